@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	doctor "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/doctor"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Report how much of a Terraform log tf-profile understands",
+	Args:  cobra.MaximumNArgs(1),
+	Long: `The 'doctor' command reports the parse coverage of a Terraform log. It shows
+	which phases (refresh, plan, apply) were detected, how many lines each parser
+	recognized and which lines look like Terraform events, but were not recognized.
+	Use it to find out why a resource is missing from the output of other commands.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return doctor.Doctor(args)
+	},
+}
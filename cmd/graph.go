@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	graph "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/graph"
 
@@ -11,13 +12,38 @@ import (
 var (
 	Size    []int
 	OutFile string
+	colorBy string
+	format  string
+	top     int
+	module  string
+
+	swimlanes   bool
+	minDuration time.Duration
+	from        int
+	to          int
+	pages       bool
 )
 
 func init() {
 	rootCmd.AddCommand(graphCmd)
 	graphCmd.Flags().IntSliceVarP(&Size, "size", "s", []int{1000, 600}, "Width and height of generated image")
 	graphCmd.Flags().StringVarP(&OutFile, "out", "o", "tf-profile-graph.png", "Output file used by gnuplot")
+	graphCmd.Flags().StringVarP(&format, "format", "f", "gnuplot", "Output format: gnuplot, folded (stacks for flamegraph.pl), svg (flame graph), mermaid or plantuml (Gantt diagrams) or terminal")
 	graphCmd.Flags().BoolVarP(&aggregate, "aggregate", "a", true, "Agregate count[] and for_each[]")
+	graphCmd.Flags().StringVar(&aggregateLevels, "aggregate-levels", "resource", "Instance keys to aggregate: resource, module or all")
+	graphCmd.Flags().IntVarP(&run, "run", "r", 0, "Only profile the Nth run in the log (0 for all runs)")
+	graphCmd.Flags().StringVarP(&unit, "unit", "u", "", "Only profile this Terragrunt unit (default: all units)")
+	graphCmd.Flags().StringVar(&planFile, "plan-json", "", "JSON plan of the run (terraform show -json <planfile>)")
+	graphCmd.Flags().StringVar(&stateFile, "state", "", "State file (or terraform show -json output) from before the run")
+	graphCmd.Flags().StringArrayVarP(&labelSelectors, "label", "l", nil, "Only profile resources with this label (key=value or key), can be repeated")
+	graphCmd.Flags().StringVar(&colorBy, "color-by", "status", "Color of the bars (gnuplot only): status, operation, module, provider or label:<key>")
+	graphCmd.Flags().IntVar(&top, "top", 0, "Only draw the N resources that took the longest (0 for all resources)")
+	graphCmd.Flags().StringVarP(&module, "module", "m", "", "Only draw the resources in this module and its submodules, e.g. 'module.core*'")
+	graphCmd.Flags().BoolVar(&swimlanes, "swimlanes", false, "Group the resources of every module in a lane of its own")
+	graphCmd.Flags().DurationVar(&minDuration, "min-duration", 0, "Only draw resources that took at least this long, e.g. 30s")
+	graphCmd.Flags().IntVar(&from, "from", 0, "Only draw what happened after this event")
+	graphCmd.Flags().IntVar(&to, "to", 0, "Only draw what happened before this event (0 for the last event)")
+	graphCmd.Flags().BoolVar(&pages, "pages", false, "Split graphs that don't fit in the height of --size into several files")
 }
 
 var graphCmd = &cobra.Command{
@@ -28,6 +54,30 @@ var graphCmd = &cobra.Command{
 		if len(Size) != 2 || Size[0] < 0 || Size[1] < 0 {
 			return fmt.Errorf("Expected two positive integers for --size flag, got %v", Size)
 		}
-		return graph.Graph(args, Size[0], Size[1], OutFile, aggregate)
+		options := graph.GraphOptions{
+			W:               Size[0],
+			H:               Size[1],
+			OutFile:         OutFile,
+			Format:          format,
+			Aggregate:       aggregate,
+			AggregateLevels: aggregateLevels,
+			Run:             run,
+			Unit:            unit,
+			Plan:            planFile,
+			State:           stateFile,
+			Labels:          labelSelectors,
+			ColorBy:         colorBy,
+			Top:             top,
+			Module:          module,
+			Layout: graph.LayoutOptions{
+				Swimlanes:   swimlanes,
+				MinDuration: float64(minDuration.Milliseconds()),
+				From:        from,
+				To:          to,
+				Pages:       pages,
+			},
+			Strict: strict,
+		}
+		return graph.Graph(args, options)
 	},
 }
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	replacements "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/replacements"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(replacementsCmd)
+	replacementsCmd.Flags().BoolVarP(&tee, "tee", "t", false, "Print logs while parsing")
+	replacementsCmd.Flags().IntVarP(&run, "run", "r", 0, "Only profile the Nth run in the log (0 for all runs)")
+}
+
+var replacementsCmd = &cobra.Command{
+	Use:   "replacements",
+	Short: "Show which attributes force resources to be replaced",
+	Args:  cobra.MaximumNArgs(1),
+	Long: `The 'replacements' command lists all resources that Terraform plans to replace,
+	grouped by resource type. For every replacement it shows why the resource must be
+	replaced: because it is tainted, because a replacement was requested, or because
+	of attributes marked with "# forces replacement" in the plan.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return replacements.Replacements(args, tee, run, strict)
+	},
+}
@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	config "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/config"
+	labels "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/labels"
+	parser "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/parser"
 	"github.com/spf13/cobra"
 )
 
@@ -8,10 +11,19 @@ var (
 	// Used for flags.
 	cfgFile     string
 	userLicense string
+	strict      bool
+	ci          string
 
 	rootCmd = &cobra.Command{
 		Use:   "tf-profile",
 		Short: "tf-profile is a CLI tool to profile Terraform runs",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			err := parser.SetCIFlavor(ci)
+			if err != nil {
+				return err
+			}
+			return loadConfig()
+		},
 	}
 )
 
@@ -21,5 +33,24 @@ func Execute() error {
 }
 
 func init() {
+	rootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "Fail on lines that look like Terraform events, but are not recognized")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file with custom parse rules and labels")
+	rootCmd.PersistentFlags().StringVar(&ci, "ci", "auto", "CI log envelope to strip: auto, none, github, gitlab, jenkins or azure")
+}
 
+// Read the config file passed with --config, if any, and register its rules
+// and labels
+func loadConfig() error {
+	if cfgFile == "" {
+		return nil
+	}
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return err
+	}
+	err = parser.AddRules(cfg.Rules)
+	if err != nil {
+		return err
+	}
+	return labels.AddLabelRules(cfg.Labels)
 }
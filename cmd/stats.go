@@ -7,13 +7,27 @@ import (
 )
 
 var (
-	aggregate bool
+	aggregate       bool
+	aggregateLevels string
+	run             int
+	unit            string
+	planFile        string
+	stateFile       string
+	groupBy         string
+	labelSelectors  []string
 )
 
 func init() {
 	rootCmd.AddCommand(statsCmd)
 	statsCmd.Flags().BoolP("tee", "t", false, "Print logs while parsing")
 	statsCmd.Flags().BoolVarP(&aggregate, "aggregate", "a", true, "Agregate count[] and for_each[]")
+	statsCmd.Flags().StringVar(&aggregateLevels, "aggregate-levels", "resource", "Instance keys to aggregate: resource, module or all")
+	statsCmd.Flags().IntVarP(&run, "run", "r", 0, "Only profile the Nth run in the log (0 for all runs)")
+	statsCmd.Flags().StringVarP(&unit, "unit", "u", "", "Only profile this Terragrunt unit (default: all units)")
+	statsCmd.Flags().StringVar(&planFile, "plan-json", "", "JSON plan of the run (terraform show -json <planfile>)")
+	statsCmd.Flags().StringVarP(&groupBy, "group-by", "g", "", "Also show statistics per group of resources: type, provider, module, top-module or label:<key>")
+	statsCmd.Flags().StringArrayVarP(&labelSelectors, "label", "l", nil, "Only profile resources with this label (key=value or key), can be repeated")
+	statsCmd.Flags().StringVar(&stateFile, "state", "", "State file (or terraform show -json output) from before the run")
 }
 
 var statsCmd = &cobra.Command{
@@ -23,6 +37,6 @@ var statsCmd = &cobra.Command{
 	a Terraform run. It prints high-level statistics on the following topics:
 	basic, time-related, creation status and modules.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return stats.Stats(args, tee, aggregate)
+		return stats.Stats(args, tee, aggregate, aggregateLevels, run, unit, planFile, stateFile, groupBy, labelSelectors, strict)
 	},
 }
@@ -1,14 +1,17 @@
 package cmd
 
 import (
+	"fmt"
+
 	table "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/table"
 	"github.com/spf13/cobra"
 )
 
 var (
-	max_depth int
-	tee       bool
-	sort      string
+	max_depth    int
+	tee          bool
+	sort         string
+	distribution bool
 )
 
 func init() {
@@ -28,7 +31,15 @@ func init() {
 		"Max recursive module depth before aggregating.",
 	)
 	tableCmd.Flags().BoolVarP(&aggregate, "aggregate", "a", true, "Agregate count[] and for_each[]")
+	tableCmd.Flags().StringVar(&aggregateLevels, "aggregate-levels", "resource", "Instance keys to aggregate: resource, module or all")
 	tableCmd.Flags().Bool("tee", false, "Print logs while parsing")
+	tableCmd.Flags().IntVarP(&run, "run", "r", 0, "Only profile the Nth run in the log (0 for all runs)")
+	tableCmd.Flags().StringVarP(&unit, "unit", "u", "", "Only profile this Terragrunt unit (default: all units)")
+	tableCmd.Flags().StringVar(&planFile, "plan-json", "", "JSON plan of the run (terraform show -json <planfile>)")
+	tableCmd.Flags().StringVarP(&groupBy, "group-by", "g", "", "Show one row per group of resources: type, provider, module, top-module or label:<key>")
+	tableCmd.Flags().StringArrayVarP(&labelSelectors, "label", "l", nil, "Only profile resources with this label (key=value or key), can be repeated")
+	tableCmd.Flags().BoolVar(&distribution, "distribution", false, "Show the distribution of durations within aggregated resources")
+	tableCmd.Flags().StringVar(&stateFile, "state", "", "State file (or terraform show -json output) from before the run")
 }
 
 var tableCmd = &cobra.Command{
@@ -38,6 +49,29 @@ var tableCmd = &cobra.Command{
 	Long: `The 'table' command is used to do in-depth profiling on a resource level.
 	It will parse a log, extract metrics about all resources and show tabular output.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return table.Table(args, max_depth, tee, sort, aggregate)
+		// Groups are built from individual resources and sorted by duration
+		if groupBy != "" {
+			for _, flag := range []string{"sort", "max_depth", "aggregate", "aggregate-levels", "distribution"} {
+				if cmd.Flags().Changed(flag) {
+					return fmt.Errorf("--%v can't be combined with --group-by", flag)
+				}
+			}
+		}
+		options := table.TableOptions{
+			MaxDepth:        max_depth,
+			Tee:             tee,
+			Sort:            sort,
+			Aggregate:       aggregate,
+			AggregateLevels: aggregateLevels,
+			Run:             run,
+			Unit:            unit,
+			Plan:            planFile,
+			State:           stateFile,
+			GroupBy:         groupBy,
+			Labels:          labelSelectors,
+			Distribution:    distribution,
+			Strict:          strict,
+		}
+		return table.Table(args, options)
 	},
 }
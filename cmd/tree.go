@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"time"
+
+	stats "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/stats"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	treeDepth    int
+	treeMinTime  time.Duration
+	treeCollapse float64
+	treeExpand   int
+)
+
+func init() {
+	rootCmd.AddCommand(treeCmd)
+	treeCmd.Flags().BoolVarP(&tee, "tee", "t", false, "Print logs while parsing")
+	treeCmd.Flags().BoolVarP(&aggregate, "aggregate", "a", true, "Agregate count[] and for_each[]")
+	treeCmd.Flags().StringVar(&aggregateLevels, "aggregate-levels", "resource", "Instance keys to aggregate: resource, module or all")
+	treeCmd.Flags().IntVarP(&run, "run", "r", 0, "Only profile the Nth run in the log (0 for all runs)")
+	treeCmd.Flags().StringVarP(&unit, "unit", "u", "", "Only profile this Terragrunt unit (default: all units)")
+	treeCmd.Flags().StringArrayVarP(&labelSelectors, "label", "l", nil, "Only profile resources with this label (key=value or key), can be repeated")
+	treeCmd.Flags().IntVarP(&treeDepth, "depth", "d", 0, "Deepest level of modules to print (0 for all levels)")
+	treeCmd.Flags().DurationVar(&treeMinTime, "min-time", 0, "Hide modules that took less time, e.g. 30s")
+	treeCmd.Flags().Float64Var(&treeCollapse, "collapse", 0, "Hide the submodules of modules that took less than this percentage of the total time")
+	treeCmd.Flags().IntVar(&treeExpand, "expand", 0, "Print the resources of modules with at most this many resources")
+}
+
+var treeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Show the module hierarchy of a Terraform run",
+	Args:  cobra.MaximumNArgs(1),
+	Long: `The 'tree' command prints the module hierarchy of a Terraform run as an
+	indented tree. Every module shows the number of resources in it and its
+	submodules, their cumulative duration, the number of failures and the
+	operations performed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options := stats.TreeOptions{
+			Depth:    treeDepth,
+			MinTime:  float64(treeMinTime.Milliseconds()),
+			Collapse: treeCollapse,
+			Expand:   treeExpand,
+		}
+		return stats.Tree(args, tee, aggregate, aggregateLevels, run, unit, labelSelectors, options, strict)
+	},
+}
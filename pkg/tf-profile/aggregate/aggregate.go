@@ -1,16 +1,39 @@
 package tfprofile
 
 import (
+	"fmt"
 	"sort"
 	"strings"
 
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
 )
 
+// Levels at which instance keys are aggregated, see AggregateLevels
+const (
+	AggregateAll      = "all"
+	AggregateResource = "resource"
+	AggregateModule   = "module"
+)
+
 // Take a parsed log and aggregate resources created
 // by the same `foreach` or `count` loop.
 func Aggregate(log ParsedLog) (ParsedLog, error) {
-	New := ParsedLog{Resources: make(map[string]ResourceMetric)}
+	return AggregateLevels(log, AggregateResource)
+}
+
+// Take a parsed log and aggregate resources whose addresses only differ in
+// their instance keys. Depending on levels, only the keys of resources
+// (`resource[*]`), of modules (`module.x[*].resource`) or both are replaced.
+func AggregateLevels(log ParsedLog, levels string) (ParsedLog, error) {
+	switch levels {
+	case AggregateAll, AggregateResource, AggregateModule:
+	default:
+		return ParsedLog{}, fmt.Errorf("Unknown aggregation level %v (expected all, resource or module)", levels)
+	}
+
+	// Keep everything but the resources, which are rebuilt below
+	New := log
+	New.Resources = make(map[string]ResourceMetric)
 
 	// Collect all resource names in slice and sort
 	ResourceNames := []string{}
@@ -19,91 +42,57 @@ func Aggregate(log ParsedLog) (ParsedLog, error) {
 	}
 	sort.Strings(ResourceNames)
 
-	// Loop over resources, collect resources to aggregate
-	// and aggregate when coming across a new one.
-	ToAgg := []string{}
-
+	// Collect the resources that share an aggregated name. Names are
+	// added in sorted order, so every list is sorted as well.
+	ToAgg := map[string][]string{}
 	for _, name := range ResourceNames {
-		// If the list is empty, start over.
-		if len(ToAgg) == 0 {
-			ToAgg = append(ToAgg, name)
-		} else if canAggregate(name, last(ToAgg)) {
-			// If this name is compatible with the list, add it
-			ToAgg = append(ToAgg, name)
-		} else {
-			// Found incompatible resource, aggregate, add to log and start over
-			AggName, AggMetric := aggregateResources(log, ToAgg)
-
-			// Add aggregated resource to log we're building
-			NewLog := New.Resources
-			NewLog[AggName] = AggMetric
-			New.Resources = NewLog
-
-			// Start over with what the resource we just saw
-			ToAgg = []string{name}
-		}
+		key := aggregationKey(name, levels)
+		ToAgg[key] = append(ToAgg[key], name)
 	}
 
-	// Aggregate leftovers in the list to get the final result
-	if len(ToAgg) > 0 {
-		AggName, AggMetric := aggregateResources(log, ToAgg)
-		NewLog := New.Resources
-		NewLog[AggName] = AggMetric
-		New.Resources = NewLog
+	for key, resources := range ToAgg {
+		// Singleton, keep its original name
+		if len(resources) == 1 {
+			New.Resources[resources[0]] = log.Resources[resources[0]]
+			continue
+		}
+		New.Resources[key] = aggregateResources(log, resources)
 	}
 
 	return New, nil
 }
 
-// Helper: return the last item in a list
-func last(l []string) string {
-	return l[len(l)-1]
-}
-
-// Given two resource names, returns true if they were created using
-// `count` or `for_each`. For example: `resource[1]` and `resource[2]`
-func canAggregate(Resource1 string, Resource2 string) bool {
-	// If any resource doesn't end with "]", can't aggregate
-	if Resource1[len(Resource1)-1] != ']' || Resource2[len(Resource2)-1] != ']' {
-		return false
-	}
-	// Anything before the last "[" must be equal
-	split1 := strings.Split(Resource1, "[")
-	split2 := strings.Split(Resource2, "[")
-	if len(split1) == 1 || len(split2) == 1 {
-		return false
-	}
-
-	prefix1 := strings.Join(split1[:len(split1)-1], "[")
-	prefix2 := strings.Join(split2[:len(split2)-1], "[")
-	if prefix1 != prefix2 {
-		return false
+// Returns the name of a resource after aggregating, by replacing instance
+// keys with `[*]`. For example, `module.x["a"].resource[1]` becomes
+// `module.x["a"].resource[*]` (resource), `module.x[*].resource[1]` (module)
+// or `module.x[*].resource[*]` (all). Terragrunt units are kept, so resources
+// of different units are never aggregated.
+func aggregationKey(name string, levels string) string {
+	unit, address := SplitUnit(name)
+	parts := SplitAddress(address)
+	for idx, part := range parts {
+		if !strings.HasSuffix(part, "]") {
+			continue
+		}
+		isModule := idx > 0 && parts[idx-1] == "module" && idx < len(parts)-1
+		isResource := idx == len(parts)-1
+		if (isModule && levels != AggregateResource) || (isResource && levels != AggregateModule) {
+			parts[idx] = StripInstanceKey(part) + "[*]"
+		}
 	}
-	return true
+	return UnitAddress(unit, strings.Join(parts, "."))
 }
 
-// Given a log and resources names to aggregate, find an aggregated name and
-// an aggregated ResourceMetric
-func aggregateResources(log ParsedLog, resources []string) (string, ResourceMetric) {
-	// Singleton, just return it
-	if len(resources) == 1 {
-		return resources[0], log.Resources[resources[0]]
-	}
-
+// Given a log and resources names to aggregate, find an aggregated ResourceMetric
+func aggregateResources(log ParsedLog, resources []string) ResourceMetric {
 	Metrics := []ResourceMetric{}
 	for _, r := range resources {
 		Metrics = append(Metrics, log.Resources[r])
 	}
 
-	return aggregateResourceNames(resources...), aggregateResourceMetrics(Metrics...)
-}
-
-// Returns a new name for aggregated resource. For example:
-// (module.x.resource[1], module.x.resource[2]) -> module.x.resource[*]
-func aggregateResourceNames(names ...string) string {
-	// Actually we only need to look at one item for now.
-	split := strings.Split(names[0], "[")
-	return strings.Join(split[:len(split)-1], "[") + "[*]"
+	Metric := aggregateResourceMetrics(Metrics...)
+	Metric.Distribution = newDistribution(resources, Metrics)
+	return Metric
 }
 
 // Aggregates a number of ResourceMetrics into one.
@@ -112,6 +101,12 @@ func aggregateResourceNames(names ...string) string {
 // ModificationStartedIndex contains the *lowest* ModificationStartedIndex of any record.
 // ModificationCompletedIndex contains the *highest* ModificationStartedIndex of any record.
 // AfterStatus can be any of "Created", "Failed", "NotCreated", "Multiple" or "Unknown"
+// RefreshIndex and RefreshEvent contain the first refresh of any record.
+// Drift is "Multiple" if records drifted in different ways.
+// MovedFrom contains the previous address, with all instance keys aggregated, if all records were moved.
+// Unit and Provider are taken from the first record: resources of different units are never aggregated.
+// Provider calls, HTTP requests, retries and errors contain the sum over all records.
+// Distribution is left empty: it needs the names of the records, see aggregateResources.
 func aggregateResourceMetrics(metrics ...ResourceMetric) ResourceMetric {
 	NumCalls := len(metrics)
 	TotalTime := float64(0)
@@ -124,9 +119,20 @@ func aggregateResourceMetrics(metrics ...ResourceMetric) ResourceMetric {
 	AfterStatus := NoneStatus
 	DesiredStatus := NoneStatus
 	Operation := NoneOp
+	RefreshIndex := -1
+	RefreshEvent := -1
+	Drift := NoDrift
+	MovedFrom := []string{}
+	Provider := ResourceMetric{}
 
-	for _, metric := range metrics {
+	for idx, metric := range metrics {
 		TotalTime += metric.TotalTime
+		Provider.ProviderCalls += metric.ProviderCalls
+		Provider.ProviderTime += metric.ProviderTime
+		Provider.HTTPRequests += metric.HTTPRequests
+		Provider.HTTPRetries += metric.HTTPRetries
+		Provider.HTTPThrottled += metric.HTTPThrottled
+		Provider.HTTPServerErrors += metric.HTTPServerErrors
 
 		// For ModificationStartedIndex and ModificationStartedEvent, take the first one we see
 		if ModificationStartedIndex == -1 {
@@ -135,6 +141,13 @@ func aggregateResourceMetrics(metrics ...ResourceMetric) ResourceMetric {
 		if ModificationStartedEvent == -1 {
 			ModificationStartedEvent = metric.ModificationStartedEvent
 		}
+		// Same for RefreshIndex and RefreshEvent
+		if RefreshIndex == -1 {
+			RefreshIndex = metric.RefreshIndex
+		}
+		if RefreshEvent == -1 {
+			RefreshEvent = metric.RefreshEvent
+		}
 
 		// For ModificationCompletedIndex and ModificationCompletedEvent, take the maximum
 		ModificationCompletedIndex = maxInt(ModificationCompletedIndex, metric.ModificationCompletedIndex)
@@ -168,7 +181,20 @@ func aggregateResourceMetrics(metrics ...ResourceMetric) ResourceMetric {
 		if Operation != metric.Operation {
 			Operation = MultipleOp
 		}
+		if idx == 0 {
+			Drift = metric.Drift
+		} else if Drift != metric.Drift {
+			Drift = MultipleDrift
+		}
+		if metric.MovedFrom != "" {
+			MovedFrom = append(MovedFrom, metric.MovedFrom)
+		}
+	}
 
+	// Only keep the previous address if all records were moved
+	AggMovedFrom := ""
+	if len(MovedFrom) == NumCalls {
+		AggMovedFrom = aggregationKey(MovedFrom[0], AggregateAll)
 	}
 
 	return ResourceMetric{
@@ -182,6 +208,18 @@ func aggregateResourceMetrics(metrics ...ResourceMetric) ResourceMetric {
 		AfterStatus:                AfterStatus,
 		DesiredStatus:              DesiredStatus,
 		Operation:                  Operation,
+		RefreshIndex:               RefreshIndex,
+		RefreshEvent:               RefreshEvent,
+		Drift:                      Drift,
+		MovedFrom:                  AggMovedFrom,
+		Unit:                       metrics[0].Unit,
+		Provider:                   metrics[0].Provider,
+		ProviderCalls:              Provider.ProviderCalls,
+		ProviderTime:               Provider.ProviderTime,
+		HTTPRequests:               Provider.HTTPRequests,
+		HTTPRetries:                Provider.HTTPRetries,
+		HTTPThrottled:              Provider.HTTPThrottled,
+		HTTPServerErrors:           Provider.HTTPServerErrors,
 	}
 }
 
@@ -1,6 +1,7 @@
 package tfprofile
 
 import (
+	"math"
 	"testing"
 
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
@@ -12,6 +13,18 @@ import (
 func MkMetric(a int, b float64, c int, d int, e int, f int, g Status, h Status, i Status, j Operation) ResourceMetric {
 	return ResourceMetric{NumCalls: a, TotalTime: b, ModificationStartedIndex: c, ModificationCompletedIndex: d, ModificationStartedEvent: e, ModificationCompletedEvent: f, BeforeStatus: g, AfterStatus: h, DesiredStatus: i, Operation: j}
 }
+
+// Helper to add the distribution of instance durations to an aggregated metric
+func WithDist(m ResourceMetric, d Distribution) ResourceMetric {
+	m.Distribution = d
+	return m
+}
+
+// Distribution of three instances that took 1, 2 and 3ms
+func Dist123(Slowest string) Distribution {
+	return Distribution{Count: 3, Min: 1, Max: 3, Mean: 2, Median: 2, P90: 3, P99: 3, StdDev: math.Sqrt(2.0 / 3), Slowest: Slowest}
+}
+
 func TestAggregateResourceMetricBasic(t *testing.T) {
 	M1 := MkMetric(1, 2000, 0, 0, 0, 3, NotCreated, Created, Created, Create)
 	M2 := MkMetric(1, 5000, 1, 1, 1, 4, NotCreated, Created, Created, Create)
@@ -37,18 +50,71 @@ func TestAggregateResourceMetricStatuses(t *testing.T) {
 	assert.Equal(t, Multiple, Result)
 }
 
-func TestCanAgg(t *testing.T) {
-	assert.False(t, canAggregate("resource1", "resource2"))
-	assert.False(t, canAggregate("module.x.r1", "module.y.r1"))
-	assert.False(t, canAggregate("module.x[1].r1", "module.x[1].r2"))
-	assert.False(t, canAggregate("module.x.r[1]", "module.y.r[2]"))
-	assert.False(t, canAggregate("module.x.r1[\"abc\"]", "module.y.r1[\"def\"]"))
+func TestAggregationKey(t *testing.T) {
+	// Without instance keys, names are kept
+	assert.Equal(t, "resource1", aggregationKey("resource1", AggregateResource))
+	assert.Equal(t, "module.x.r1", aggregationKey("module.x.r1", AggregateResource))
+	assert.Equal(t, "module.x[1].r1", aggregationKey("module.x[1].r1", AggregateResource))
+
+	// Only resources in the same module are aggregated
+	assert.Equal(t, "module.x.r[*]", aggregationKey("module.x.r[1]", AggregateResource))
+	assert.Equal(t, "module.y.r[*]", aggregationKey("module.y.r[2]", AggregateResource))
+	assert.Equal(t, "module.x.r1[*]", aggregationKey("module.x.r1[\"abc\"]", AggregateResource))
+	assert.Equal(t, "module.x.r1[*]", aggregationKey("module.x.r1[\"def\"]", AggregateResource))
+	assert.Equal(t, "r1[*]", aggregationKey("r1[1]", AggregateResource))
+	assert.Equal(t, "r1[*]", aggregationKey("r1[\"abc\"]", AggregateResource))
+	assert.Equal(t, "module.x[\"a\"].r1[*]", aggregationKey("module.x[\"a\"].r1[\"abc\"]", AggregateResource))
+	assert.Equal(t, "r[*]", aggregationKey("r[\"a\"]", AggregateResource)) // Edge case as they come from different loops...
+}
+
+func TestAggregationKeyLevels(t *testing.T) {
+	assert.Equal(t, "module.x[\"a\"].r", aggregationKey("module.x[\"a\"].r", AggregateResource))
+	assert.Equal(t, "module.x[*].r", aggregationKey("module.x[\"a\"].r", AggregateModule))
+	assert.Equal(t, "module.x[*].r", aggregationKey("module.x[\"b\"].r", AggregateAll))
+
+	assert.Equal(t, "module.x[*].r[0]", aggregationKey("module.x[\"a\"].r[0]", AggregateModule))
+	assert.Equal(t, "module.x[*].r[1]", aggregationKey("module.x[\"b\"].r[1]", AggregateModule))
+	assert.Equal(t, "module.x[*].r[*]", aggregationKey("module.x[\"b\"].r[1]", AggregateAll))
+	assert.Equal(t, "module.x[*].module.y[*].r", aggregationKey("module.x[0].module.y[\"a\"].r", AggregateAll))
+	assert.Equal(t, "module.y[*].r", aggregationKey("module.y[0].r", AggregateAll))
+
+	assert.Equal(t, "module.x[*].r[0]", aggregationKey("module.x[\"a.b\"].r[0]", AggregateModule))
+	assert.Equal(t, "[network] module.x[*].r[*]", aggregationKey("[network] module.x[1].r[0]", AggregateAll))
+	assert.Equal(t, "[queue] module.x[*].r", aggregationKey("[queue] module.x[1].r", AggregateAll))
+}
+
+func TestAggregateLevels(t *testing.T) {
+	In := ParsedLog{Resources: map[string]ResourceMetric{
+		"module.app[\"a\"].aws_s3_bucket.b":    {NumCalls: 1, TotalTime: 1000, ModificationCompletedIndex: 0},
+		"module.app[\"a\"].aws_s3_object.o[0]": {NumCalls: 1, TotalTime: 1000, ModificationCompletedIndex: 1},
+		"module.app[\"b\"].aws_s3_bucket.b":    {NumCalls: 1, TotalTime: 3000, ModificationCompletedIndex: 2},
+		"module.app[\"b\"].aws_s3_object.o[0]": {NumCalls: 1, TotalTime: 1000, ModificationCompletedIndex: 3},
+		"module.app[\"b\"].aws_s3_object.o[1]": {NumCalls: 1, TotalTime: 1000, ModificationCompletedIndex: 4},
+		"aws_iam_role.r":                       {NumCalls: 1, TotalTime: 1000, ModificationCompletedIndex: 5},
+	}}
+
+	Out, err := AggregateLevels(In, AggregateModule)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(Out.Resources))
+	assert.Equal(t, 2, Out.Resources["module.app[*].aws_s3_bucket.b"].NumCalls)
+	assert.Equal(t, float64(4000), Out.Resources["module.app[*].aws_s3_bucket.b"].TotalTime)
+	assert.Equal(t, "module.app[\"b\"].aws_s3_bucket.b", Out.Resources["module.app[*].aws_s3_bucket.b"].Distribution.Slowest)
+	assert.Equal(t, 2, Out.Resources["module.app[*].aws_s3_object.o[0]"].NumCalls)
+	assert.Equal(t, 1, Out.Resources["module.app[\"b\"].aws_s3_object.o[1]"].NumCalls)
+	assert.Equal(t, 1, Out.Resources["aws_iam_role.r"].NumCalls)
+
+	Out, err = AggregateLevels(In, AggregateAll)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(Out.Resources))
+	assert.Equal(t, 3, Out.Resources["module.app[*].aws_s3_object.o[*]"].NumCalls)
+
+	Out, err = AggregateLevels(In, AggregateResource)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(Out.Resources))
+	assert.Equal(t, 2, Out.Resources["module.app[\"b\"].aws_s3_object.o[*]"].NumCalls)
 
-	assert.True(t, canAggregate("r1[1]", "r1[2]"))
-	assert.True(t, canAggregate("r1[\"abc\"]", "r1[\"def\"]"))
-	assert.True(t, canAggregate("module.x.r1[\"abc\"]", "module.x.r1[\"def\"]"))
-	assert.True(t, canAggregate("module.x[\"a\"].r1[\"abc\"]", "module.x[\"a\"].r1[\"def\"]"))
-	assert.True(t, canAggregate("r[1]", "r[\"a\"]")) // Edge case as they come from different loops...
+	_, err = AggregateLevels(In, "type")
+	assert.NotNil(t, err)
 }
 
 // No aggregation possible
@@ -75,7 +141,7 @@ func TestBasicAgg(t *testing.T) {
 	}
 	Out := ParsedLog{
 		Resources: map[string]ResourceMetric{
-			"r1[*]": MkMetric(3, 3, 0, 2, 0, 5, NotCreated, Created, Created, Create),
+			"r1[*]": WithDist(MkMetric(3, 3, 0, 2, 0, 5, NotCreated, Created, Created, Create), Distribution{Count: 3, Min: 1, Max: 1, Mean: 1, Median: 1, P90: 1, P99: 1, Slowest: "r1[1]"}),
 		},
 	}
 	Result, err := Aggregate(In)
@@ -97,8 +163,8 @@ func TestMixedAgg(t *testing.T) {
 	}
 	Out := ParsedLog{
 		Resources: map[string]ResourceMetric{
-			"r1[*]": MkMetric(3, 3, 0, 2, 0, 9, NotCreated, Created, Created, Create),
-			"r2[*]": MkMetric(2, 2, 3, 4, 3, 11, NotCreated, Created, Created, Create),
+			"r1[*]": WithDist(MkMetric(3, 3, 0, 2, 0, 9, NotCreated, Created, Created, Create), Distribution{Count: 3, Min: 1, Max: 1, Mean: 1, Median: 1, P90: 1, P99: 1, Slowest: "r1[1]"}),
+			"r2[*]": WithDist(MkMetric(2, 2, 3, 4, 3, 11, NotCreated, Created, Created, Create), Distribution{Count: 2, Min: 1, Max: 1, Mean: 1, Median: 1, P90: 1, P99: 1, Slowest: "r2[\"a\"]"}),
 			"r3":    MkMetric(1, 1, 5, 5, 5, 12, NotCreated, Created, Created, Create),
 			"r4":    MkMetric(1, 1, 6, 6, 6, 13, NotCreated, Created, Created, Create),
 		},
@@ -136,16 +202,71 @@ func TestFullAgg(t *testing.T) {
 	}
 	Out := ParsedLog{
 		Resources: map[string]ResourceMetric{
-			"module.x.r[*]":                MkMetric(3, 6, 0, 0, 0, 0, NotCreated, Created, Created, Create),
-			"module.y[1].module.y[1].r[*]": MkMetric(3, 6, 0, 0, 0, 0, NotCreated, Created, Created, Create),
-			"module.z[1].module.z[1].r[*]": MkMetric(3, 6, 2, 9, 0, 0, NotCreated, Created, Created, Create),
-			"module.a[1].module.b[1].r[*]": MkMetric(4, 7, 2, 9, 0, 0, NotCreated, Multiple, Created, Create),
-			"random_resource":              MkMetric(1, 1, 5, 9, 1, 1, NotCreated, Created, Created, Create),
-			"random_resource2":             MkMetric(1, 1, 5, 9, 2, 2, NotCreated, Failed, Created, Create),
-			"random_resource3":             MkMetric(1, 1, 5, 9, 3, 3, NotCreated, Failed, Created, Create),
+			"module.x.r[*]":                WithDist(MkMetric(3, 6, 0, 0, 0, 0, NotCreated, Created, Created, Create), Dist123("module.x.r[3]")),
+			"module.y[1].module.y[1].r[*]": WithDist(MkMetric(3, 6, 0, 0, 0, 0, NotCreated, Created, Created, Create), Dist123("module.y[1].module.y[1].r[3]")),
+			"module.z[1].module.z[1].r[*]": WithDist(MkMetric(3, 6, 2, 9, 0, 0, NotCreated, Created, Created, Create), Dist123("module.z[1].module.z[1].r[1]")),
+			"module.a[1].module.b[1].r[*]": WithDist(MkMetric(4, 7, 2, 9, 0, 0, NotCreated, Multiple, Created, Create), Distribution{
+				Count: 4, Min: 1, Max: 3, Mean: 1.75, Median: 1.5, P90: 3, P99: 3, StdDev: math.Sqrt(0.6875), Slowest: "module.a[1].module.b[1].r[1]",
+			}),
+			"random_resource":  MkMetric(1, 1, 5, 9, 1, 1, NotCreated, Created, Created, Create),
+			"random_resource2": MkMetric(1, 1, 5, 9, 2, 2, NotCreated, Failed, Created, Create),
+			"random_resource3": MkMetric(1, 1, 5, 9, 3, 3, NotCreated, Failed, Created, Create),
 		},
 	}
 	Result, err := Aggregate(In)
 	assert.Nil(t, err)
 	assert.Equal(t, Out, Result)
 }
+
+func TestAggregateMovedFrom(t *testing.T) {
+	Moved := aggregateResourceMetrics(
+		ResourceMetric{Operation: Move, MovedFrom: "old[0]"},
+		ResourceMetric{Operation: Move, MovedFrom: "old[1]"},
+	)
+	assert.Equal(t, "old[*]", Moved.MovedFrom)
+
+	PartiallyMoved := aggregateResourceMetrics(
+		ResourceMetric{Operation: Move, MovedFrom: "old[0]"},
+		ResourceMetric{Operation: Create},
+	)
+	assert.Equal(t, "", PartiallyMoved.MovedFrom)
+
+	ModuleMoved := aggregateResourceMetrics(
+		ResourceMetric{Operation: Move, MovedFrom: "module.old[\"a\"].r"},
+		ResourceMetric{Operation: Move, MovedFrom: "module.old[\"b\"].r"},
+	)
+	assert.Equal(t, "module.old[*].r", ModuleMoved.MovedFrom)
+}
+
+func TestAggregateUnits(t *testing.T) {
+	In := ParsedLog{Resources: map[string]ResourceMetric{
+		"[network] aws_subnet.private[0]": {NumCalls: 1, Unit: "network"},
+		"[network] aws_subnet.private[1]": {NumCalls: 1, Unit: "network"},
+		"[queue] aws_subnet.private[0]":   {NumCalls: 1, Unit: "queue"},
+	}}
+	Out, err := Aggregate(In)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(Out.Resources))
+	assert.Equal(t, 2, Out.Resources["[network] aws_subnet.private[*]"].NumCalls)
+	assert.Equal(t, "network", Out.Resources["[network] aws_subnet.private[*]"].Unit)
+	assert.Equal(t, "queue", Out.Resources["[queue] aws_subnet.private[0]"].Unit)
+}
+
+func TestDistribution(t *testing.T) {
+	Names := []string{"r[0]", "r[1]", "r[2]", "r[3]"}
+	Metrics := []ResourceMetric{
+		{TotalTime: 4000, ModificationCompletedIndex: 0},
+		{TotalTime: 600000, ModificationCompletedIndex: 1},
+		{TotalTime: -1, ModificationCompletedIndex: -1}, // Not finished
+		{TotalTime: 0, ModificationCompletedIndex: -1},  // Not modified
+	}
+	Dist := newDistribution(Names, Metrics)
+	assert.Equal(t, 2, Dist.Count)
+	assert.Equal(t, float64(4000), Dist.Min)
+	assert.Equal(t, float64(600000), Dist.Max)
+	assert.Equal(t, float64(302000), Dist.Median)
+	assert.Equal(t, float64(298000), Dist.StdDev)
+	assert.Equal(t, "r[1]", Dist.Slowest)
+
+	assert.Equal(t, Distribution{}, newDistribution(Names[2:], Metrics[2:]))
+}
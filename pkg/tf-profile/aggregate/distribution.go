@@ -0,0 +1,78 @@
+package tfprofile
+
+import (
+	"math"
+	"sort"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+// Returns true if a resource was modified and has a duration. Unfinished
+// resources have TotalTime -1, unmodified resources no completion index.
+func hasDuration(metric ResourceMetric) bool {
+	return metric.TotalTime > 0 || (metric.TotalTime == 0 && metric.ModificationCompletedIndex != -1)
+}
+
+// Distribution of the durations of resources, given their names and metrics
+func newDistribution(names []string, metrics []ResourceMetric) Distribution {
+	Durations := []float64{}
+	Slowest := ""
+	SlowestTime := float64(-1)
+	for idx, metric := range metrics {
+		if !hasDuration(metric) {
+			continue
+		}
+		Durations = append(Durations, metric.TotalTime)
+		if metric.TotalTime > SlowestTime {
+			Slowest, SlowestTime = names[idx], metric.TotalTime
+		}
+	}
+	if len(Durations) == 0 {
+		return Distribution{}
+	}
+	sort.Float64s(Durations)
+
+	Sum := float64(0)
+	for _, d := range Durations {
+		Sum += d
+	}
+	Mean := Sum / float64(len(Durations))
+	Variance := float64(0)
+	for _, d := range Durations {
+		Variance += (d - Mean) * (d - Mean)
+	}
+	Variance /= float64(len(Durations))
+
+	return Distribution{
+		Count:   len(Durations),
+		Min:     Durations[0],
+		Max:     Durations[len(Durations)-1],
+		Mean:    Mean,
+		Median:  median(Durations),
+		P90:     Percentile(Durations, 90),
+		P99:     Percentile(Durations, 99),
+		StdDev:  math.Sqrt(Variance),
+		Slowest: Slowest,
+	}
+}
+
+// Nearest-rank percentile of sorted values
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Median of sorted values: the mean of the middle two for an even number of values
+func median(sorted []float64) float64 {
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[middle]
+	}
+	return (sorted[middle-1] + sorted[middle]) / 2
+}
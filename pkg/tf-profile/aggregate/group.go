@@ -0,0 +1,176 @@
+package tfprofile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/labels"
+)
+
+// Ways to group resources, see GroupBy
+const (
+	GroupByType      = "type"
+	GroupByProvider  = "provider"
+	GroupByModule    = "module"
+	GroupByTopModule = "top-module"
+)
+
+// Name of the group for resources in the root module
+const RootModule = "(root)"
+
+// Resources that share a type, provider or module, see GroupBy
+type Group struct {
+	Name string
+	// All resources of the group aggregated into one (see aggregateResourceMetrics).
+	// NumCalls is the number of resources, TotalTime their cumulative duration.
+	Metric ResourceMetric
+	// Longest, mean and 95th percentile duration of the resources in the
+	// group that were modified, in ms
+	MaxTime  float64
+	MeanTime float64
+	P95Time  float64
+	// Number of resources that failed
+	Failures int
+	// Number of resources per operation
+	Operations map[Operation]int
+}
+
+// Group all resources in a log by type, provider, module, top-module or by a
+// label from the config file (e.g. "label:team").
+// Expects a log that is not aggregated yet, so that every resource counts
+// towards the duration statistics. Groups are sorted by cumulative duration
+// (descending), then by name.
+func GroupBy(log ParsedLog, by string) ([]Group, error) {
+	_, isLabel := LabelKey(by)
+	switch by {
+	case GroupByType, GroupByProvider, GroupByModule, GroupByTopModule:
+	default:
+		if !isLabel {
+			return nil, fmt.Errorf("Unknown group %v (expected type, provider, module, top-module or label:<key>)", by)
+		}
+	}
+
+	Members := map[string][]string{}
+	for resource, metric := range log.Resources {
+		key := GroupKey(resource, metric, by)
+		Members[key] = append(Members[key], resource)
+	}
+
+	Groups := []Group{}
+	for name, resources := range Members {
+		sort.Strings(resources)
+		Groups = append(Groups, newGroup(log, name, resources))
+	}
+	sort.Slice(Groups, func(i int, j int) bool {
+		if Groups[i].Metric.TotalTime != Groups[j].Metric.TotalTime {
+			return Groups[i].Metric.TotalTime > Groups[j].Metric.TotalTime
+		}
+		return Groups[i].Name < Groups[j].Name
+	})
+	return Groups, nil
+}
+
+// Name of the group a resource belongs to. Resources without a known
+// provider are grouped by the prefix of their type, e.g. "aws" for
+// "aws_iam_role". Modules of Terragrunt units keep their unit. Resources
+// without the label of a "label:<key>" grouping are grouped as NoLabel.
+func GroupKey(resource string, metric ResourceMetric, by string) string {
+	if key, ok := LabelKey(by); ok {
+		return Label(resource, key)
+	}
+	unit, address := SplitUnit(resource)
+	parts := SplitAddress(address)
+	switch by {
+	case GroupByType:
+		if IsDataSource(address) {
+			return "data." + ResourceType(address)
+		}
+		return ResourceType(address)
+	case GroupByProvider:
+		if metric.Provider != "" {
+			return metric.Provider
+		}
+		return strings.SplitN(ResourceType(address), "_", 2)[0]
+	case GroupByModule:
+		module := []string{}
+		for idx := 0; idx+1 < len(parts) && parts[idx] == "module"; idx += 2 {
+			module = append(module, parts[idx], parts[idx+1])
+		}
+		return moduleGroup(unit, strings.Join(module, "."))
+	case GroupByTopModule:
+		if len(parts) > 2 && parts[0] == "module" {
+			return moduleGroup(unit, "module."+StripInstanceKey(parts[1]))
+		}
+		return moduleGroup(unit, "")
+	}
+	return ""
+}
+
+func moduleGroup(unit string, module string) string {
+	if module == "" {
+		module = RootModule
+	}
+	return UnitAddress(unit, module)
+}
+
+// Compute the statistics of a group of resources
+func newGroup(log ParsedLog, name string, resources []string) Group {
+	Metrics := []ResourceMetric{}
+	Durations := []float64{}
+	Cumulative := float64(0)
+	Failures := 0
+	Operations := map[Operation]int{}
+
+	for _, resource := range resources {
+		metric := log.Resources[resource]
+		Metrics = append(Metrics, metric)
+		if hasDuration(metric) {
+			Durations = append(Durations, metric.TotalTime)
+			Cumulative += metric.TotalTime
+		}
+		if metric.AfterStatus == Failed {
+			Failures += metric.NumCalls
+		}
+		Operations[metric.Operation] += metric.NumCalls
+	}
+
+	group := Group{
+		Name:       name,
+		Metric:     aggregateResourceMetrics(Metrics...),
+		Failures:   Failures,
+		Operations: Operations,
+	}
+	// aggregateResourceMetrics counts records, but a record may already
+	// stand for multiple resources
+	group.Metric.NumCalls = 0
+	for _, metric := range Metrics {
+		group.Metric.NumCalls += metric.NumCalls
+	}
+	group.Metric.TotalTime = Cumulative
+
+	if len(Durations) > 0 {
+		sort.Float64s(Durations)
+		group.MaxTime = Durations[len(Durations)-1]
+		group.MeanTime = Cumulative / float64(len(Durations))
+		group.P95Time = Percentile(Durations, 95)
+	}
+	return group
+}
+
+// Number of resources per operation, e.g. "Create:3 Modify:1". Operations
+// are listed in a fixed order.
+func (g Group) OperationMix() string {
+	Ops := []Operation{}
+	for op := range g.Operations {
+		Ops = append(Ops, op)
+	}
+	sort.Slice(Ops, func(i int, j int) bool { return Ops[i] < Ops[j] })
+
+	result := []string{}
+	for _, op := range Ops {
+		result = append(result, fmt.Sprintf("%v:%v", op, g.Operations[op]))
+	}
+	return strings.Join(result, " ")
+}
@@ -0,0 +1,96 @@
+package tfprofile
+
+import (
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/config"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/labels"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupKey(t *testing.T) {
+	tests := []struct {
+		resource string
+		provider string
+		by       string
+		key      string
+	}{
+		{"aws_iam_role.a", "", GroupByType, "aws_iam_role"},
+		{"module.x.data.aws_region.current", "", GroupByType, "data.aws_region"},
+		{"aws_iam_role.a", "", GroupByProvider, "aws"},
+		{"aws_iam_role.a", "hashicorp/aws", GroupByProvider, "hashicorp/aws"},
+		{"helm_release.b", "", GroupByProvider, "helm"},
+		{"aws_iam_role.a", "", GroupByModule, "(root)"},
+		{`module.a["x.y"].module.b.aws_iam_role.a[0]`, "", GroupByModule, `module.a["x.y"].module.b`},
+		{`module.a["x.y"].module.b.aws_iam_role.a[0]`, "", GroupByTopModule, "module.a"},
+		{"[network] module.vpc.aws_vpc.this", "", GroupByTopModule, "[network] module.vpc"},
+		{"[network] aws_vpc.this", "", GroupByModule, "[network] (root)"},
+	}
+	for _, test := range tests {
+		key := GroupKey(test.resource, ResourceMetric{Provider: test.provider}, test.by)
+		assert.Equal(t, test.key, key, test.resource)
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{
+		"aws_iam_role.a[0]":  {NumCalls: 1, TotalTime: 1000, ModificationCompletedIndex: 0, Operation: Create, AfterStatus: Created},
+		"aws_iam_role.a[1]":  {NumCalls: 1, TotalTime: 3000, ModificationCompletedIndex: 1, Operation: Create, AfterStatus: Created},
+		"aws_iam_role.b":     {NumCalls: 1, TotalTime: 8000, ModificationCompletedIndex: 2, Operation: Modify, AfterStatus: Failed},
+		"aws_iam_role.c":     {NumCalls: 1, TotalTime: -1, ModificationCompletedIndex: -1, Operation: None, AfterStatus: Created},
+		"helm_release.chart": {NumCalls: 1, TotalTime: 60000, ModificationCompletedIndex: 3, Operation: Create, AfterStatus: Created},
+	}}
+
+	groups, err := GroupBy(log, GroupByType)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(groups))
+	assert.Equal(t, "helm_release", groups[0].Name)
+
+	iam := groups[1]
+	assert.Equal(t, "aws_iam_role", iam.Name)
+	assert.Equal(t, 4, iam.Metric.NumCalls)
+	assert.Equal(t, float64(12000), iam.Metric.TotalTime)
+	assert.Equal(t, float64(8000), iam.MaxTime)
+	assert.Equal(t, float64(4000), iam.MeanTime)
+	assert.Equal(t, float64(8000), iam.P95Time)
+	assert.Equal(t, 1, iam.Failures)
+	assert.Equal(t, "None:1 Create:2 Modify:1", iam.OperationMix())
+
+	_, err = GroupBy(log, "color")
+	assert.NotNil(t, err)
+}
+
+func TestGroupByLabel(t *testing.T) {
+	t.Cleanup(ResetLabelRules)
+	err := AddLabelRules([]LabelRule{{Match: "module.network*", Labels: map[string]string{"team": "netops"}}})
+	assert.Nil(t, err)
+
+	log := ParsedLog{Resources: map[string]ResourceMetric{
+		"module.network.aws_vpc.this":          {NumCalls: 1, TotalTime: 600000, ModificationCompletedIndex: 0},
+		"module.network.aws_subnet.private[*]": {NumCalls: 3, TotalTime: 240000, ModificationCompletedIndex: 1},
+		"aws_iam_role.r":                       {NumCalls: 1, TotalTime: 1000, ModificationCompletedIndex: 2},
+	}}
+	Groups, err := GroupBy(log, "label:team")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(Groups))
+	assert.Equal(t, "netops", Groups[0].Name)
+	assert.Equal(t, 4, Groups[0].Metric.NumCalls)
+	assert.Equal(t, float64(840000), Groups[0].Metric.TotalTime)
+	assert.Equal(t, NoLabel, Groups[1].Name)
+
+	_, err = GroupBy(log, "labels")
+	assert.NotNil(t, err)
+}
+
+func TestPercentile(t *testing.T) {
+	assert.Equal(t, float64(0), Percentile([]float64{}, 95))
+	assert.Equal(t, float64(5), Percentile([]float64{5}, 95))
+	values := []float64{}
+	for i := 1; i <= 100; i++ {
+		values = append(values, float64(i))
+	}
+	assert.Equal(t, float64(95), Percentile(values, 95))
+	assert.Equal(t, float64(50), Percentile(values, 50))
+}
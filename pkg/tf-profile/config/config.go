@@ -0,0 +1,71 @@
+package tfprofile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration file for tf-profile, passed with --config. E.g:
+//
+//	rules:
+//	  - name: hooks
+//	    pattern: '^>>> custom hook (?P<resource>\S+) finished in (?P<duration>\S+)$'
+//	    event: completed
+//	    phase: apply
+//	labels:
+//	  - match: 'module.network*'
+//	    labels: {team: netops}
+type Config struct {
+	Rules  []Rule      `yaml:"rules"`
+	Labels []LabelRule `yaml:"labels"`
+}
+
+// A custom rule to recognize lines that Terraform does not print itself,
+// e.g. lines printed by wrapper scripts. The pattern is a regex with named
+// captures:
+//   - resource (required): name of the resource, as shown in the output.
+//   - duration (optional): duration of the operation, e.g. "12s" or "1m2s".
+//   - event (optional): one of "started", "completed" or "failed".
+//
+// If the pattern has no event capture, the Event field is used instead.
+type Rule struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+	Event   string `yaml:"event"`
+	// Phase of the run in which the rule is active: refresh, plan or apply.
+	Phase string `yaml:"phase"`
+	// Operation to record for the resource, e.g. Create. Default: None
+	Operation string `yaml:"operation"`
+}
+
+// Labels for the resources whose address matches a glob (`*` matches
+// anything, the whole address must match) or a regex (any part of the
+// address may match). Exactly one of Match and Regex is set.
+type LabelRule struct {
+	Match  string            `yaml:"match"`
+	Regex  string            `yaml:"regex"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+// Read a configuration file. Unknown keys are reported as an error, to catch
+// typos early.
+func LoadConfig(file string) (Config, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config := Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&config)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("Unable to read config file %v: %v", file, err)
+	}
+	return config, nil
+}
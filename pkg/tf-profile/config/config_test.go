@@ -0,0 +1,47 @@
+package tfprofile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	config, err := LoadConfig("../../../test/custom_rules.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(config.Rules))
+	assert.Equal(t, Rule{
+		Name:    "hooks",
+		Pattern: `^>>> custom hook (?P<resource>\S+) finished in (?P<duration>\S+)$`,
+		Event:   "completed",
+		Phase:   "apply",
+	}, config.Rules[0])
+	assert.Equal(t, "create", config.Rules[1].Operation)
+}
+
+func TestLoadInvalidConfig(t *testing.T) {
+	_, err := LoadConfig("does-not-exist.yaml")
+	assert.NotNil(t, err)
+
+	// Unknown keys are rejected
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(file, []byte("rules:\n  - name: a\n    patern: b\n"), 0644)
+	_, err = LoadConfig(file)
+	assert.NotNil(t, err)
+
+	// An empty file is a valid config
+	os.WriteFile(file, []byte(""), 0644)
+	config, err := LoadConfig(file)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(config.Rules))
+}
+
+func TestLoadLabels(t *testing.T) {
+	config, err := LoadConfig("../../../test/labels.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(config.Labels))
+	assert.Equal(t, LabelRule{Match: "module.core*", Labels: map[string]string{"team": "platform"}}, config.Labels[0])
+	assert.Equal(t, `module\.dbt`, config.Labels[2].Regex)
+}
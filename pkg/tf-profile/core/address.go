@@ -0,0 +1,94 @@
+package tfprofile
+
+import "strings"
+
+// Split a resource address into its dot-separated parts, ignoring dots inside
+// instance keys. For example, `module.a["x.y"].aws_s3_bucket.b[0]` results in
+// [`module`, `a["x.y"]`, `aws_s3_bucket`, `b[0]`].
+func SplitAddress(address string) []string {
+	parts := []string{}
+	current := strings.Builder{}
+	inKey := false
+	inString := false
+
+	for _, c := range address {
+		switch {
+		case c == '"' && inKey:
+			inString = !inString
+		case c == '[' && !inString:
+			inKey = true
+		case c == ']' && !inString:
+			inKey = false
+		case c == '.' && !inKey:
+			parts = append(parts, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteRune(c)
+	}
+	return append(parts, current.String())
+}
+
+// Split an address into the modules it is nested in and the resource within
+// the deepest module. For example, `module.a.module.b["x"].aws_s3_bucket.c[0]`
+// results in [`module.a`, `module.b["x"]`] and `aws_s3_bucket.c[0]`.
+func SplitModules(address string) ([]string, string) {
+	parts := SplitAddress(address)
+	modules := []string{}
+	idx := 0
+	for ; idx+2 < len(parts) && parts[idx] == "module"; idx += 2 {
+		modules = append(modules, parts[idx]+"."+parts[idx+1])
+	}
+	return modules, strings.Join(parts[idx:], ".")
+}
+
+// Remove the instance key from one part of an address, e.g. `b[0]` => `b`
+func StripInstanceKey(part string) string {
+	return strings.Split(part, "[")[0]
+}
+
+// Extract the resource type from an address. For example,
+// `module.a["x"].aws_s3_bucket.b[0]` will return "aws_s3_bucket" and
+// `data.aws_region.current` will return "aws_region".
+func ResourceType(address string) string {
+	_, address = SplitUnit(address)
+	parts := SplitAddress(address)
+	if len(parts) < 2 {
+		return address
+	}
+	return StripInstanceKey(parts[len(parts)-2])
+}
+
+// Returns true if the address is that of a data source, e.g.
+// `module.a.data.aws_region.current`
+func IsDataSource(address string) bool {
+	_, address = SplitUnit(address)
+	parts := SplitAddress(address)
+	if len(parts) < 3 || parts[len(parts)-3] != "data" {
+		return false
+	}
+	// Not a module called "data", e.g. `module.data.aws_s3_bucket.b`
+	return len(parts) == 3 || parts[len(parts)-4] != "module"
+}
+
+// Address of a resource in a Terragrunt unit, e.g. "[vpc] aws_vpc.this".
+// This is how Terragrunt prefixes the output of its units.
+func UnitAddress(unit string, address string) string {
+	if unit == "" {
+		return address
+	}
+	return "[" + unit + "] " + address
+}
+
+// Split an address created by UnitAddress into the unit and the address
+// within that unit. The unit is empty for addresses without a unit.
+func SplitUnit(address string) (string, string) {
+	if !strings.HasPrefix(address, "[") {
+		return "", address
+	}
+	end := strings.Index(address, "] ")
+	if end < 0 {
+		return "", address
+	}
+	return address[1:end], address[end+2:]
+}
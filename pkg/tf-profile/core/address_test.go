@@ -0,0 +1,58 @@
+package tfprofile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitAddress(t *testing.T) {
+	assert.Equal(t, []string{"aws_s3_bucket", "b"}, SplitAddress("aws_s3_bucket.b"))
+	assert.Equal(t,
+		[]string{"module", `a["x.y"]`, "aws_s3_bucket", "b[0]"},
+		SplitAddress(`module.a["x.y"].aws_s3_bucket.b[0]`),
+	)
+}
+
+func TestSplitModules(t *testing.T) {
+	Modules, Resource := SplitModules(`module.a.module.b["x.y"].aws_s3_bucket.c[0]`)
+	assert.Equal(t, []string{"module.a", `module.b["x.y"]`}, Modules)
+	assert.Equal(t, "aws_s3_bucket.c[0]", Resource)
+
+	Modules, Resource = SplitModules("module.a.data.aws_region.current")
+	assert.Equal(t, []string{"module.a"}, Modules)
+	assert.Equal(t, "data.aws_region.current", Resource)
+
+	Modules, Resource = SplitModules("aws_iam_role.r")
+	assert.Equal(t, []string{}, Modules)
+	assert.Equal(t, "aws_iam_role.r", Resource)
+}
+
+func TestResourceType(t *testing.T) {
+	assert.Equal(t, "aws_s3_bucket", ResourceType("aws_s3_bucket.b"))
+	assert.Equal(t, "aws_s3_bucket", ResourceType(`module.a["x.y"].aws_s3_bucket.b[0]`))
+	assert.Equal(t, "aws_region", ResourceType("data.aws_region.current"))
+	assert.Equal(t, "foo", ResourceType("foo"))
+}
+
+func TestIsDataSource(t *testing.T) {
+	assert.True(t, IsDataSource("data.aws_region.current"))
+	assert.True(t, IsDataSource(`module.a["data"].data.aws_region.current`))
+	assert.False(t, IsDataSource("module.data.aws_s3_bucket.b"))
+	assert.False(t, IsDataSource("aws_s3_bucket.b"))
+}
+
+func TestUnitAddress(t *testing.T) {
+	assert.Equal(t, "[live/vpc] aws_vpc.this", UnitAddress("live/vpc", "aws_vpc.this"))
+	assert.Equal(t, "aws_vpc.this", UnitAddress("", "aws_vpc.this"))
+
+	Unit, Address := SplitUnit(`[live/vpc] module.a["x"].aws_subnet.b`)
+	assert.Equal(t, "live/vpc", Unit)
+	assert.Equal(t, `module.a["x"].aws_subnet.b`, Address)
+
+	Unit, Address = SplitUnit("aws_vpc.this")
+	assert.Equal(t, "", Unit)
+	assert.Equal(t, "aws_vpc.this", Address)
+
+	assert.Equal(t, "aws_subnet", ResourceType("[live/vpc] aws_subnet.b[0]"))
+}
@@ -1,12 +1,22 @@
 package tfprofile
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type (
-	LineParseError        struct{ Msg string }
-	ResourceNotFoundError struct{ Resource string }
+	LineParseError         struct{ Msg string }
+	ResourceNotFoundError  struct{ Resource string }
+	UnrecognizedLinesError struct{ Lines []UnrecognizedLine }
 )
 
+// A line that looks like a Terraform event, but was not recognized
+type UnrecognizedLine struct {
+	Number int
+	Line   string
+}
+
 func (e *LineParseError) Error() string {
 	return e.Msg
 }
@@ -14,3 +24,12 @@ func (e *LineParseError) Error() string {
 func (e *ResourceNotFoundError) Error() string {
 	return fmt.Sprintf("Unable to find resource %v in log.", e.Resource)
 }
+
+func (e *UnrecognizedLinesError) Error() string {
+	msg := strings.Builder{}
+	msg.WriteString(fmt.Sprintf("Found %v unrecognized line(s) in strict mode:\n", len(e.Lines)))
+	for _, l := range e.Lines {
+		msg.WriteString(fmt.Sprintf("  line %v: %v\n", l.Number, strings.TrimSpace(l.Line)))
+	}
+	return strings.TrimSuffix(msg.String(), "\n")
+}
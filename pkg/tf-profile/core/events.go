@@ -0,0 +1,88 @@
+package tfprofile
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// Types of events emitted while parsing a log
+	ResourceStarted   EventType = 0
+	ResourceCompleted EventType = 1
+	ResourceFailed    EventType = 2
+	PlanDecision      EventType = 3
+	PhaseChanged      EventType = 4
+	Summary           EventType = 5
+)
+
+type (
+	EventType int
+
+	// Something that happened in a Terraform log, e.g. a resource that
+	// started being created. Which fields are set depends on the type.
+	Event struct {
+		Type EventType
+		// Line number (starting from 1) of the line that caused the event
+		Line int
+		// Time at which the line was logged, if known (e.g. from a CI
+		// timestamp, see parser/ci.go). Zero for lines without a timestamp.
+		Time time.Time
+		// Phase of the run the event belongs to
+		Phase Phase
+		// Terragrunt unit that printed the line, empty for plain Terraform logs
+		Unit string
+		// ResourceStarted, ResourceCompleted, ResourceFailed, PlanDecision
+		Resource  string
+		Operation Operation
+		// ResourceCompleted: duration in milliseconds
+		Duration float64
+		// PlanDecision: why a resource is replaced (tainted, requested, forced)
+		ReplaceReason string
+		// Summary: the counts reported by Terraform
+		Counts ChangeCounts
+	}
+)
+
+// Record an event. Events are collected by a Stream after every line.
+func (log *ParsedLog) Emit(e Event) {
+	log.pendingEvents = append(log.pendingEvents, e)
+}
+
+// Return the events recorded since the last call and forget them
+func (log *ParsedLog) TakeEvents() []Event {
+	events := log.pendingEvents
+	log.pendingEvents = nil
+	return events
+}
+
+func (t EventType) String() string {
+	switch t {
+	case ResourceStarted:
+		return "ResourceStarted"
+	case ResourceCompleted:
+		return "ResourceCompleted"
+	case ResourceFailed:
+		return "ResourceFailed"
+	case PlanDecision:
+		return "PlanDecision"
+	case PhaseChanged:
+		return "PhaseChanged"
+	case Summary:
+		return "Summary"
+	default:
+		return fmt.Sprintf("%d (unknown)", int(t))
+	}
+}
+
+func (p Phase) String() string {
+	switch p {
+	case RefreshPhase:
+		return "refresh"
+	case PlanPhase:
+		return "plan"
+	case ApplyPhase:
+		return "apply"
+	default:
+		return fmt.Sprintf("%d (unknown)", int(p))
+	}
+}
@@ -0,0 +1,59 @@
+package tfprofile
+
+// Record a completed provider RPC (e.g. ApplyResourceChange) that took the
+// given duration in milliseconds. Calls that could not be attributed to a
+// resource (empty Resource) only count towards the totals per RPC.
+func (log *ParsedLog) AddProviderCall(Resource string, RPC string, Duration float64) {
+	if log.ProviderRPCs == nil {
+		log.ProviderRPCs = map[string]ProviderRPC{}
+	}
+	rpc := log.ProviderRPCs[RPC]
+	rpc.Calls += 1
+	rpc.TotalTime += Duration
+	log.ProviderRPCs[RPC] = rpc
+
+	if Resource == "" {
+		return
+	}
+	log.RegisterNewResource(Resource)
+	metric := log.Resources[Resource]
+	metric.ProviderCalls += 1
+	metric.ProviderTime += Duration
+	log.Resources[Resource] = metric
+}
+
+// Record an HTTP request sent by a provider for a resource. A retry is a
+// request sent after a throttled or failed response.
+func (log *ParsedLog) AddHTTPRequest(Resource string, Retry bool) {
+	if Resource == "" {
+		return
+	}
+	log.RegisterNewResource(Resource)
+	metric := log.Resources[Resource]
+	metric.HTTPRequests += 1
+	if Retry {
+		metric.HTTPRetries += 1
+	}
+	log.Resources[Resource] = metric
+}
+
+// Record the status code of an HTTP response received by a provider for a resource
+func (log *ParsedLog) AddHTTPResponse(Resource string, StatusCode int) {
+	if Resource == "" {
+		return
+	}
+	log.RegisterNewResource(Resource)
+	metric := log.Resources[Resource]
+	if StatusCode == 429 {
+		metric.HTTPThrottled += 1
+	} else if StatusCode >= 500 {
+		metric.HTTPServerErrors += 1
+	}
+	log.Resources[Resource] = metric
+}
+
+// Returns true for HTTP status codes after which providers retry a request:
+// 429 (throttling) and 5xx (server errors)
+func IsRetryableStatus(StatusCode int) bool {
+	return StatusCode == 429 || StatusCode >= 500
+}
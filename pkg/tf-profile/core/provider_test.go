@@ -0,0 +1,35 @@
+package tfprofile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderCalls(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+	log.AddProviderCall("a", "ApplyResourceChange", 1500)
+	log.AddProviderCall("", "ApplyResourceChange", 500)
+	log.AddHTTPRequest("a", false)
+	log.AddHTTPResponse("a", 429)
+	log.AddHTTPRequest("a", true)
+	log.AddHTTPResponse("a", 502)
+	log.AddHTTPRequest("", false)
+
+	assert.Equal(t, ProviderRPC{Calls: 2, TotalTime: 2000}, log.ProviderRPCs["ApplyResourceChange"])
+	assert.Equal(t, 1, len(log.Resources))
+	metric := log.Resources["a"]
+	assert.Equal(t, 1, metric.ProviderCalls)
+	assert.Equal(t, float64(1500), metric.ProviderTime)
+	assert.Equal(t, 2, metric.HTTPRequests)
+	assert.Equal(t, 1, metric.HTTPRetries)
+	assert.Equal(t, 1, metric.HTTPThrottled)
+	assert.Equal(t, 1, metric.HTTPServerErrors)
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	assert.True(t, IsRetryableStatus(429))
+	assert.True(t, IsRetryableStatus(503))
+	assert.False(t, IsRetryableStatus(200))
+	assert.False(t, IsRetryableStatus(404))
+}
@@ -0,0 +1,93 @@
+package tfprofile
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	// How Terraform describes each kind of change in its summary lines
+	PlanLabels  = [5]string{"to import", "to add", "to change", "to destroy", "to forget"}
+	ApplyLabels = [5]string{"imported", "added", "changed", "destroyed", "forgotten"}
+)
+
+// Count the planned changes per kind, the way Terraform does in its
+// "Plan: ..." line. A replacement counts as one add and one destroy.
+func (log ParsedLog) PlannedCounts() ChangeCounts {
+	counts := ChangeCounts{}
+	for _, change := range log.PlannedChanges {
+		if change.Imported {
+			counts.Import += 1
+		}
+		switch change.Operation {
+		case Create:
+			counts.Add += 1
+		case Modify:
+			counts.Change += 1
+		case Destroy:
+			counts.Destroy += 1
+		case Replace:
+			counts.Add += 1
+			counts.Destroy += 1
+		case Forget:
+			counts.Forget += 1
+		}
+	}
+	return counts
+}
+
+// Count the changes completed during the apply. Terraform prints nothing when
+// it forgets a resource, so those are counted from the plan.
+func (log ParsedLog) AppliedCounts() ChangeCounts {
+	counts := log.ApplyCounts
+	for _, metric := range log.Resources {
+		if metric.Operation == Forget {
+			counts.Forget += metric.NumCalls
+		}
+	}
+	return counts
+}
+
+// Compare the summary lines printed by Terraform with what was parsed from the
+// rest of the log. Returns a human-readable message for every disagreement.
+// Disagreements usually indicate lines that tf-profile failed to recognize.
+func (log ParsedLog) SummaryMismatches() []string {
+	result := []string{}
+	if log.PlanSummary != nil {
+		result = append(result, compareCounts("Plan", PlanLabels, *log.PlanSummary, log.PlannedCounts())...)
+	}
+	if log.ApplySummary != nil {
+		result = append(result, compareCounts("Apply", ApplyLabels, *log.ApplySummary, log.AppliedCounts())...)
+	}
+	return result
+}
+
+func compareCounts(phase string, labels [5]string, reported ChangeCounts, parsed ChangeCounts) []string {
+	result := []string{}
+	r := reported.asArray()
+	p := parsed.asArray()
+	for idx := range labels {
+		if r[idx] != p[idx] {
+			msg := fmt.Sprintf("%v summary reports %v %v, but tf-profile parsed %v.", phase, r[idx], labels[idx], p[idx])
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+// Format change counts the way Terraform does, e.g. "3 to add, 1 to change, 4 to destroy".
+// Imports and forgotten resources are only included when there are any.
+func (c ChangeCounts) Format(labels [5]string) string {
+	parts := []string{}
+	for idx, count := range c.asArray() {
+		optional := idx == 0 || idx == 4
+		if count > 0 || !optional {
+			parts = append(parts, fmt.Sprintf("%v %v", count, labels[idx]))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (c ChangeCounts) asArray() [5]int {
+	return [5]int{c.Import, c.Add, c.Change, c.Destroy, c.Forget}
+}
@@ -0,0 +1,30 @@
+package tfprofile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummaryMismatches(t *testing.T) {
+	log := ParsedLog{
+		Resources: map[string]ResourceMetric{},
+		PlannedChanges: map[string]PlannedChange{
+			"a": {Operation: Create},
+			"b": {Operation: Replace},
+			"c": {Operation: Modify, Imported: true},
+		},
+		PlanSummary:  &ChangeCounts{Import: 1, Add: 2, Change: 1, Destroy: 1},
+		ApplySummary: &ChangeCounts{Import: 1, Add: 2, Change: 1, Destroy: 1},
+		ApplyCounts:  ChangeCounts{Import: 1, Add: 1, Change: 1, Destroy: 1},
+	}
+	assert.Equal(t, []string{"Apply summary reports 2 added, but tf-profile parsed 1."}, log.SummaryMismatches())
+
+	log.ApplyCounts.Add = 2
+	assert.Equal(t, []string{}, log.SummaryMismatches())
+}
+
+func TestFormatChangeCounts(t *testing.T) {
+	assert.Equal(t, "3 to add, 1 to change, 4 to destroy", ChangeCounts{Add: 3, Change: 1, Destroy: 4}.Format(PlanLabels))
+	assert.Equal(t, "1 imported, 0 added, 0 changed, 0 destroyed, 2 forgotten", ChangeCounts{Import: 1, Forget: 2}.Format(ApplyLabels))
+}
@@ -1,11 +1,20 @@
 package tfprofile
 
-import "regexp"
+import (
+	"regexp"
+	"strings"
+)
+
+// regex to detect ANSI terminal formatting directives (https://stackoverflow.com/a/14693789)
+var terminalFormatting = regexp.MustCompile(`(?:\x1B[@-_]|[\x80-\x9F])[0-?]*[ -/]*[@-~]`)
 
 // Terraform inserts a lot of formatting strings into its output when
 // -no-color is not specified. This function removes all of those
 func RemoveTerminalFormatting(in string) string {
-	// regex to detect ANSI terminal formatting directives (https://stackoverflow.com/a/14693789)
-	re := regexp.MustCompile(`(?:\x1B[@-_]|[\x80-\x9F])[0-?]*[ -/]*[@-~]`)
-	return re.ReplaceAllString(in, "")
+	// Fast path for logs without formatting: every directive starts with
+	// ESC or a C1 control character (encoded as 0xC2 0x80-0x9F in UTF-8)
+	if strings.IndexByte(in, '\x1B') < 0 && strings.IndexByte(in, '\xC2') < 0 {
+		return in
+	}
+	return terminalFormatting.ReplaceAllString(in, "")
 }
@@ -0,0 +1,13 @@
+package tfprofile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveTerminalFormatting(t *testing.T) {
+	assert.Equal(t, "aws_ssm_parameter.p1: Creating...", RemoveTerminalFormatting("aws_ssm_parameter.p1: Creating..."))
+	assert.Equal(t, "aws_ssm_parameter.p1: Creating...", RemoveTerminalFormatting("\x1b[0m\x1b[1maws_ssm_parameter.p1: Creating...\x1b[0m"))
+	assert.Equal(t, "module.a[\"é\"]", RemoveTerminalFormatting("module.a[\"é\"]"))
+}
@@ -23,11 +23,34 @@ const (
 	Replace    Operation = 3
 	Destroy    Operation = 4
 	MultipleOp Operation = 5
+	Read       Operation = 6
+	Import     Operation = 7
+	Move       Operation = 8
+	Forget     Operation = 9
+
+	// Drift detected during refresh
+	NoDrift       Drift = 0
+	DriftChanged  Drift = 1
+	DriftDeleted  Drift = 2
+	MultipleDrift Drift = 3
+
+	// Phase of a Terraform run
+	RefreshPhase Phase = 0
+	PlanPhase    Phase = 1
+	ApplyPhase   Phase = 2
+
+	// Tool that printed the log
+	UnknownTool Tool = 0
+	Terraform   Tool = 1
+	OpenTofu    Tool = 2
 )
 
 type (
 	Status    int
 	Operation int
+	Drift     int
+	Phase     int
+	Tool      int
 
 	// Data structure that holds all metrics for one particular resource
 	ResourceMetric struct {
@@ -51,6 +74,94 @@ type (
 		DesiredStatus Status
 		// Operation to perform to go from BeforeStatus to DesiredStatus
 		Operation Operation
+		// Resource was the Nth to be refreshed (or read, for data sources)
+		RefreshIndex int
+		// (Global) event index of when the refresh happened. Comparable
+		// with ModificationStartedEvent and ModificationCompletedEvent.
+		RefreshEvent int
+		// Changes made outside of Terraform, as reported after refreshing
+		Drift Drift
+		// Previous address of the resource, if it was moved
+		MovedFrom string
+		// Terragrunt unit the resource belongs to, empty for plain Terraform logs
+		Unit string
+		// Provider API calls made for the resource, from TF_LOG=trace or
+		// TF_LOG=json logs: number of RPCs, their total duration (in ms),
+		// HTTP requests sent, retries and throttled (429) or failed (5xx) responses
+		ProviderCalls    int
+		ProviderTime     float64
+		HTTPRequests     int
+		HTTPRetries      int
+		HTTPThrottled    int
+		HTTPServerErrors int
+		// Provider that manages the resource, e.g. "hashicorp/aws". Only known
+		// from a JSON plan or state file.
+		Provider string
+		// Durations of the individual instances of an aggregated resource,
+		// zero for resources that were not aggregated
+		Distribution Distribution
+	}
+
+	// Distribution of the durations (in ms) of the instances of a resource
+	// created by `count` or `for_each`. Only instances that were modified count.
+	Distribution struct {
+		// Number of instances with a duration
+		Count  int
+		Min    float64
+		Max    float64
+		Mean   float64
+		Median float64
+		P90    float64
+		P99    float64
+		StdDev float64
+		// Address of the instance that took longest
+		Slowest string
+	}
+
+	// Calls of one provider RPC (e.g. ApplyResourceChange) in a trace log
+	ProviderRPC struct {
+		Calls int
+		// Total duration of all calls, in milliseconds
+		TotalTime float64
+	}
+
+	// A single attribute change in the body of a plan block
+	AttributeChange struct {
+		// Name of the attribute. Nested attributes are joined with dots, e.g. "ingress.cidr_blocks"
+		Name string
+		// Symbol used by Terraform to indicate the change: "+", "-", "~", "-/+" or "+/-"
+		Action string
+		// Attribute is marked with "# forces replacement"
+		ForcesReplacement bool
+		// New value is "(known after apply)"
+		KnownAfterApply bool
+		// Value is "(sensitive value)"
+		Sensitive bool
+	}
+
+	// Everything Terraform plans to do with a resource, as described by its plan block
+	PlannedChange struct {
+		// Operation announced in the header of the plan block
+		Operation Operation
+		// Why a resource must be replaced: "tainted", "requested" or "forced"
+		ReplaceReason string
+		// Resource will be imported (possibly combined with other changes)
+		Imported bool
+		// Reason for the change as reported in a JSON plan, e.g.
+		// "delete_because_no_resource_config". Empty if not known.
+		ActionReason string
+		// Changed attributes in the body of the plan block
+		Attributes []AttributeChange
+	}
+
+	// Number of resources per kind of change, as reported by Terraform in
+	// its summary lines ("Plan: 1 to add, ...") or as counted by tf-profile
+	ChangeCounts struct {
+		Import  int
+		Add     int
+		Change  int
+		Destroy int
+		Forget  int
 	}
 
 	// Parsing a log results in a map of resource names and their metrics
@@ -59,12 +170,44 @@ type (
 		CurrentModificationStartedIndex int
 		CurrentModificationEndedIndex   int
 		CurrentEvent                    int
+		CurrentRefreshIndex             int
+		// Phase of the run currently being parsed
+		CurrentPhase Phase
+		// Resource described by the plan block currently being parsed
+		CurrentPlanResource string
+		// Nested blocks opened in the body of the current plan block
+		CurrentPlanPath []string
+		// End marker of a heredoc string in the current plan block
+		CurrentPlanHeredoc string
 		// Stage information
 		ContainsRefresh bool
 		ContainsPlan    bool
 		ContainsApply   bool
 		// Resources detected
 		Resources map[string]ResourceMetric
+		// Plan blocks detected, by resource
+		PlannedChanges map[string]PlannedChange
+		// Summary lines printed by Terraform, nil if not present in the log
+		PlanSummary  *ChangeCounts
+		ApplySummary *ChangeCounts
+		// Changes completed during the apply, as counted while parsing
+		ApplyCounts ChangeCounts
+		// Events emitted while parsing the current line, see events.go
+		pendingEvents []Event
+		// Terragrunt units found in the log, in order of appearance. Resources
+		// of a unit are stored as "[unit] address", see UnitAddress.
+		Units []string
+		// Tool that printed the log and its version (e.g. "1.6.2"), detected
+		// from banners and version lines. Empty version if not printed.
+		Tool        Tool
+		ToolVersion string
+		// Log contains TF_LOG=trace (or json) output, see ProviderCalls
+		ContainsTrace bool
+		// Provider RPCs found in the trace output, by RPC name
+		ProviderRPCs map[string]ProviderRPC
+		// State before the run was added (see tfjson), so BeforeStatus is
+		// known instead of inferred from the log
+		ContainsState bool
 	}
 )
 
@@ -173,6 +316,91 @@ func (log ParsedLog) SetOperation(Resource string, Op Operation) error {
 	return nil
 }
 
+func (log ParsedLog) SetRefreshIndex(Resource string, Idx int) error {
+	metric, found := log.Resources[Resource]
+	if found == false {
+		return &ResourceNotFoundError{Resource}
+	}
+	metric.RefreshIndex = Idx
+	log.Resources[Resource] = metric
+	return nil
+}
+
+func (log ParsedLog) SetRefreshEvent(Resource string, Idx int) error {
+	metric, found := log.Resources[Resource]
+	if found == false {
+		return &ResourceNotFoundError{Resource}
+	}
+	metric.RefreshEvent = Idx
+	log.Resources[Resource] = metric
+	return nil
+}
+
+func (log ParsedLog) SetDrift(Resource string, Drift Drift) error {
+	metric, found := log.Resources[Resource]
+	if found == false {
+		return &ResourceNotFoundError{Resource}
+	}
+	metric.Drift = Drift
+	log.Resources[Resource] = metric
+	return nil
+}
+
+func (log ParsedLog) SetMovedFrom(Resource string, Previous string) error {
+	metric, found := log.Resources[Resource]
+	if found == false {
+		return &ResourceNotFoundError{Resource}
+	}
+	metric.MovedFrom = Previous
+	log.Resources[Resource] = metric
+	return nil
+}
+
+// Record the operation (and reason, for replacements) announced in the header
+// of a plan block and emit a PlanDecision event. Any attributes seen earlier
+// for this resource are kept.
+func (log *ParsedLog) SetPlannedChange(Resource string, Op Operation, ReplaceReason string) {
+	if log.PlannedChanges == nil {
+		log.PlannedChanges = map[string]PlannedChange{}
+	}
+	change := log.PlannedChanges[Resource]
+	change.Operation = Op
+	change.ReplaceReason = ReplaceReason
+	log.PlannedChanges[Resource] = change
+	log.Emit(Event{Type: PlanDecision, Resource: Resource, Operation: Op, ReplaceReason: ReplaceReason})
+}
+
+// Mark a resource as planned to be imported
+func (log *ParsedLog) SetPlannedImport(Resource string) {
+	if log.PlannedChanges == nil {
+		log.PlannedChanges = map[string]PlannedChange{}
+	}
+	change := log.PlannedChanges[Resource]
+	change.Imported = true
+	log.PlannedChanges[Resource] = change
+}
+
+// Record a changed attribute in the body of a plan block. If the attribute was
+// seen before (e.g. multiple changed elements of a list), the flags are merged.
+func (log *ParsedLog) AddPlannedAttribute(Resource string, Attribute AttributeChange) {
+	if log.PlannedChanges == nil {
+		log.PlannedChanges = map[string]PlannedChange{}
+	}
+	change := log.PlannedChanges[Resource]
+	for idx, existing := range change.Attributes {
+		if existing.Name == Attribute.Name {
+			existing.ForcesReplacement = existing.ForcesReplacement || Attribute.ForcesReplacement
+			existing.KnownAfterApply = existing.KnownAfterApply || Attribute.KnownAfterApply
+			existing.Sensitive = existing.Sensitive || Attribute.Sensitive
+			change.Attributes[idx] = existing
+			log.PlannedChanges[Resource] = change
+			return
+		}
+	}
+	change.Attributes = append(change.Attributes, Attribute)
+	log.PlannedChanges[Resource] = change
+}
+
 func (log ParsedLog) RegisterNewResource(Resource string) {
 	_, found := (log.Resources)[Resource]
 	if found {
@@ -189,6 +417,9 @@ func (log ParsedLog) RegisterNewResource(Resource string) {
 		AfterStatus:                Created,
 		DesiredStatus:              Created,
 		Operation:                  None,
+		RefreshIndex:               -1, // Not refreshed, will be overwritten
+		RefreshEvent:               -1, // Not refreshed, will be overwritten
+		Drift:                      NoDrift,
 	}
 }
 
@@ -204,6 +435,8 @@ func (s Status) String() string {
 		return "Unknown"
 	case Tainted:
 		return "Tainted"
+	case Multiple:
+		return "Multiple"
 	default:
 		return fmt.Sprintf("%d (unknown)", int(s))
 	}
@@ -223,7 +456,53 @@ func (s Operation) String() string {
 		return "Multiple"
 	case None:
 		return "None"
+	case Read:
+		return "Read"
+	case Import:
+		return "Import"
+	case Move:
+		return "Move"
+	case Forget:
+		return "Forget"
 	default:
 		return fmt.Sprintf("%d (unknown)", int(s))
 	}
 }
+
+func (d Drift) String() string {
+	switch d {
+	case NoDrift:
+		return "None"
+	case DriftChanged:
+		return "Changed"
+	case DriftDeleted:
+		return "Deleted"
+	case MultipleDrift:
+		return "Multiple"
+	default:
+		return fmt.Sprintf("%d (unknown)", int(d))
+	}
+}
+
+func (t Tool) String() string {
+	switch t {
+	case Terraform:
+		return "Terraform"
+	case OpenTofu:
+		return "OpenTofu"
+	default:
+		return "Unknown"
+	}
+}
+
+// Describe the tool that printed the log, e.g. "OpenTofu v1.6.2". Empty if
+// the tool is unknown.
+func (log ParsedLog) ToolDescription() string {
+	if log.Tool == UnknownTool {
+		return ""
+	}
+	if log.ToolVersion == "" {
+		return log.Tool.String()
+	}
+	return fmt.Sprintf("%v v%v", log.Tool, log.ToolVersion)
+}
@@ -0,0 +1,99 @@
+package tfprofile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/parser"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/readers"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// Execute the `tf-profile doctor` command
+func Doctor(args []string) error {
+	var file *bufio.Scanner
+	var err error
+
+	if len(args) == 1 {
+		file, err = FileReader{File: args[0]}.Read()
+	} else {
+		file, err = StdinReader{}.Read()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	tflog, coverage, err := ParseCoverage(file)
+	if err != nil {
+		return err
+	}
+
+	PrintDoctor(os.Stdout, tflog, coverage)
+	return nil
+}
+
+// Print a parse coverage report: detected phases, the number of lines
+// recognized by every parse function and all suspicious unrecognized lines.
+func PrintDoctor(w io.Writer, tflog ParsedLog, coverage Coverage) {
+	headerFmt := color.New(color.FgHiBlue, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgBlue).SprintfFunc()
+
+	fmt.Fprintf(w, "Read %v lines, %v recognized by a parser.\n", coverage.Lines, coverage.Recognized)
+	if tool := tflog.ToolDescription(); tool != "" {
+		fmt.Fprintf(w, "Log printed by %v.\n", tool)
+	}
+	fmt.Fprintln(w)
+
+	phases := table.New("phase", "detected").WithWriter(w)
+	phases.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	phases.AddRow("refresh", tflog.ContainsRefresh)
+	phases.AddRow("plan", tflog.ContainsPlan)
+	phases.AddRow("apply", tflog.ContainsApply)
+	phases.Print()
+	fmt.Fprintln(w)
+
+	parsers := table.New("parser", "lines").WithWriter(w)
+	parsers.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	for _, name := range sortedParsers(coverage.Matched) {
+		parsers.AddRow(name, coverage.Matched[name])
+	}
+	parsers.Print()
+	fmt.Fprintln(w)
+
+	if len(coverage.Unrecognized) == 0 {
+		fmt.Fprintln(w, "No suspicious unrecognized lines found.")
+		return
+	}
+
+	warning := color.New(color.FgYellow).SprintfFunc()
+	fmt.Fprintln(w, warning("Found %v line(s) that look like Terraform events, but were not recognized:", len(coverage.Unrecognized)))
+	unrecognized := table.New("line", "content").WithWriter(w)
+	unrecognized.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	for _, line := range coverage.Unrecognized {
+		unrecognized.AddRow(line.Number, strings.TrimSpace(line.Line))
+	}
+	unrecognized.Print()
+}
+
+// Sort parse functions by the number of lines they recognized (descending),
+// then by name.
+func sortedParsers(matched map[string]int) []string {
+	names := []string{}
+	for name := range matched {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if matched[names[i]] != matched[names[j]] {
+			return matched[names[i]] > matched[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
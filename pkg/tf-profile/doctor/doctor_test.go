@@ -0,0 +1,37 @@
+package tfprofile
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintDoctor(t *testing.T) {
+	log := ParsedLog{ContainsPlan: true, Tool: OpenTofu, ToolVersion: "1.6.2"}
+	coverage := Coverage{
+		Lines:        10,
+		Recognized:   5,
+		Matched:      map[string]int{"parseStartPlan": 1, "parsePlanAttribute": 4},
+		Unrecognized: []UnrecognizedLine{{Number: 7, Line: "a.b: Creation errored after 1s"}},
+	}
+
+	buf := bytes.Buffer{}
+	PrintDoctor(&buf, log, coverage)
+	Out := buf.String()
+
+	assert.Contains(t, Out, "Read 10 lines, 5 recognized by a parser.")
+	assert.Contains(t, Out, "Log printed by OpenTofu v1.6.2.")
+	assert.Contains(t, Out, "a.b: Creation errored after 1s")
+	assert.Less(t, bytes.Index(buf.Bytes(), []byte("parsePlanAttribute")), bytes.Index(buf.Bytes(), []byte("parseStartPlan")))
+}
+
+func TestDoctor(t *testing.T) {
+	err := Doctor([]string{"../../../test/all_operations.log"})
+	assert.Nil(t, err)
+	err = Doctor([]string{"does-not-exist"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,209 @@
+package tfprofile
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/utils"
+)
+
+// Frames of a resource in a flame graph, from the outermost to the innermost:
+// the Terragrunt unit (if any), the modules and the resource itself. For
+// example, "[unit] module.a.aws_s3_bucket.b" => ["[unit]", "module.a", "aws_s3_bucket.b"].
+func flameStack(name string) []string {
+	unit, address := SplitUnit(name)
+	stack := []string{}
+	if unit != "" {
+		stack = append(stack, "["+unit+"]")
+	}
+	modules, resource := SplitModules(address)
+	stack = append(stack, modules...)
+	return append(stack, resource)
+}
+
+// Lines in the "folded stacks" format of Brendan Gregg's FlameGraph tools,
+// e.g. "module.a;module.b;aws_x.y 12000". Every resource is weighted by its
+// duration in ms. Resources that were not modified are left out.
+func FoldedStacks(tflog ParsedLog) []string {
+	lines := []string{}
+	for name, metrics := range tflog.Resources {
+		if int(metrics.TotalTime) <= 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%v %v", strings.Join(flameStack(name), ";"), int(metrics.TotalTime)))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// Print the folded stacks of a log (see FoldedStacks), one per line
+func printFoldedStacks(tflog ParsedLog) (string, error) {
+	output := strings.Join(FoldedStacks(tflog), "\n") + "\n"
+	_, err := fmt.Fprint(os.Stdout, output)
+	if err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// A frame in a flame graph: a module or resource with the cumulative duration
+// of everything in it
+type flameFrame struct {
+	Name     string
+	Weight   float64
+	Children map[string]*flameFrame
+}
+
+// Build the frames of a flame graph. The root frame "all" covers the whole run.
+func buildFlameFrames(tflog ParsedLog) *flameFrame {
+	root := &flameFrame{Name: "all", Children: map[string]*flameFrame{}}
+	for name, metrics := range tflog.Resources {
+		if int(metrics.TotalTime) <= 0 {
+			continue
+		}
+		frame := root
+		frame.Weight += metrics.TotalTime
+		for _, part := range flameStack(name) {
+			if _, ok := frame.Children[part]; !ok {
+				frame.Children[part] = &flameFrame{Name: part, Children: map[string]*flameFrame{}}
+			}
+			frame = frame.Children[part]
+			frame.Weight += metrics.TotalTime
+		}
+	}
+	return root
+}
+
+// Number of levels below (and including) a frame
+func (f *flameFrame) depth() int {
+	deepest := 0
+	for _, child := range f.Children {
+		if d := child.depth(); d > deepest {
+			deepest = d
+		}
+	}
+	return deepest + 1
+}
+
+// Layout of the SVG flame graph, in pixels
+const (
+	flameFrameHeight = 16
+	flameMarginTop   = 40
+	flameMargin      = 10
+	flameCharWidth   = 7
+)
+
+// A rectangle in the SVG flame graph
+type flameRect struct {
+	X, Y, Width float64
+	Fill        string
+	Title       string
+	Label       string
+}
+
+// Lay out all frames as rectangles. Children are drawn on top of their parent,
+// in alphabetical order, with a width proportional to their duration.
+func flameRects(frame *flameFrame, x float64, level int, scale float64, height int, total float64) []flameRect {
+	width := frame.Weight * scale
+	rects := []flameRect{{
+		X:     x,
+		Y:     float64(height - flameMargin - (level+1)*flameFrameHeight),
+		Width: width,
+		Fill:  flameColor(frame.Name),
+		Title: template.HTMLEscapeString(fmt.Sprintf("%v (%v, %.2f%%)", frame.Name, FormatDuration(int(frame.Weight/1000)), 100*frame.Weight/total)),
+		Label: template.HTMLEscapeString(flameLabel(frame.Name, width)),
+	}}
+
+	names := []string{}
+	for name := range frame.Children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child := frame.Children[name]
+		rects = append(rects, flameRects(child, x, level+1, scale, height, total)...)
+		x += child.Weight * scale
+	}
+	return rects
+}
+
+// Text in a frame, truncated to fit. Frames that are too narrow have no text.
+func flameLabel(name string, width float64) string {
+	fits := int(width/flameCharWidth) - 1
+	if fits < 3 {
+		return ""
+	}
+	runes := []rune(name)
+	if len(runes) > fits {
+		return string(runes[:fits-2]) + ".."
+	}
+	return name
+}
+
+// A warm color for a frame, derived from its name so that it is stable
+// between runs
+func flameColor(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	v := h.Sum32()
+	return fmt.Sprintf("rgb(%v,%v,%v)", 205+v%50, (v>>8)%230, (v>>16)%55)
+}
+
+// Render a log as an SVG flame graph of the given width. The height follows
+// from the depth of the module hierarchy, so the height of --size is not
+// used. Frames are colored by name (see flameColor), not by --color-by.
+func printFlameGraph(tflog ParsedLog, w int) (string, error) {
+	if w < 1 {
+		return "", fmt.Errorf("--size must be provided as two positive integers (e.g. '1000,1000')")
+	}
+
+	root := buildFlameFrames(tflog)
+	height := flameMarginTop + root.depth()*flameFrameHeight + flameMargin
+	rects := []flameRect{}
+	if root.Weight > 0 {
+		scale := float64(w-2*flameMargin) / root.Weight
+		rects = flameRects(root, flameMargin, 0, scale, height, root.Weight)
+	}
+
+	Context := map[string]interface{}{
+		"W":           w,
+		"H":           height,
+		"Center":      w / 2,
+		"FrameHeight": flameFrameHeight - 1, // Leave a gap between levels
+		"Title":       "Apply time by module",
+		"Rects":       rects,
+	}
+	template, _ := template.New("flamegraph").Parse(FlameGraphTemplate)
+
+	var output bytes.Buffer
+	err := template.Execute(&output, Context)
+	if err != nil {
+		return "", err
+	}
+	_, err = fmt.Fprint(os.Stdout, output.String())
+	if err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+const FlameGraphTemplate string = `<?xml version="1.0" standalone="no"?>
+<svg version="1.1" width="{{ .W }}" height="{{ .H }}" viewBox="0 0 {{ .W }} {{ .H }}" xmlns="http://www.w3.org/2000/svg">
+<style>
+  text { font-family: Verdana, sans-serif; font-size: 12px; fill: #000000; }
+  g:hover rect { stroke: #000000; stroke-width: 0.5; }
+</style>
+<rect x="0" y="0" width="{{ .W }}" height="{{ .H }}" fill="#ffffff"/>
+<text x="{{ .Center }}" y="24" text-anchor="middle" font-size="17">{{ .Title }}</text>
+{{ range .Rects -}}
+<g><title>{{ .Title }}</title><rect x="{{ printf "%.2f" .X }}" y="{{ .Y }}" width="{{ printf "%.2f" .Width }}" height="{{ $.FrameHeight }}" fill="{{ .Fill }}" rx="2" ry="2"/>
+{{- if .Label }}<text x="{{ printf "%.2f" .X }}" y="{{ .Y }}" dx="3" dy="12">{{ .Label }}</text>{{ end }}</g>
+{{ end -}}
+</svg>
+`
@@ -0,0 +1,81 @@
+package tfprofile
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func flameLog() ParsedLog {
+	return ParsedLog{Resources: map[string]ResourceMetric{
+		"aws_iam_role.r":                           {TotalTime: 2000},
+		"module.a.module.b.aws_s3_bucket.c":        {TotalTime: 12000},
+		`module.a.module.b["x.y"].aws_sqs_queue.q`: {TotalTime: 6000},
+		"module.a.data.aws_region.current":         {TotalTime: 0},
+		"[queue] aws_sqs_queue.jobs":               {TotalTime: 1500.7},
+	}}
+}
+
+func TestFoldedStacks(t *testing.T) {
+	assert.Equal(t, []string{
+		"[queue];aws_sqs_queue.jobs 1500",
+		"aws_iam_role.r 2000",
+		"module.a;module.b;aws_s3_bucket.c 12000",
+		`module.a;module.b["x.y"];aws_sqs_queue.q 6000`,
+	}, FoldedStacks(flameLog()))
+}
+
+func TestFlameFrames(t *testing.T) {
+	Root := buildFlameFrames(flameLog())
+	assert.Equal(t, 21500.7, Root.Weight)
+	assert.Equal(t, float64(18000), Root.Children["module.a"].Weight)
+	assert.Equal(t, 4, Root.depth())
+
+	// Children are laid out next to each other, on top of their parent
+	Rects := flameRects(Root.Children["module.a"], 0, 1, 0.01, 100, 18000)
+	assert.Equal(t, 5, len(Rects)) // module.a, module.b, aws_s3_bucket.c, module.b["x.y"], aws_sqs_queue.q
+	assert.Equal(t, float64(58), Rects[0].Y)
+	assert.Equal(t, float64(42), Rects[1].Y)
+	assert.Equal(t, float64(120), Rects[1].Width)
+	assert.Equal(t, float64(26), Rects[2].Y)
+	assert.Equal(t, float64(120), Rects[3].X) // module.b["x.y"] after module.b
+	assert.Equal(t, "module.b[&#34;x.y&#34;] (6s, 33.33%)", Rects[3].Title)
+
+	assert.Equal(t, "", flameLabel("aws_s3_bucket.c", 20))
+	assert.Equal(t, "aws_s3_bu..", flameLabel("aws_s3_bucket.c", 84))
+	assert.Equal(t, "aws_s3_bucket.c", flameLabel("aws_s3_bucket.c", 200))
+
+	// Multi-byte characters are counted and cut as one character
+	assert.Equal(t, `b["äöüäöü"]`, flameLabel(`b["äöüäöü"]`, 84))
+	assert.Equal(t, `b["äöüäöü..`, flameLabel(`b["äöüäöüäöü"]`, 84))
+	assert.True(t, utf8.ValidString(flameLabel(`b["日本語の鍵"]`, 56)))
+	assert.Equal(t, flameColor("module.a"), flameColor("module.a"))
+}
+
+func TestFlameGraph(t *testing.T) {
+	out, err := printFlameGraph(flameLog(), 1000)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(out, "<?xml"))
+	assert.Nil(t, xml.Unmarshal([]byte(out), new(interface{})))
+	assert.Contains(t, out, `<title>all (21s, 100.00%)</title>`)
+
+	// An empty log is still a valid SVG
+	out, err = printFlameGraph(ParsedLog{}, 1000)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "</svg>")
+
+	_, err = printFlameGraph(flameLog(), 0)
+	assert.NotNil(t, err)
+
+	err = Graph([]string{"../../../test/many_modules.log"}, GraphOptions{W: 1000, H: 600, Format: "folded", Aggregate: true, AggregateLevels: "module", ColorBy: "status"})
+	assert.Nil(t, err)
+	err = Graph([]string{"../../../test/many_modules.log"}, GraphOptions{W: 1000, H: 600, Format: "svg", Aggregate: true, AggregateLevels: "resource", ColorBy: "status"})
+	assert.Nil(t, err)
+	err = Graph([]string{"../../../test/many_modules.log"}, GraphOptions{W: 1000, H: 600, Format: "pdf", Aggregate: true, AggregateLevels: "resource", ColorBy: "status"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,156 @@
+package tfprofile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+// A bar in a Gantt diagram. Start and End are the events at which the
+// modification of the resource started and ended, like in the gnuplot output.
+type ganttTask struct {
+	// Name of the resource within its module and the full resource name
+	Name     string
+	Resource string
+	Start    int
+	End      int
+	Failed   bool
+}
+
+// The bars of the resources in one module
+type ganttSection struct {
+	Name  string
+	Tasks []ganttTask
+}
+
+// Group the resources of a log into one section per module. Tasks are sorted
+// chronologically, sections by their first task. Resources that were never
+// modified are left out. Every bar is at least one event long, so that it
+// remains visible.
+func ganttSections(tflog ParsedLog) []ganttSection {
+	Sections := map[string]*ganttSection{}
+	for resource, metrics := range tflog.Resources {
+		if metrics.ModificationStartedEvent < 0 {
+			continue
+		}
+		_, address := SplitUnit(resource)
+		_, name := SplitModules(address)
+		module := moduleName(resource)
+
+		if _, ok := Sections[module]; !ok {
+			Sections[module] = &ganttSection{Name: module}
+		}
+		End := metrics.ModificationCompletedEvent
+		if End <= metrics.ModificationStartedEvent {
+			End = metrics.ModificationStartedEvent + 1
+		}
+		Sections[module].Tasks = append(Sections[module].Tasks, ganttTask{
+			Name:     name,
+			Resource: resource,
+			Start:    metrics.ModificationStartedEvent,
+			End:      End,
+			Failed:   metrics.AfterStatus == Failed,
+		})
+	}
+
+	Result := []ganttSection{}
+	for _, section := range Sections {
+		sort.Slice(section.Tasks, func(i int, j int) bool {
+			if section.Tasks[i].Start != section.Tasks[j].Start {
+				return section.Tasks[i].Start < section.Tasks[j].Start
+			}
+			return section.Tasks[i].Name < section.Tasks[j].Name
+		})
+		Result = append(Result, *section)
+	}
+	sort.Slice(Result, func(i int, j int) bool {
+		if Result[i].Tasks[0].Start != Result[j].Tasks[0].Start {
+			return Result[i].Tasks[0].Start < Result[j].Tasks[0].Start
+		}
+		return Result[i].Name < Result[j].Name
+	})
+	return Result
+}
+
+// Mermaid ends a task name at ":" and treats "#" and ";" specially
+var mermaidReplacer = strings.NewReplacer(":", "_", ";", "_", "#", "_")
+
+// PlantUML uses square brackets around task names
+var plantUMLReplacer = strings.NewReplacer("[", "(", "]", ")")
+
+// Arbitrary start date of PlantUML diagrams: Terraform logs have no
+// timestamps, so every event is shown as a day
+var plantUMLStart = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var ganttFuncs = template.FuncMap{
+	"mermaid":  mermaidReplacer.Replace,
+	"plantuml": plantUMLReplacer.Replace,
+	"day": func(event int) string {
+		return plantUMLStart.AddDate(0, 0, event).Format("2006-01-02")
+	},
+}
+
+// Render a log as a Mermaid Gantt diagram, e.g. for GitHub markdown
+func printMermaid(tflog ParsedLog) (string, error) {
+	return printGantt(tflog, MermaidTemplate)
+}
+
+// Render a log as a PlantUML Gantt diagram
+func printPlantUML(tflog ParsedLog) (string, error) {
+	return printGantt(tflog, PlantUMLTemplate)
+}
+
+func printGantt(tflog ParsedLog, Template string) (string, error) {
+	Context := map[string]interface{}{
+		"Title":    "Terraform run",
+		"Sections": ganttSections(tflog),
+		"Start":    plantUMLStart.Format("2006-01-02"),
+	}
+	template, _ := template.New("gantt").Funcs(ganttFuncs).Parse(Template)
+
+	var output bytes.Buffer
+	err := template.Execute(&output, Context)
+	if err != nil {
+		return "", err
+	}
+	_, err = fmt.Fprint(os.Stdout, output.String())
+	if err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// Events are used as timestamps (dateFormat X), so the axis shows event numbers
+const MermaidTemplate string = `gantt
+    title {{ .Title }}
+    dateFormat X
+    axisFormat %s
+{{- range .Sections }}
+    section {{ mermaid .Name }}
+{{- range .Tasks }}
+    {{ mermaid .Name }} :{{ if .Failed }}crit, {{ end }}{{ .Start }}, {{ .End }}
+{{- end }}
+{{- end }}
+`
+
+// Tasks use the full resource name, as PlantUML merges tasks with equal names
+const PlantUMLTemplate string = `@startgantt
+title {{ .Title }}
+Project starts {{ .Start }}
+{{- range .Sections }}
+-- {{ .Name }} --
+{{- range .Tasks }}
+[{{ plantuml .Resource }}] starts {{ day .Start }} and ends {{ day .End }}
+{{- if .Failed }}
+[{{ plantuml .Resource }}] is colored in Red
+{{- end }}
+{{- end }}
+{{- end }}
+@endgantt
+`
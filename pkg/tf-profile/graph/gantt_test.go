@@ -0,0 +1,58 @@
+package tfprofile
+
+import (
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ganttLog() ParsedLog {
+	return ParsedLog{Resources: map[string]ResourceMetric{
+		"aws_iam_role.r":                   {ModificationStartedEvent: 0, ModificationCompletedEvent: 2, AfterStatus: Created},
+		"module.a.aws_s3_bucket.b[*]":      {ModificationStartedEvent: 1, ModificationCompletedEvent: 5, AfterStatus: Multiple},
+		"module.a.aws_s3_object.o":         {ModificationStartedEvent: 3, ModificationCompletedEvent: 6, AfterStatus: Failed},
+		"module.a.data.aws_region.current": {ModificationStartedEvent: -1, ModificationCompletedEvent: -1},
+		"[queue] aws_sqs_queue.q":          {ModificationStartedEvent: 4, ModificationCompletedEvent: 4, AfterStatus: Created},
+	}}
+}
+
+func TestGanttSections(t *testing.T) {
+	Sections := ganttSections(ganttLog())
+	assert.Equal(t, 3, len(Sections))
+	assert.Equal(t, "(root)", Sections[0].Name)
+	assert.Equal(t, "module.a", Sections[1].Name)
+	assert.Equal(t, "[queue] (root)", Sections[2].Name)
+
+	assert.Equal(t, []ganttTask{
+		{Name: "aws_s3_bucket.b[*]", Resource: "module.a.aws_s3_bucket.b[*]", Start: 1, End: 5},
+		{Name: "aws_s3_object.o", Resource: "module.a.aws_s3_object.o", Start: 3, End: 6, Failed: true},
+	}, Sections[1].Tasks)
+	assert.Equal(t, 5, Sections[2].Tasks[0].End) // Bars are at least one event long
+}
+
+func TestMermaid(t *testing.T) {
+	out, err := printMermaid(ganttLog())
+	assert.Nil(t, err)
+	assert.Contains(t, out, "gantt\n    title Terraform run\n    dateFormat X\n")
+	assert.Contains(t, out, "    section module.a\n    aws_s3_bucket.b[*] :1, 5\n    aws_s3_object.o :crit, 3, 6\n")
+	assert.NotContains(t, out, "aws_region")
+
+	assert.Equal(t, "aws_s3_bucket.b[\"a_b\"]", mermaidReplacer.Replace("aws_s3_bucket.b[\"a:b\"]"))
+}
+
+func TestPlantUML(t *testing.T) {
+	out, err := printPlantUML(ganttLog())
+	assert.Nil(t, err)
+	assert.Contains(t, out, "@startgantt\ntitle Terraform run\nProject starts 2000-01-01\n")
+	assert.Contains(t, out, "-- module.a --\n[module.a.aws_s3_bucket.b(*)] starts 2000-01-02 and ends 2000-01-06\n")
+	assert.Contains(t, out, "[module.a.aws_s3_object.o] is colored in Red\n")
+	assert.Contains(t, out, "[(queue) aws_sqs_queue.q] starts 2000-01-05 and ends 2000-01-06\n")
+	assert.Contains(t, out, "@endgantt\n")
+
+	err = Graph([]string{"../../../test/many_modules.log"}, GraphOptions{W: 1000, H: 600, Format: "mermaid", Aggregate: true, AggregateLevels: "module", ColorBy: "status"})
+	assert.Nil(t, err)
+	err = Graph([]string{"../../../test/failures.log"}, GraphOptions{W: 1000, H: 600, Format: "plantuml", Aggregate: true, AggregateLevels: "resource", ColorBy: "status"})
+	assert.Nil(t, err)
+}
@@ -6,17 +6,54 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"regexp"
 	"sort"
 	"strings"
 	"text/template"
 
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/aggregate"
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/labels"
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/parser"
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/readers"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/runs"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/tfjson"
 )
 
-func Graph(args []string, w int, h int, OutFile string, aggregate bool) error {
+// Options of the `tf-profile graph` command
+type GraphOptions struct {
+	// Width and height of the image, in pixels
+	W int
+	H int
+	// File that gnuplot writes the image to
+	OutFile string
+	// gnuplot, folded, svg, mermaid, plantuml or terminal
+	Format string
+	// Aggregate count[] and for_each[], at the given levels
+	Aggregate       bool
+	AggregateLevels string
+	// Only profile this run (0 for all runs) and this Terragrunt unit
+	Run  int
+	Unit string
+	// JSON plan and state from before the run, if any
+	Plan  string
+	State string
+	// Only profile the resources with these labels, see FilterLabels
+	Labels []string
+	// Color of the bars, see barColors
+	ColorBy string
+	// Only draw the N resources that took the longest (0 for all resources)
+	Top int
+	// Only draw the resources in this module, see filterModule
+	Module string
+	// Options for large graphs
+	Layout LayoutOptions
+	// Fail on unrecognized lines
+	Strict bool
+}
+
+// Execute the `tf-profile graph` command
+func Graph(args []string, options GraphOptions) error {
 	var file *bufio.Scanner
 	var err error
 
@@ -28,20 +65,67 @@ func Graph(args []string, w int, h int, OutFile string, aggregate bool) error {
 	if err != nil {
 		return err
 	}
-	tflog, err := Parse(file, false)
+	tflog, runs, err := ParseRuns(file, false, options.Strict)
 	if err != nil {
 		return err
 	}
 
-	if aggregate {
-		tflog, err = Aggregate(tflog)
+	// Stdout is reserved for gnuplot, print the summary on stderr
+	PrintRunSummary(os.Stderr, runs)
+	tflog, err = SelectRun(tflog, runs, options.Run)
+	if err != nil {
+		return err
+	}
+	PrintUnitSummary(os.Stderr, tflog)
+	tflog, err = SelectUnit(tflog, options.Unit)
+	if err != nil {
+		return err
+	}
+	tflog, err = AddPlanAndState(tflog, options.Plan, options.State)
+	if err != nil {
+		return err
+	}
+	tflog, err = FilterLabels(tflog, options.Labels)
+	if err != nil {
+		return err
+	}
+
+	if options.Aggregate {
+		tflog, err = AggregateLevels(tflog, options.AggregateLevels)
 		if err != nil {
 			return err
 		}
 	}
 
+	tflog, err = filterModule(tflog, options.Module)
+	if err != nil {
+		return err
+	}
+	tflog = filterMinDuration(tflog, options.Layout.MinDuration)
+	tflog = filterTop(tflog, options.Top)
+
 	cleanFailedResources(tflog)
-	_, err = printGNUPlotOutput(tflog, w, h, OutFile)
+	tflog, err = filterWindow(tflog, options.Layout.From, options.Layout.To)
+	if err != nil {
+		return err
+	}
+
+	switch options.Format {
+	case "gnuplot":
+		_, err = printGNUPlotOutput(tflog, options.W, options.H, options.OutFile, options.ColorBy, options.Layout)
+	case "folded":
+		_, err = printFoldedStacks(tflog)
+	case "svg":
+		_, err = printFlameGraph(tflog, options.W)
+	case "mermaid":
+		_, err = printMermaid(tflog)
+	case "plantuml":
+		_, err = printPlantUML(tflog)
+	case "terminal":
+		_, err = printTerminal(tflog, terminalWidth())
+	default:
+		err = fmt.Errorf("Unknown format %v (expected gnuplot, folded, svg, mermaid, plantuml or terminal)", options.Format)
+	}
 
 	if err != nil {
 		return err
@@ -49,6 +133,55 @@ func Graph(args []string, w int, h int, OutFile string, aggregate bool) error {
 	return nil
 }
 
+// Keep only the resources in a module or its submodules. The module is a glob
+// that must match the whole module path, e.g. "module.core*" or
+// "module.app[*].module.db". An empty module keeps all resources.
+func filterModule(tflog ParsedLog, module string) (ParsedLog, error) {
+	if module == "" {
+		return tflog, nil
+	}
+	re, err := regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(module), `\*`, ".*") + `(\..*)?$`)
+	if err != nil {
+		return ParsedLog{}, fmt.Errorf("Invalid module %v: %v", module, err)
+	}
+
+	New := tflog
+	New.Resources = map[string]ResourceMetric{}
+	for resource, metrics := range tflog.Resources {
+		unit, address := SplitUnit(resource)
+		modules, _ := SplitModules(address)
+		path := strings.Join(modules, ".")
+		if re.MatchString(path) || re.MatchString(UnitAddress(unit, path)) {
+			New.Resources[resource] = metrics
+		}
+	}
+	return New, nil
+}
+
+// Keep only the N resources that took the longest. 0 keeps all resources.
+func filterTop(tflog ParsedLog, top int) ParsedLog {
+	if top <= 0 || top >= len(tflog.Resources) {
+		return tflog
+	}
+	Names := []string{}
+	for resource := range tflog.Resources {
+		Names = append(Names, resource)
+	}
+	sort.Slice(Names, func(i int, j int) bool {
+		if tflog.Resources[Names[i]].TotalTime != tflog.Resources[Names[j]].TotalTime {
+			return tflog.Resources[Names[i]].TotalTime > tflog.Resources[Names[j]].TotalTime
+		}
+		return Names[i] < Names[j]
+	})
+
+	New := tflog
+	New.Resources = map[string]ResourceMetric{}
+	for _, resource := range Names[:top] {
+		New.Resources[resource] = tflog.Resources[resource]
+	}
+	return New
+}
+
 // For failed resources, ModificationCompletedEvent will always be -1, since we never
 // detect the end of their modifications. We manually set their ModificationCompletedEvent
 // to the maximum value, leading to a long red bar.
@@ -71,41 +204,267 @@ func cleanFailedResources(tflog ParsedLog) {
 	}
 }
 
+// Colors of the bars in the graph
+const (
+	Green = 0x49A720
+	Red   = 0xD32F2F
+	Grey  = 0xBDBDBD
+)
+
+// Colors of the statuses after the run, for --color-by status
+var StatusColors = map[Status]int{
+	Created:    Green,
+	Failed:     Red,
+	NotCreated: 0x757575,
+	Tainted:    0xFF7F0E,
+	Unknown:    Grey,
+	Multiple:   0xFFB300,
+}
+
+// Colors of the operations, for --color-by operation
+var OperationColors = map[Operation]int{
+	None:       Grey,
+	Create:     Green,
+	Modify:     0x1F77B4,
+	Replace:    0xFF7F0E,
+	Destroy:    Red,
+	MultipleOp: 0xFFB300,
+	Read:       0x9467BD,
+	Import:     0x17BECF,
+	Move:       0x8C564B,
+	Forget:     0xE377C2,
+}
+
+// Colors for the values of a module, provider or label, in order. When there
+// are more values, colors are reused.
+var Palette = []int{0x1F77B4, 0xFF7F0E, 0x2CA02C, 0xD62728, 0x9467BD, 0x8C564B, 0xE377C2, 0xBCBD22, 0x17BECF}
+
+// An entry in the legend of the graph
+type LegendEntry struct {
+	Title string
+	Color int
+}
+
+// Color of the bar of every resource, and the legend that explains them:
+//   - status (default): the status after the run, e.g. red for failed resources
+//   - operation: the operation, e.g. Create or Destroy
+//   - module, provider and label:<key>: every top-level module, provider or
+//     value of the label gets a color of the palette. Resources in the root
+//     module or without the label are grey.
+func barColors(tflog ParsedLog, color_by string) (map[string]int, []LegendEntry, error) {
+	switch color_by {
+	case "status":
+		Statuses := map[string]Status{}
+		for resource, metrics := range tflog.Resources {
+			Statuses[resource] = metrics.AfterStatus
+		}
+		Colors, Legend := enumColors(Statuses, StatusColors)
+		return Colors, Legend, nil
+	case "operation":
+		Operations := map[string]Operation{}
+		for resource, metrics := range tflog.Resources {
+			Operations[resource] = metrics.Operation
+		}
+		Colors, Legend := enumColors(Operations, OperationColors)
+		return Colors, Legend, nil
+	case "module":
+		Colors, Legend := paletteColors(tflog, RootModule, func(resource string, metrics ResourceMetric) string {
+			return GroupKey(resource, metrics, GroupByTopModule)
+		})
+		return Colors, Legend, nil
+	case "provider":
+		Colors, Legend := paletteColors(tflog, "", func(resource string, metrics ResourceMetric) string {
+			return GroupKey(resource, metrics, GroupByProvider)
+		})
+		return Colors, Legend, nil
+	}
+
+	key, ok := LabelKey(color_by)
+	if !ok {
+		return nil, nil, fmt.Errorf("Unknown --color-by %v (expected status, operation, module, provider or label:<key>)", color_by)
+	}
+	Colors, Legend := paletteColors(tflog, NoLabel, func(resource string, metrics ResourceMetric) string {
+		return Label(resource, key)
+	})
+	return Colors, Legend, nil
+}
+
+// Colors of statuses or operations. The legend lists the values that occur,
+// in the order of their definition.
+func enumColors[T Status | Operation](Values map[string]T, ValueColors map[T]int) (map[string]int, []LegendEntry) {
+	colorOf := func(value T) int {
+		if color, ok := ValueColors[value]; ok {
+			return color
+		}
+		return Grey
+	}
+
+	Colors := map[string]int{}
+	Seen := map[T]bool{}
+	for resource, value := range Values {
+		Colors[resource] = colorOf(value)
+		Seen[value] = true
+	}
+
+	Used := []T{}
+	for value := range Seen {
+		Used = append(Used, value)
+	}
+	sort.Slice(Used, func(i int, j int) bool { return Used[i] < Used[j] })
+	Legend := []LegendEntry{}
+	for _, value := range Used {
+		Legend = append(Legend, LegendEntry{fmt.Sprintf("%v", value), colorOf(value)})
+	}
+	return Colors, Legend
+}
+
+// Colors from the palette, one per value of key. Resources whose value is
+// grey are drawn in grey and listed last in the legend.
+func paletteColors(tflog ParsedLog, grey string, key func(resource string, metrics ResourceMetric) string) (map[string]int, []LegendEntry) {
+	Keys := map[string]string{}
+	Seen := map[string]bool{}
+	for resource, metrics := range tflog.Resources {
+		Keys[resource] = key(resource, metrics)
+		Seen[Keys[resource]] = true
+	}
+	Values := []string{}
+	for value := range Seen {
+		if value != grey {
+			Values = append(Values, value)
+		}
+	}
+	sort.Strings(Values)
+
+	ValueColors := map[string]int{grey: Grey}
+	Legend := []LegendEntry{}
+	for idx, value := range Values {
+		ValueColors[value] = Palette[idx%len(Palette)]
+		Legend = append(Legend, LegendEntry{value, ValueColors[value]})
+	}
+	if Seen[grey] && grey != "" {
+		Legend = append(Legend, LegendEntry{grey, Grey})
+	}
+
+	Colors := map[string]int{}
+	for resource, value := range Keys {
+		Colors[resource] = ValueColors[value]
+	}
+	return Colors, Legend
+}
+
+// Aggregated resources whose instances ended up in different statuses or had
+// different operations are drawn hatched
+func isHatched(metrics ResourceMetric) bool {
+	return metrics.AfterStatus == Multiple || metrics.Operation == MultipleOp
+}
+
+// The data of a page of the gnuplot output. Gnuplot draws the first line at
+// the bottom, so Lines are ordered from bottom to top.
+type gnuplotPage struct {
+	Number int
+	File   string
+	Rows   int
+	Lines  []string
+	Tics   string
+	// Vertical ranges with a grey background, to tell lanes apart
+	Shading [][2]float64
+}
+
+// Gnuplot strings are double-quoted, tic labels and legend titles are printed
+// as-is (noenhanced)
+var gnuplotStringReplacer = strings.NewReplacer(`\`, `\\`, `"`, `'`)
+
 // Use the template below and a ParsedLog to generate all output for gnuplot.
-// This can be piped into gnuplot to generate a .png file
-func printGNUPlotOutput(tflog ParsedLog, w int, h int, OutFile string) (string, error) {
+// This can be piped into gnuplot to generate a .png file, or with pages,
+// several files if the resources do not fit on a single page (see layoutPages).
+func printGNUPlotOutput(tflog ParsedLog, w int, h int, OutFile string, color_by string, layout LayoutOptions) (string, error) {
 	if w < 1 || h < 1 {
-		return "", errors.New("--size must provided as two positive integers (e.g. '1000,1000').")
+		return "", errors.New("--size must be provided as two positive integers (e.g. '1000,1000')")
+	}
+	Colors, Legend, err := barColors(tflog, color_by)
+	if err != nil {
+		return "", err
 	}
 
 	// Context object for templating
 	Context := map[string]interface{}{}
 	Context["W"] = w
 	Context["H"] = h
-	Context["File"] = OutFile
+	for idx := range Legend {
+		Legend[idx].Title = gnuplotStringReplacer.Replace(Legend[idx].Title)
+	}
+	Context["Legend"] = Legend
+	Context["Hatched"] = false
+	Context["LeftMargin"] = labelWidth(w) + 2
+	Context["XFrom"] = -1
+	Context["XTo"] = ""
+	if layout.From > 0 {
+		Context["XFrom"] = layout.From
+	}
+	if layout.To > 0 {
+		Context["XTo"] = layout.To
+	}
 
-	SortedResources := sortResourcesForGraph(tflog)
-	Resources := []string{} // Lines passed into template
+	PerPage := 0
+	if layout.Pages {
+		PerPage = rowsPerPage(h)
+	}
+	Pages := []gnuplotPage{}
+	for idx, page := range layoutPages(graphLanes(tflog, layout.Swimlanes), PerPage, labelWidth(w), OutFile) {
+		Page := gnuplotPage{Number: idx + 1, File: page.File, Rows: len(page.Rows)}
+		Tics := []string{}
 
-	// Build list of lines and let template do the looping
-	for _, r := range SortedResources {
-		metrics := tflog.Resources[r]
+		// Build list of lines and let template do the looping
+		for i := len(page.Rows) - 1; i >= 0; i-- {
+			row := page.Rows[i]
+			y := len(page.Rows) - i
+			Tics = append(Tics, fmt.Sprintf(`"%v" %v`, gnuplotStringReplacer.Replace(row.Label), y))
+			if row.Lane%2 == 1 && layout.Swimlanes {
+				if n := len(Page.Shading); n > 0 && Page.Shading[n-1][1] == float64(y)-0.5 {
+					Page.Shading[n-1][1] = float64(y) + 0.5
+				} else {
+					Page.Shading = append(Page.Shading, [2]float64{float64(y) - 0.5, float64(y) + 0.5})
+				}
+			}
+			if row.Resource == "" {
+				continue
+			}
 
-		NameForOutput := strings.Replace(r, "_", `\\\_`, -1)
-		NameForOutput = strings.Replace(NameForOutput, `"`, `'`, -1)
-		// Escape underscores and add the necessary metrics.
-		line := fmt.Sprintf("%v %v %v %v",
-			NameForOutput,
-			metrics.ModificationStartedEvent,
-			metrics.ModificationCompletedEvent,
-			metrics.AfterStatus,
-		)
-		Resources = append(Resources, line)
+			r := row.Resource
+			metrics := tflog.Resources[r]
+			NameForOutput := strings.Replace(r, "_", `\\\_`, -1)
+			NameForOutput = strings.Replace(NameForOutput, `"`, `'`, -1)
+			if strings.Contains(NameForOutput, " ") {
+				// Unit addresses ("[unit] address") must stay a single column
+				NameForOutput = `"` + NameForOutput + `"`
+			}
+			Hatched := 0
+			if isHatched(metrics) {
+				Hatched = 1
+				Context["Hatched"] = true
+			}
+			// Escape underscores and add the necessary metrics.
+			line := fmt.Sprintf("%v %v %v %v %d %v %v",
+				NameForOutput,
+				metrics.ModificationStartedEvent,
+				metrics.ModificationCompletedEvent,
+				metrics.AfterStatus,
+				Colors[r],
+				Hatched,
+				y,
+			)
+			Page.Lines = append(Page.Lines, line)
+		}
+		Page.Tics = strings.Join(Tics, ", ")
+		Pages = append(Pages, Page)
+	}
+	if len(Pages) == 0 {
+		return "", errors.New("No resources to draw.")
 	}
-	Context["Resources"] = Resources
+	Context["Pages"] = Pages
 
 	template, _ := template.New("plot").Parse(Template)
-	err := template.Execute(os.Stdout, Context) // To stdout
+	err = template.Execute(os.Stdout, Context) // To stdout
 	if err != nil {
 		return "", err
 	}
@@ -119,7 +478,8 @@ func printGNUPlotOutput(tflog ParsedLog, w int, h int, OutFile string) (string,
 }
 
 // To create a nice graph, sort the resources chronologically
-// according to ModificationStartedEvent
+// according to ModificationStartedEvent, then by name. For Terragrunt logs, the resources
+// of each unit are kept together, giving every unit a lane of its own.
 func sortResourcesForGraph(log ParsedLog) []string {
 	// Collect keys
 	keys := []string{}
@@ -127,8 +487,23 @@ func sortResourcesForGraph(log ParsedLog) []string {
 		keys = append(keys, key)
 	}
 
+	// Units in order of appearance. Gnuplot draws the first line at the bottom,
+	// so everything is sorted in reverse.
+	lanes := map[string]int{}
+	for idx, unit := range log.Units {
+		lanes[unit] = idx
+	}
+
 	sort.Slice(keys, func(i, j int) bool {
-		return log.Resources[keys[i]].ModificationStartedEvent > log.Resources[keys[j]].ModificationStartedEvent
+		lane1, lane2 := lanes[log.Resources[keys[i]].Unit], lanes[log.Resources[keys[j]].Unit]
+		if lane1 != lane2 {
+			return lane1 > lane2
+		}
+		start1, start2 := log.Resources[keys[i]].ModificationStartedEvent, log.Resources[keys[j]].ModificationStartedEvent
+		if start1 != start2 {
+			return start1 > start2
+		}
+		return keys[i] > keys[j]
 	})
 	return keys
 }
@@ -139,41 +514,53 @@ reset
 set termoption dash
 set terminal pngcairo  background "#ffffff" fontscale 1.0 dashed size {{ .W }}, {{ .H }}
 
-# --- Output colors
-green = 0x49A720;# 0xFFE599;
-red = 0xD32F2F; # 0xF1C232;
-
-# resource        start    end   status
-$DATA << EOD 
-{{range .Resources -}} 	
-{{ . }}
-{{ end }}
-EOD     
-                     
-# set output
-set output "{{ .File }}"
-
 # grid and tics
 set mxtics 
-set mytics
 set grid xtics
 set grid ytics
 set grid mxtics
 
-# create list of keys
-List = ''
-set table $Dummy
-    plot $DATA u (List=List.'"'.strcol(1).'" ',NaN) w table
-unset table
+# room for the labels of the resources
+set lmargin {{ .LeftMargin }}
+
+# set range of x-axis
+set xrange [{{ .XFrom }}:{{ .XTo }}]
+
+# legend
+set key outside right top box noenhanced
+{{ range .Pages }}
+# page {{ .Number }}: resource        start    end   status   color   hatched   row
+$DATA{{ .Number }} << EOD
+{{ range .Lines -}}
+{{ . }}
+{{ end -}}
+EOD
 
-# define functions for lookup/index and color
-Lookup(s) = (Index = NaN, sum [i=1:words(List)] \
-    (Index = s eq word(List,i) ? i : Index,0), Index)
-Color(s) = (s eq "Failed") ?  red : green
+# set output
+set output "{{ .File }}"
+{{- if gt (len $.Pages) 1 }}
+set title "Page {{ .Number }} of {{ len $.Pages }}"
+{{- end }}
 
-# set range of x-axis and y-axis
-set xrange [-1:]
-set yrange [0.5:words(List)+0.5]
+# one row per resource or lane, from the bottom to the top
+set yrange [0.5:{{ .Rows }}.5]
+set ytics noenhanced ({{ .Tics }})
+unset object
+{{- range .Shading }}
+set object rect from graph 0, first {{ index . 0 }} to graph 1, first {{ index . 1 }} fc rgb "#EEEEEE" fillstyle solid noborder behind
+{{- end }}
 
-plot $DATA u 2:(Idx=Lookup(strcol(1))): 3 : 2 :(Idx-0.2):(Idx+0.2): \
-    (Color(strcol(4))): ytic(strcol(1)) w boxxyerror fill solid 0.7 lw 2.0 lc rgb var notitle`
+# hatched bars are drawn twice: in their color and with a pattern on top
+plot $DATA{{ .Number }} u 2:7: 3 : 2 :($7-0.2):($7+0.2): \
+    5 w boxxyerror fill solid 0.7 lw 2.0 lc rgb var notitle
+{{- if $.Hatched }}, \
+    $DATA{{ .Number }} u ($6 ? $2 : NaN):7: ($6 ? $3 : NaN) : 2 :($7-0.2):($7+0.2) \
+    w boxxyerror fill transparent pattern 4 lw 2.0 lc rgb 0x000000 notitle
+{{- end }}
+{{- range $.Legend }}, \
+    NaN w boxes fill solid 0.7 lc rgb {{ printf "0x%06X" .Color }} title "{{ .Title }}"
+{{- end }}
+{{- if $.Hatched }}, \
+    NaN w boxes fill transparent pattern 4 lc rgb 0x000000 title "Multiple (hatched)"
+{{- end }}
+{{ end }}`
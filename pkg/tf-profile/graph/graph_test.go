@@ -8,6 +8,9 @@ import (
 	"testing"
 
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/aggregate"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/config"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/labels"
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/parser"
 
 	"github.com/stretchr/testify/assert"
@@ -20,14 +23,14 @@ func TestFailureParse(t *testing.T) {
 	// Sanity check: all *.log files must be graph-able
 	for _, File := range Files {
 		if strings.Contains(File.Name(), ".log") {
-			err := Graph([]string{"../../../test/" + File.Name()}, 1000, 600, "tf-profile-graph.png", true)
+			err := Graph([]string{"../../../test/" + File.Name()}, GraphOptions{W: 1000, H: 600, OutFile: "tf-profile-graph.png", Format: "gnuplot", Aggregate: true, AggregateLevels: "resource", ColorBy: "status"})
 			assert.Nil(t, err)
 		}
 	}
 
-	err = Graph([]string{"../../../test/does-not-exist"}, 1000, 600, "tf-profile-graph.png", true)
+	err = Graph([]string{"../../../test/does-not-exist"}, GraphOptions{W: 1000, H: 600, OutFile: "tf-profile-graph.png", Format: "gnuplot", Aggregate: true, AggregateLevels: "resource", ColorBy: "status"})
 	assert.NotNil(t, err)
-	err = Graph([]string{"../../../test/failures.log"}, -1, -1, "tf-profile-graph.png", true)
+	err = Graph([]string{"../../../test/failures.log"}, GraphOptions{W: -1, H: -1, OutFile: "tf-profile-graph.png", Format: "gnuplot", Aggregate: true, AggregateLevels: "resource", ColorBy: "status"})
 	assert.NotNil(t, err)
 }
 
@@ -38,13 +41,120 @@ func TestPlotOutput(t *testing.T) {
 	log, _ := Parse(s, false)
 	log, _ = Aggregate(log)
 
-	out, err := printGNUPlotOutput(log, 1000, 600, "tf-profile-graph.png")
+	out, err := printGNUPlotOutput(log, 1000, 600, "tf-profile-graph.png", "status", LayoutOptions{})
 
 	assert.Nil(t, err)
 	fmt.Println(out)
 	assert.Contains(t, out, `aws\\\_ssm\\\_parameter.good2[*] 7 11 Created`)
-	assert.Contains(t, out, `aws\\\_ssm\\\_parameter.bad 5 -1 Failed`)
+	assert.Contains(t, out, `aws\\\_ssm\\\_parameter.bad 5 -1 Failed 13840175`)
 	assert.Contains(t, out, `aws\\\_ssm\\\_parameter.bad2[*] 3 -1 Failed`)
 	assert.Contains(t, out, `aws\\\_ssm\\\_parameter.good 0 8 Created`)
 
 }
+
+func TestPlotUnitLanes(t *testing.T) {
+	file, _ := os.Open("../../../test/terragrunt.log")
+	s := bufio.NewScanner(file)
+
+	log, _ := Parse(s, false)
+	log, _ = Aggregate(log)
+
+	out, err := printGNUPlotOutput(log, 1000, 600, "tf-profile-graph.png", "status", LayoutOptions{})
+	assert.Nil(t, err)
+
+	// Every unit has its own lane. The first line is drawn at the bottom, so
+	// the first unit (network) ends up at the top.
+	Queue := strings.Index(out, `"[queue] aws\\\_sqs\\\_queue.jobs" 0 2 Created`)
+	Subnets := strings.Index(out, `"[network] aws\\\_subnet.private[*]" 4 7 Created`)
+	Vpc := strings.Index(out, `"[network] aws\\\_vpc.this" 1 3 Created`)
+	assert.True(t, Queue >= 0 && Subnets >= 0 && Vpc >= 0)
+	assert.True(t, Queue < Subnets && Subnets < Vpc)
+}
+
+func TestPlotColorByLabel(t *testing.T) {
+	t.Cleanup(ResetLabelRules)
+	err := AddLabelRules([]LabelRule{
+		{Match: "aws_ssm_parameter.good*", Labels: map[string]string{"quality": "good"}},
+		{Match: "aws_ssm_parameter.bad*", Labels: map[string]string{"quality": "bad"}},
+	})
+	assert.Nil(t, err)
+
+	file, _ := os.Open("../../../test/failures.log")
+	log, _ := Parse(bufio.NewScanner(file), false)
+	log, _ = Aggregate(log)
+
+	Colors, Legend, err := barColors(log, "label:quality")
+	assert.Nil(t, err)
+	assert.Equal(t, Palette[0], Colors["aws_ssm_parameter.bad"]) // Values are sorted
+	assert.Equal(t, Palette[1], Colors["aws_ssm_parameter.good2[*]"])
+	assert.Equal(t, []LegendEntry{{"bad", Palette[0]}, {"good", Palette[1]}}, Legend)
+
+	Colors, _, err = barColors(log, "status")
+	assert.Nil(t, err)
+	assert.Equal(t, Red, Colors["aws_ssm_parameter.bad"])
+	assert.Equal(t, Green, Colors["aws_ssm_parameter.good"])
+
+	_, _, err = barColors(log, "team")
+	assert.NotNil(t, err)
+	err = Graph([]string{"../../../test/failures.log"}, GraphOptions{W: 1000, H: 600, OutFile: "tf-profile-graph.png", Format: "gnuplot", Aggregate: true, AggregateLevels: "resource", Labels: []string{"quality=good"}, ColorBy: "label:quality"})
+	assert.Nil(t, err)
+}
+
+func TestPlotColorBy(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{
+		"aws_iam_role.r":                 {Operation: Create, AfterStatus: Created, Provider: "aws"},
+		"module.app.aws_s3_bucket.b":     {Operation: Replace, AfterStatus: Created, Provider: "aws"},
+		"module.app.google_dns_record.d": {Operation: Destroy, AfterStatus: Multiple, Provider: "google"},
+	}}
+
+	Colors, Legend, err := barColors(log, "operation")
+	assert.Nil(t, err)
+	assert.Equal(t, OperationColors[Replace], Colors["module.app.aws_s3_bucket.b"])
+	assert.Equal(t, []LegendEntry{{"Create", Green}, {"Replace", OperationColors[Replace]}, {"Destroy", Red}}, Legend)
+
+	Colors, Legend, err = barColors(log, "module")
+	assert.Nil(t, err)
+	assert.Equal(t, Grey, Colors["aws_iam_role.r"])
+	assert.Equal(t, Palette[0], Colors["module.app.aws_s3_bucket.b"])
+	assert.Equal(t, []LegendEntry{{"module.app", Palette[0]}, {RootModule, Grey}}, Legend)
+
+	Colors, Legend, err = barColors(log, "provider")
+	assert.Nil(t, err)
+	assert.Equal(t, Palette[1], Colors["module.app.google_dns_record.d"])
+	assert.Equal(t, []LegendEntry{{"aws", Palette[0]}, {"google", Palette[1]}}, Legend)
+
+	// Resources with multiple statuses are hatched
+	assert.True(t, isHatched(log.Resources["module.app.google_dns_record.d"]))
+	assert.False(t, isHatched(log.Resources["aws_iam_role.r"]))
+}
+
+func TestPlotLegend(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{
+		"aws_iam_role.r":     {ModificationStartedEvent: 0, ModificationCompletedEvent: 2, AfterStatus: Created},
+		"aws_s3_bucket.b[*]": {ModificationStartedEvent: 1, ModificationCompletedEvent: 3, AfterStatus: Multiple},
+	}}
+	out, err := printGNUPlotOutput(log, 1000, 600, "tf-profile-graph.png", "status", LayoutOptions{})
+	assert.Nil(t, err)
+
+	assert.Contains(t, out, `aws\\\_s3\\\_bucket.b[*] 1 3 Multiple 16757504 1`)
+	assert.Contains(t, out, `aws\\\_iam\\\_role.r 0 2 Created 4826912 0`)
+	assert.Contains(t, out, `title "Created"`)
+	assert.Contains(t, out, `title "Multiple (hatched)"`)
+	assert.Contains(t, out, "fill transparent pattern 4")
+}
+
+func TestPlotLegendEscaped(t *testing.T) {
+	t.Cleanup(ResetLabelRules)
+	err := AddLabelRules([]LabelRule{
+		{Match: "aws_iam_role.*", Labels: map[string]string{"team": `"core" \ infra`}},
+	})
+	assert.Nil(t, err)
+
+	log := ParsedLog{Resources: map[string]ResourceMetric{
+		"aws_iam_role.r": {ModificationStartedEvent: 0, ModificationCompletedEvent: 2, AfterStatus: Created},
+	}}
+	out, err := printGNUPlotOutput(log, 1000, 600, "tf-profile-graph.png", "label:team", LayoutOptions{})
+	assert.Nil(t, err)
+	assert.Contains(t, out, `title "'core' \\ infra"`)
+	assert.Contains(t, out, "set key outside right top box noenhanced")
+}
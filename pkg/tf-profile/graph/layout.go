@@ -0,0 +1,198 @@
+package tfprofile
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/aggregate"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+// Options for large graphs, see Graph
+type LayoutOptions struct {
+	// Group the resources into a lane per module, with the module as header
+	Swimlanes bool
+	// Resources that took less time (in ms) are not drawn
+	MinDuration float64
+	// Only draw what happened between these events. 0 means the first and
+	// the last event respectively.
+	From int
+	To   int
+	// Split the graph into pages of the height of the image, instead of
+	// drawing all resources in a single image
+	Pages bool
+}
+
+// Layout of the gnuplot output, in pixels. With pages, every row must be at
+// least as high as a line of text, so that labels never overlap.
+const (
+	graphRowHeight      = 16
+	graphVerticalMargin = 90
+	graphCharWidth      = 8
+)
+
+// A lane of the graph: the resources of one module, from top to bottom.
+// Without swimlanes, all resources are in a single lane without a name.
+type graphLane struct {
+	Name      string
+	Resources []string
+}
+
+// A row of the graph. Rows without a resource are the headers of lanes.
+type graphRow struct {
+	Label    string
+	Resource string
+	Lane     int
+}
+
+// The rows on one page (i.e. output file) of the graph, from top to bottom
+type graphPage struct {
+	File string
+	Rows []graphRow
+}
+
+// Name of the module of a resource, including its Terragrunt unit, e.g.
+// "[network] module.vpc". Resources outside of modules are in RootModule.
+func moduleName(resource string) string {
+	unit, address := SplitUnit(resource)
+	modules, _ := SplitModules(address)
+	module := strings.Join(modules, ".")
+	if module == "" {
+		module = RootModule
+	}
+	return UnitAddress(unit, module)
+}
+
+// Drop resources that took less time (in ms). 0 keeps all resources.
+func filterMinDuration(tflog ParsedLog, min float64) ParsedLog {
+	if min <= 0 {
+		return tflog
+	}
+	New := tflog
+	New.Resources = map[string]ResourceMetric{}
+	for resource, metrics := range tflog.Resources {
+		if metrics.TotalTime >= min {
+			New.Resources[resource] = metrics
+		}
+	}
+	return New
+}
+
+// Zoom in on the events between from and to: resources that were not being
+// modified in that window are dropped, the others are cut off at its edges.
+func filterWindow(tflog ParsedLog, from int, to int) (ParsedLog, error) {
+	if from < 0 || to < 0 {
+		return ParsedLog{}, fmt.Errorf("--from and --to must be non-negative, got %v and %v", from, to)
+	}
+	if to > 0 && to <= from {
+		return ParsedLog{}, fmt.Errorf("--to (%v) must be after --from (%v)", to, from)
+	}
+	if from == 0 && to == 0 {
+		return tflog, nil
+	}
+
+	New := tflog
+	New.Resources = map[string]ResourceMetric{}
+	for resource, metrics := range tflog.Resources {
+		Start, End := metrics.ModificationStartedEvent, metrics.ModificationCompletedEvent
+		if Start < 0 || (to > 0 && Start > to) || (End >= 0 && End < from) {
+			continue
+		}
+		metrics.ModificationStartedEvent = max(Start, from)
+		if to > 0 && (End < 0 || End > to) {
+			metrics.ModificationCompletedEvent = to
+		}
+		New.Resources[resource] = metrics
+	}
+	return New, nil
+}
+
+// Group the resources of a log into lanes. Resources are sorted like before
+// (by unit, then chronologically), lanes by their first resource.
+func graphLanes(tflog ParsedLog, swimlanes bool) []graphLane {
+	Sorted := sortResourcesForGraph(tflog)
+	Resources := []string{}
+	for idx := len(Sorted) - 1; idx >= 0; idx-- {
+		Resources = append(Resources, Sorted[idx])
+	}
+	if !swimlanes {
+		return []graphLane{{Resources: Resources}}
+	}
+
+	Lanes := []graphLane{}
+	Index := map[string]int{}
+	for _, resource := range Resources {
+		module := moduleName(resource)
+		if _, ok := Index[module]; !ok {
+			Index[module] = len(Lanes)
+			Lanes = append(Lanes, graphLane{Name: module})
+		}
+		Lanes[Index[module]].Resources = append(Lanes[Index[module]].Resources, resource)
+	}
+	return Lanes
+}
+
+// Number of rows that fit on a page of the given height
+func rowsPerPage(h int) int {
+	return max((h-graphVerticalMargin)/graphRowHeight, 1)
+}
+
+// Number of characters of a label that fit in the left 40% of the graph
+func labelWidth(w int) int {
+	return max(w*2/5/graphCharWidth, minNameWidth)
+}
+
+// Distribute the lanes over pages of at most perPage rows, or a single page
+// if perPage is 0. A lane that continues on the next page repeats its header
+// there. Labels are cut off at width characters. With more than one page,
+// every page is written to a file of its own: graph.png => graph-1.png,
+// graph-2.png, ...
+func layoutPages(lanes []graphLane, perPage int, width int, OutFile string) []graphPage {
+	Pages := [][]graphRow{}
+	Current := []graphRow{}
+	newPage := func() {
+		if len(Current) > 0 {
+			Pages = append(Pages, Current)
+		}
+		Current = []graphRow{}
+	}
+
+	for idx, lane := range lanes {
+		header := lane.Name != ""
+		// Don't leave a header at the bottom of a page
+		if header && perPage > 0 && len(Current) > 0 && len(Current)+2 > perPage {
+			newPage()
+		}
+		if header {
+			Current = append(Current, graphRow{Label: truncateName(lane.Name, width), Lane: idx})
+		}
+		for _, resource := range lane.Resources {
+			if perPage > 0 && len(Current) >= perPage {
+				newPage()
+				if header && perPage > 1 {
+					Current = append(Current, graphRow{Label: truncateName(lane.Name+" (continued)", width), Lane: idx})
+				}
+			}
+			Label := truncateName(resource, width)
+			if header {
+				_, address := SplitUnit(resource)
+				_, name := SplitModules(address)
+				Label = "  " + truncateName(name, width-2)
+			}
+			Current = append(Current, graphRow{Label: Label, Resource: resource, Lane: idx})
+		}
+	}
+	newPage()
+
+	Result := []graphPage{}
+	for idx, rows := range Pages {
+		File := OutFile
+		if len(Pages) > 1 {
+			ext := filepath.Ext(OutFile)
+			File = fmt.Sprintf("%v-%v%v", strings.TrimSuffix(OutFile, ext), idx+1, ext)
+		}
+		Result = append(Result, graphPage{File: File, Rows: rows})
+	}
+	return Result
+}
@@ -0,0 +1,139 @@
+package tfprofile
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterMinDuration(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{
+		"aws_iam_role.r":  {TotalTime: 2000},
+		"aws_s3_bucket.b": {TotalTime: 9000},
+	}}
+	assert.Equal(t, 1, len(filterMinDuration(log, 5000).Resources))
+	assert.Contains(t, filterMinDuration(log, 5000).Resources, "aws_s3_bucket.b")
+	assert.Equal(t, log, filterMinDuration(log, 0))
+}
+
+func TestFilterWindow(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{
+		"aws_iam_role.r":   {ModificationStartedEvent: 0, ModificationCompletedEvent: 4},
+		"aws_s3_bucket.b":  {ModificationStartedEvent: 3, ModificationCompletedEvent: 12},
+		"aws_sqs_queue.q":  {ModificationStartedEvent: 11, ModificationCompletedEvent: 15},
+		"data.aws_region.": {ModificationStartedEvent: -1, ModificationCompletedEvent: -1},
+	}}
+
+	Window, err := filterWindow(log, 5, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(Window.Resources))
+	assert.Equal(t, 5, Window.Resources["aws_s3_bucket.b"].ModificationStartedEvent)
+	assert.Equal(t, 10, Window.Resources["aws_s3_bucket.b"].ModificationCompletedEvent)
+
+	Window, err = filterWindow(log, 4, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(Window.Resources))
+	assert.Equal(t, 4, Window.Resources["aws_iam_role.r"].ModificationStartedEvent)
+	assert.Equal(t, 15, Window.Resources["aws_sqs_queue.q"].ModificationCompletedEvent)
+
+	Window, err = filterWindow(log, 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, log, Window)
+
+	_, err = filterWindow(log, 10, 5)
+	assert.NotNil(t, err)
+	_, err = filterWindow(log, -1, 5)
+	assert.NotNil(t, err)
+}
+
+func TestGraphLanes(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{
+		"aws_iam_role.r":                 {ModificationStartedEvent: 0},
+		"module.app.aws_s3_bucket.b":     {ModificationStartedEvent: 1},
+		"module.db.aws_rds_cluster.c":    {ModificationStartedEvent: 2},
+		"module.app.aws_sqs_queue.queue": {ModificationStartedEvent: 3},
+	}}
+
+	Lanes := graphLanes(log, false)
+	assert.Equal(t, 1, len(Lanes))
+	assert.Equal(t, []string{"aws_iam_role.r", "module.app.aws_s3_bucket.b", "module.db.aws_rds_cluster.c", "module.app.aws_sqs_queue.queue"}, Lanes[0].Resources)
+
+	Lanes = graphLanes(log, true)
+	assert.Equal(t, []graphLane{
+		{Name: "(root)", Resources: []string{"aws_iam_role.r"}},
+		{Name: "module.app", Resources: []string{"module.app.aws_s3_bucket.b", "module.app.aws_sqs_queue.queue"}},
+		{Name: "module.db", Resources: []string{"module.db.aws_rds_cluster.c"}},
+	}, Lanes)
+}
+
+func TestLayoutPages(t *testing.T) {
+	Lanes := []graphLane{
+		{Name: "module.app", Resources: []string{"module.app.a.a", "module.app.b.b", "module.app.c.c"}},
+		{Name: "module.db", Resources: []string{"module.db.d.d"}},
+	}
+
+	Pages := layoutPages(Lanes, 3, 40, "graph.png")
+	assert.Equal(t, 3, len(Pages))
+	assert.Equal(t, "graph-1.png", Pages[0].File)
+	assert.Equal(t, []graphRow{
+		{Label: "module.app", Lane: 0},
+		{Label: "  a.a", Resource: "module.app.a.a", Lane: 0},
+		{Label: "  b.b", Resource: "module.app.b.b", Lane: 0},
+	}, Pages[0].Rows)
+	// The lane continues on the next page. The next header would end up
+	// alone at the bottom, so it moves to the next page as well.
+	assert.Equal(t, []graphRow{
+		{Label: "module.app (continued)", Lane: 0},
+		{Label: "  c.c", Resource: "module.app.c.c", Lane: 0},
+	}, Pages[1].Rows)
+	assert.Equal(t, "graph-3.png", Pages[2].File)
+	assert.Equal(t, "module.db", Pages[2].Rows[0].Label)
+
+	// Everything fits on a single page with the original file name
+	Pages = layoutPages(Lanes, 10, 40, "graph.png")
+	assert.Equal(t, 1, len(Pages))
+	assert.Equal(t, "graph.png", Pages[0].File)
+	assert.Equal(t, 6, len(Pages[0].Rows))
+
+	// Without pages, all rows are on a single page
+	Pages = layoutPages(Lanes, 0, 40, "graph.png")
+	assert.Equal(t, 1, len(Pages))
+	assert.Equal(t, "graph.png", Pages[0].File)
+	assert.Equal(t, 6, len(Pages[0].Rows))
+
+	// Long labels are shortened
+	Pages = layoutPages([]graphLane{{Resources: []string{"module.app.aws_s3_bucket.b"}}}, 10, 12, "graph.png")
+	assert.Equal(t, "…s3_bucket.b", Pages[0].Rows[0].Label)
+}
+
+func TestPlotPages(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		log.Resources["module."+name+".aws_iam_role.r"] = ResourceMetric{ModificationStartedEvent: 0, ModificationCompletedEvent: 1, AfterStatus: Created}
+	}
+
+	// Room for 5 rows per page: 2 lanes of 2 rows each, as a third header
+	// would end up alone at the bottom
+	out, err := printGNUPlotOutput(log, 1000, graphVerticalMargin+5*graphRowHeight, "graph.png", "status", LayoutOptions{Swimlanes: true, From: 1, Pages: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, strings.Count(out, "set output \""))
+	assert.Contains(t, out, `set output "graph-5.png"`)
+	assert.Contains(t, out, `set title "Page 1 of 5"`)
+	assert.Contains(t, out, `set ytics noenhanced ("  aws_iam_role.r" 1, "module.b" 2, "  aws_iam_role.r" 3, "module.a" 4)`)
+	assert.Contains(t, out, `set ytics noenhanced ("  aws_iam_role.r" 1, "module.j" 2, "  aws_iam_role.r" 3, "module.i" 4)`)
+	assert.Contains(t, out, `set object rect from graph 0, first 0.5 to graph 1, first 2.5`)
+	assert.Contains(t, out, "set xrange [1:]")
+
+	// Pages are opt-in: by default, everything is drawn in the output file
+	out, err = printGNUPlotOutput(log, 1000, graphVerticalMargin+5*graphRowHeight, "graph.png", "status", LayoutOptions{Swimlanes: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, strings.Count(out, "set output \""))
+	assert.Contains(t, out, `set output "graph.png"`)
+	assert.NotContains(t, out, "set title")
+
+	_, err = printGNUPlotOutput(ParsedLog{}, 1000, 600, "graph.png", "status", LayoutOptions{})
+	assert.NotNil(t, err)
+}
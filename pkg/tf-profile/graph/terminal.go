@@ -0,0 +1,120 @@
+package tfprofile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/utils"
+	"github.com/fatih/color"
+)
+
+// Width of the terminal Gantt chart when the width of the terminal is unknown
+const defaultTerminalWidth = 120
+
+// Narrowest possible parts of a row in the terminal Gantt chart
+const (
+	minNameWidth     = 10
+	minBarWidth      = 10
+	durationWidth    = 7
+	terminalBarBlock = "█"
+)
+
+// Width of the terminal in columns: from $COLUMNS, from the terminal itself
+// or a default
+func terminalWidth() int {
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	if width := stdoutWidth(); width > 0 {
+		return width
+	}
+	return defaultTerminalWidth
+}
+
+// Shorten a resource name to a number of characters. The end of a name is the
+// most specific part, so the beginning is cut off.
+func truncateName(name string, width int) string {
+	if utf8.RuneCountInString(name) <= width {
+		return name
+	}
+	runes := []rune(name)
+	return "…" + string(runes[len(runes)-width+1:])
+}
+
+// Render a log as a Gantt chart of block characters, as wide as the terminal.
+// Resources are sorted like in the gnuplot output, with the first one at the
+// top. Failed resources are drawn in red, others in green.
+func printTerminal(tflog ParsedLog, width int) (string, error) {
+	Resources := []string{}
+	SortedResources := sortResourcesForGraph(tflog)
+	for idx := len(SortedResources) - 1; idx >= 0; idx-- {
+		if tflog.Resources[SortedResources[idx]].ModificationStartedEvent >= 0 {
+			Resources = append(Resources, SortedResources[idx])
+		}
+	}
+	if len(Resources) == 0 {
+		return "", errors.New("No resources were modified, nothing to draw.")
+	}
+
+	// Events at the left and right edge of the chart
+	First, Last := -1, 0
+	NameWidth := minNameWidth
+	for _, r := range Resources {
+		metrics := tflog.Resources[r]
+		if First == -1 || metrics.ModificationStartedEvent < First {
+			First = metrics.ModificationStartedEvent
+		}
+		Last = max(Last, metrics.ModificationCompletedEvent, metrics.ModificationStartedEvent+1)
+		NameWidth = max(NameWidth, utf8.RuneCountInString(r))
+	}
+
+	// Names get at most 40% of the width, the bars the rest
+	NameWidth = max(min(NameWidth, width*2/5), minNameWidth)
+	BarWidth := max(width-NameWidth-durationWidth-4, minBarWidth)
+	column := func(event int) int {
+		return (event - First) * BarWidth / (Last - First)
+	}
+
+	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+
+	var output strings.Builder
+	for _, r := range Resources {
+		metrics := tflog.Resources[r]
+		Start := column(metrics.ModificationStartedEvent)
+		End := min(max(column(metrics.ModificationCompletedEvent), Start+1), BarWidth)
+
+		bar := strings.Repeat(terminalBarBlock, End-Start)
+		if metrics.AfterStatus == Failed {
+			bar = red(bar)
+		} else {
+			bar = green(bar)
+		}
+		fmt.Fprintf(&output, "%-*s │%s%s%s %*s\n",
+			NameWidth, truncateName(r, NameWidth),
+			strings.Repeat(" ", Start), bar, strings.Repeat(" ", BarWidth-End),
+			durationWidth, FormatDuration(int(metrics.TotalTime/1000)),
+		)
+	}
+
+	// Time axis, in events: Terraform logs have no timestamps
+	fmt.Fprintf(&output, "%*s └%s\n", NameWidth, "", strings.Repeat("─", BarWidth))
+	Labels := []rune(strings.Repeat(" ", BarWidth+durationWidth+1))
+	for _, event := range []int{First, (First + Last) / 2, Last} {
+		label := strconv.Itoa(event)
+		col := min(column(event), len(Labels)-len(label))
+		copy(Labels[col:], []rune(label))
+	}
+	fmt.Fprintf(&output, "%*s  %s\n", NameWidth, "event", strings.TrimRight(string(Labels), " "))
+
+	_, err := fmt.Fprint(os.Stdout, output.String())
+	if err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
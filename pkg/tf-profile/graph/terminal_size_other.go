@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package tfprofile
+
+// Width of the terminal attached to stdout, or 0 if unknown
+func stdoutWidth() int {
+	return 0
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package tfprofile
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Width of the terminal attached to stdout, or 0 if unknown
+func stdoutWidth() int {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(ws.Col)
+}
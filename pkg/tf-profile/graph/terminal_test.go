@@ -0,0 +1,68 @@
+package tfprofile
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerminal(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{
+		"aws_iam_role.r":                   {TotalTime: 2000, ModificationStartedEvent: 0, ModificationCompletedEvent: 5, AfterStatus: Created},
+		"module.app.aws_s3_bucket.bucket":  {TotalTime: 9000, ModificationStartedEvent: 5, ModificationCompletedEvent: 10, AfterStatus: Failed},
+		"module.a.data.aws_region.current": {TotalTime: 0, ModificationStartedEvent: -1, ModificationCompletedEvent: -1},
+	}}
+	out, err := printTerminal(log, 50)
+	assert.Nil(t, err)
+
+	// 20 columns (40%) for names, 19 for bars
+	assert.Equal(t, []string{
+		"aws_iam_role.r       │█████████                2s",
+		"…ws_s3_bucket.bucket │         ██████████      9s",
+		"                     └───────────────────",
+		"               event  0        5         10",
+	}, strings.Split(strings.TrimSuffix(out, "\n"), "\n"))
+
+	_, err = printTerminal(ParsedLog{}, 50)
+	assert.NotNil(t, err)
+	assert.Equal(t, "…ket.b", truncateName("aws_s3_bucket.b", 6))
+	assert.Equal(t, "aws_s3_bucket.b", truncateName("aws_s3_bucket.b", 15))
+
+	t.Setenv("COLUMNS", "80")
+	assert.Equal(t, 80, terminalWidth())
+}
+
+func TestGraphFilters(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{
+		"aws_iam_role.r":                     {TotalTime: 2000},
+		"module.core[0].aws_s3_bucket.b":     {TotalTime: 9000},
+		"module.core[1].module.db.aws_rds.d": {TotalTime: 1000},
+		"module.core_network.aws_vpc.this":   {TotalTime: 5000},
+		"[network] module.core.aws_subnet.s": {TotalTime: 3000},
+	}}
+
+	Top := filterTop(log, 2)
+	assert.Equal(t, 2, len(Top.Resources))
+	assert.Contains(t, Top.Resources, "module.core[0].aws_s3_bucket.b")
+	assert.Contains(t, Top.Resources, "module.core_network.aws_vpc.this")
+	assert.Equal(t, log, filterTop(log, 0))
+
+	Core, err := filterModule(log, "module.core[*]")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(Core.Resources))
+	Core, err = filterModule(log, "module.core")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(Core.Resources))
+	Core, err = filterModule(log, "[network] module.core")
+	assert.Nil(t, err)
+	assert.Contains(t, Core.Resources, "[network] module.core.aws_subnet.s")
+	Core, err = filterModule(log, "module.core[1].module.db")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(Core.Resources))
+
+	err = Graph([]string{"../../../test/many_modules.log"}, GraphOptions{W: 1000, H: 600, Format: "terminal", Aggregate: true, AggregateLevels: "resource", ColorBy: "status", Top: 10, Module: "module.core*"})
+	assert.Nil(t, err)
+}
@@ -0,0 +1,164 @@
+package tfprofile
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/config"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+// Prefix of a grouping or colouring by label, e.g. "label:team"
+const LabelPrefix = "label:"
+
+// Value used for resources that do not have a label
+const NoLabel = "(none)"
+
+// A label rule from the config file, with its pattern compiled
+type compiledLabelRule struct {
+	re     *regexp.Regexp
+	labels map[string]string
+}
+
+var labelRules = []compiledLabelRule{}
+
+// Compile label rules (see config.LabelRule) and register them. Rules are
+// applied in order, so a later rule overrides the labels of an earlier one.
+func AddLabelRules(rules []LabelRule) error {
+	for idx, rule := range rules {
+		compiled, err := compileLabelRule(rule)
+		if err != nil {
+			return fmt.Errorf("Label rule %v: %v", idx+1, err)
+		}
+		labelRules = append(labelRules, compiled)
+	}
+	return nil
+}
+
+// Remove all label rules added with AddLabelRules
+func ResetLabelRules() {
+	labelRules = []compiledLabelRule{}
+}
+
+func compileLabelRule(rule LabelRule) (compiledLabelRule, error) {
+	if (rule.Match == "") == (rule.Regex == "") {
+		return compiledLabelRule{}, fmt.Errorf("expected either match or regex")
+	}
+	if len(rule.Labels) == 0 {
+		return compiledLabelRule{}, fmt.Errorf("no labels")
+	}
+
+	pattern := rule.Regex
+	if rule.Match != "" {
+		pattern = "^" + strings.ReplaceAll(regexp.QuoteMeta(rule.Match), `\*`, ".*") + "$"
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return compiledLabelRule{}, fmt.Errorf("invalid regex: %v", err)
+	}
+	return compiledLabelRule{re, rule.Labels}, nil
+}
+
+// Labels of a resource. Rules are matched against the address within its
+// Terragrunt unit as well as the full name, e.g. "[network] aws_vpc.this".
+func Labels(resource string) map[string]string {
+	_, address := SplitUnit(resource)
+	result := map[string]string{}
+	for _, rule := range labelRules {
+		if !rule.re.MatchString(address) && !rule.re.MatchString(resource) {
+			continue
+		}
+		for key, value := range rule.labels {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// Value of one label of a resource, or NoLabel
+func Label(resource string, key string) string {
+	value, ok := Labels(resource)[key]
+	if !ok {
+		return NoLabel
+	}
+	return value
+}
+
+// Returns the label key of a grouping like "label:team", if it is one
+func LabelKey(by string) (string, bool) {
+	if !strings.HasPrefix(by, LabelPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(by, LabelPrefix), true
+}
+
+// Keep only the resources that match all selectors. A selector is either
+// "key=value" (the label has that value) or "key" (the label is set).
+func FilterLabels(log ParsedLog, selectors []string) (ParsedLog, error) {
+	if len(selectors) == 0 {
+		return log, nil
+	}
+	for _, selector := range selectors {
+		if strings.HasPrefix(selector, "=") || selector == "" {
+			return ParsedLog{}, fmt.Errorf("Invalid label selector %q (expected key=value or key)", selector)
+		}
+	}
+
+	New := log
+	New.Resources = map[string]ResourceMetric{}
+	for resource, metric := range log.Resources {
+		if matchesSelectors(Labels(resource), selectors) {
+			New.Resources[resource] = metric
+		}
+	}
+	if len(New.Resources) == 0 && len(log.Resources) > 0 {
+		return ParsedLog{}, noMatchError(log, selectors)
+	}
+	return New, nil
+}
+
+// Explain which values the labels of the selectors do have, e.g. a typo in
+// "team=netpos" results in "No resources match the label selectors [team=netpos]
+// (team: data, netops)".
+func noMatchError(log ParsedLog, selectors []string) error {
+	Available := []string{}
+	for _, selector := range selectors {
+		key, _, _ := strings.Cut(selector, "=")
+		Values := LabelValues(log, key)
+		if len(Values) == 0 {
+			Available = append(Available, fmt.Sprintf("%v: no resources have this label", key))
+		} else {
+			Available = append(Available, fmt.Sprintf("%v: %v", key, strings.Join(Values, ", ")))
+		}
+	}
+	return fmt.Errorf("No resources match the label selectors %v (%v)", selectors, strings.Join(Available, "; "))
+}
+
+func matchesSelectors(labels map[string]string, selectors []string) bool {
+	for _, selector := range selectors {
+		key, value, hasValue := strings.Cut(selector, "=")
+		actual, ok := labels[key]
+		if !ok || (hasValue && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// All values of a label in a log, sorted. NoLabel is not included.
+func LabelValues(log ParsedLog, key string) []string {
+	Seen := map[string]bool{}
+	for resource := range log.Resources {
+		if value, ok := Labels(resource)[key]; ok {
+			Seen[value] = true
+		}
+	}
+	Values := []string{}
+	for value := range Seen {
+		Values = append(Values, value)
+	}
+	sort.Strings(Values)
+	return Values
+}
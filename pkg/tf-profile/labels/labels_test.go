@@ -0,0 +1,85 @@
+package tfprofile
+
+import (
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/config"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Register label rules for the duration of a test
+func withLabelRules(t *testing.T, rules []LabelRule) {
+	saved := labelRules
+	t.Cleanup(func() { labelRules = saved })
+	assert.Nil(t, AddLabelRules(rules))
+}
+
+func TestLabels(t *testing.T) {
+	config, err := LoadConfig("../../../test/labels.yaml")
+	assert.Nil(t, err)
+	withLabelRules(t, config.Labels)
+
+	assert.Equal(t, map[string]string{"team": "platform"}, Labels("module.core[0].module.role[1].time_sleep.bar"))
+	assert.Equal(t, map[string]string{"team": "analytics", "component": "dbt"}, Labels("module.applications[0].module.dbt[0].time_sleep.bar[*]"))
+	assert.Equal(t, map[string]string{}, Labels("time_sleep.foo[*]"))
+
+	// Globs must match the whole address, regexes any part of it
+	assert.Equal(t, NoLabel, Label("module.platform.module.core.aws_vpc.this", "team"))
+	assert.Equal(t, "dbt", Label("[data] module.dbt.aws_s3_bucket.b", "component"))
+	assert.Equal(t, "platform", Label("[network] module.core.aws_vpc.this", "team"))
+}
+
+func TestInvalidLabelRules(t *testing.T) {
+	defer func(saved []compiledLabelRule) { labelRules = saved }(labelRules)
+
+	assert.NotNil(t, AddLabelRules([]LabelRule{{Labels: map[string]string{"team": "a"}}}))
+	assert.NotNil(t, AddLabelRules([]LabelRule{{Match: "a*", Regex: "a", Labels: map[string]string{"team": "a"}}}))
+	assert.NotNil(t, AddLabelRules([]LabelRule{{Match: "a*"}}))
+	assert.NotNil(t, AddLabelRules([]LabelRule{{Regex: "a[", Labels: map[string]string{"team": "a"}}}))
+}
+
+func TestLabelKey(t *testing.T) {
+	key, ok := LabelKey("label:team")
+	assert.True(t, ok)
+	assert.Equal(t, "team", key)
+	_, ok = LabelKey("module")
+	assert.False(t, ok)
+}
+
+func TestFilterLabels(t *testing.T) {
+	withLabelRules(t, []LabelRule{
+		{Match: "module.network*", Labels: map[string]string{"team": "netops"}},
+		{Match: "*aws_rds*", Labels: map[string]string{"component": "db"}},
+		{Match: "module.data.aws_rds*", Labels: map[string]string{"team": "data"}},
+	})
+	log := ParsedLog{Resources: map[string]ResourceMetric{
+		"module.network.aws_vpc.this":          {NumCalls: 1},
+		"module.network.aws_rds_cluster.audit": {NumCalls: 1},
+		"module.data.aws_rds_cluster.main":     {NumCalls: 1},
+		"aws_iam_role.r":                       {NumCalls: 1},
+	}}
+
+	Out, err := FilterLabels(log, []string{"team=netops"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(Out.Resources))
+
+	Out, err = FilterLabels(log, []string{"team=netops", "component"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(Out.Resources))
+	assert.Contains(t, Out.Resources, "module.network.aws_rds_cluster.audit")
+
+	Out, err = FilterLabels(log, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, log, Out)
+
+	_, err = FilterLabels(log, []string{"=netops"})
+	assert.NotNil(t, err)
+
+	// The error lists the values that do exist
+	_, err = FilterLabels(log, []string{"team=netpos", "owner"})
+	assert.Equal(t, "No resources match the label selectors [team=netpos owner] (team: data, netops; owner: no resources have this label)", err.Error())
+
+	assert.Equal(t, []string{"data", "netops"}, LabelValues(log, "team"))
+}
@@ -2,31 +2,38 @@ package tfprofile
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
 )
 
 var (
-	// All regexes that recognize interesting logs during the apply phase
-	resourceName = `[a-zA-Z0-9_.["\]\/:]*` // Simplified regex but it will do
+	// All matchers that recognize interesting logs during the apply phase
+	// A resource address: anything up to the first whitespace, except inside
+	// instance keys, which can contain spaces, e.g. `a.b["kube-proxy v2"]`.
+	resourceName = `(?:[^\s\[]|\[[^\]]*\])*`
 
-	resourceCreated         = fmt.Sprintf("%v: Creation complete after", resourceName)
-	resourceCreationStarted = fmt.Sprintf("%v: Creating...", resourceName)
-	resourceOperationFailed = fmt.Sprintf("with %v,", resourceName)
+	resourceCreated         = newMatcher(": Creation complete after", fmt.Sprintf("%v: Creation complete after", resourceName))
+	resourceCreationStarted = newMatcher(": Creating...", fmt.Sprintf("%v: Creating...", resourceName))
+	resourceOperationFailed = newMatcher("with ", fmt.Sprintf("with %v,", resourceName))
+	resourceErrored         = newMatcher(" errored after ", fmt.Sprintf(`^(%v): (Creation|Destruction|Modifications|Read|Import) errored after (\S+)`, resourceName))
 
-	resourceDestructionStarted = fmt.Sprintf("%v: Destroying...", resourceName)
-	resourceDestroyed          = fmt.Sprintf("%v: Destruction complete after", resourceName)
+	resourceDestructionStarted = newMatcher(": Destroying...", fmt.Sprintf("%v: Destroying...", resourceName))
+	resourceDestroyed          = newMatcher(": Destruction complete after", fmt.Sprintf("%v: Destruction complete after", resourceName))
 
-	resourceModificationStarted = fmt.Sprintf("%v: Modifying...", resourceName)
-	resourceModified            = fmt.Sprintf("%v: Modifications complete after", resourceName)
+	resourceModificationStarted = newMatcher(": Modifying...", fmt.Sprintf("%v: Modifying...", resourceName))
+	resourceModified            = newMatcher(": Modifications complete after", fmt.Sprintf("%v: Modifications complete after", resourceName))
+
+	resourceImportStarted = newMatcher(": Importing...", fmt.Sprintf("%v: Importing...", resourceName))
+	resourceImported      = newMatcher(": Import complete", fmt.Sprintf("%v: Import complete", resourceName))
+
+	applySummary = newMatcher(" complete! Resources: ", `^(Apply|Destroy) complete! Resources: (.*)\.$`)
 )
 
 // Handle line that indicates creation of a resource was completed. E.g:
 // resource: Creation complete after 1s [id=2023-04-09T18:17:33Z]
 func parseResourceCreated(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(resourceCreated, Line)
+	match := resourceCreated.Match(Line)
 	if !match {
 		return false, nil
 	}
@@ -52,15 +59,17 @@ func parseResourceCreated(Line string, log *ParsedLog) (bool, error) {
 	log.SetModificationCompletedEvent(resource, log.CurrentEvent)
 	log.SetModificationCompletedIndex(resource, log.CurrentModificationEndedIndex)
 
+	log.ApplyCounts.Add += 1
 	log.CurrentModificationEndedIndex += 1
 	log.CurrentEvent += 1
+	log.Emit(Event{Type: ResourceCompleted, Resource: resource, Operation: Create, Duration: createDuration})
 	return true, nil
 }
 
 // Handle line that indicates the creation of a resource was started. E.g:
 // aws_ssm_parameter.bad2[2]: Creating...
 func parseResourceCreationStarted(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(resourceCreationStarted, Line)
+	match := resourceCreationStarted.Match(Line)
 	if !match {
 		return false, nil
 	}
@@ -77,16 +86,19 @@ func parseResourceCreationStarted(Line string, log *ParsedLog) (bool, error) {
 	log.SetModificationStartedEvent(tokens[0], log.CurrentEvent)
 	log.CurrentModificationStartedIndex += 1
 	log.CurrentEvent += 1
+	log.Emit(Event{Type: ResourceStarted, Resource: tokens[0], Operation: Create})
 	return true, nil
 }
 
 // Handle line that indicates resource modifications failed. E.g:
 // Error: creating SSM Parameter (/slash/at/end1/): ValidationException: Something something
-//	 status code: 400, request id: 77765932-a8b2-48bf-abe2-71a151da56ea
-//	 with aws_ssm_parameter.bad2[1],
+//
+//	status code: 400, request id: 77765932-a8b2-48bf-abe2-71a151da56ea
+//	with aws_ssm_parameter.bad2[1],
+//
 // In practice we just detect the "with <resource_name>", as we only receive one line of context
 func parseResourceCreationFailed(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(resourceOperationFailed, Line)
+	match := resourceOperationFailed.Match(Line)
 	if !match {
 		return false, nil
 	}
@@ -102,14 +114,45 @@ func parseResourceCreationFailed(Line string, log *ParsedLog) (bool, error) {
 
 	// Knowing the resource whose modifications failed, insert everything in the log
 	// TODO: dependin on the operation, Failed is not always correct. E.g. destroy fails => Created
+	// The failure was already reported if Terraform printed an "errored" line
+	// after the resource was last started
+	metric := log.Resources[resource]
+	if metric.AfterStatus == Failed && metric.ModificationCompletedEvent > metric.ModificationStartedEvent {
+		return true, nil
+	}
 	log.SetAfterStatus(resource, Failed)
+	log.Emit(Event{Type: ResourceFailed, Resource: resource, Operation: log.Resources[resource].Operation})
+	return true, nil
+}
+
+// Handle line that indicates an operation on a resource failed, printed before
+// the error itself (see parseResourceCreationFailed). E.g:
+// aws_s3_bucket.b: Creation errored after 10s
+func parseResourceErrored(Line string, log *ParsedLog) (bool, error) {
+	match := resourceErrored.Match(Line)
+	if !match {
+		return false, nil
+	}
+	tokens := resourceErrored.re.FindStringSubmatch(Line)
+	resource := tokens[1]
+	Duration := parseCreateDurationString(tokens[3])
+
+	// We know the resource and the duration, insert everything into the log
+	log.SetTotalTime(resource, Duration)
+	log.SetAfterStatus(resource, Failed)
+	log.SetModificationCompletedEvent(resource, log.CurrentEvent)
+	log.SetModificationCompletedIndex(resource, log.CurrentModificationEndedIndex)
+
+	log.CurrentModificationEndedIndex += 1
+	log.CurrentEvent += 1
+	log.Emit(Event{Type: ResourceFailed, Resource: resource, Operation: log.Resources[resource].Operation, Duration: Duration})
 	return true, nil
 }
 
 // Handle line that indicates the destruction of a resource was started. E.g:
 // aws_ssm_parameter.bad2[2]: Destroying...
 func parseResourceDestructionStarted(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(resourceDestructionStarted, Line)
+	match := resourceDestructionStarted.Match(Line)
 	if !match {
 		return false, nil
 	}
@@ -126,13 +169,14 @@ func parseResourceDestructionStarted(Line string, log *ParsedLog) (bool, error)
 	log.SetModificationCompletedIndex(tokens[0], log.CurrentModificationEndedIndex)
 	log.CurrentModificationStartedIndex += 1
 	log.CurrentEvent += 1
+	log.Emit(Event{Type: ResourceStarted, Resource: tokens[0], Operation: Destroy})
 	return true, nil
 }
 
 // Handle line that indicates deletion of a resource was completed. E.g:
 // resource: Destruction complete after 1s [id=2023-04-09T18:17:33Z]
 func parseResourceDestroyed(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(resourceDestroyed, Line)
+	match := resourceDestroyed.Match(Line)
 	if !match {
 		return false, nil
 	}
@@ -154,15 +198,17 @@ func parseResourceDestroyed(Line string, log *ParsedLog) (bool, error) {
 	log.SetModificationCompletedEvent(resource, log.CurrentEvent)
 	log.SetModificationCompletedIndex(resource, log.CurrentModificationEndedIndex)
 
+	log.ApplyCounts.Destroy += 1
 	log.CurrentModificationEndedIndex += 1
 	log.CurrentEvent += 1
+	log.Emit(Event{Type: ResourceCompleted, Resource: resource, Operation: Destroy, Duration: createDuration})
 	return true, nil
 }
 
 // Handle line that indicates the destruction of a resource was started. E.g:
 // aws_ssm_parameter.bad2[2]: Destroying...
 func parseResourceModificationStarted(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(resourceModificationStarted, Line)
+	match := resourceModificationStarted.Match(Line)
 	if !match {
 		return false, nil
 	}
@@ -179,13 +225,14 @@ func parseResourceModificationStarted(Line string, log *ParsedLog) (bool, error)
 	log.SetModificationStartedIndex(tokens[0], log.CurrentModificationStartedIndex)
 	log.CurrentModificationStartedIndex += 1
 	log.CurrentEvent += 1
+	log.Emit(Event{Type: ResourceStarted, Resource: tokens[0], Operation: Modify})
 	return true, nil
 }
 
 // Handle line that indicates modification of a resource was completed. E.g:
 // resource: Destruction complete after 1s [id=2023-04-09T18:17:33Z]
 func parseResourceModified(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(resourceModified, Line)
+	match := resourceModified.Match(Line)
 	if !match {
 		return false, nil
 	}
@@ -211,7 +258,88 @@ func parseResourceModified(Line string, log *ParsedLog) (bool, error) {
 	log.SetModificationCompletedEvent(resource, log.CurrentEvent)
 	log.SetModificationCompletedIndex(resource, log.CurrentModificationEndedIndex)
 
+	log.ApplyCounts.Change += 1
+	log.CurrentModificationEndedIndex += 1
+	log.CurrentEvent += 1
+	log.Emit(Event{Type: ResourceCompleted, Resource: resource, Operation: Modify, Duration: Duration})
+	return true, nil
+}
+
+// Handle line that indicates the import of a resource was started. E.g:
+// aws_ssm_parameter.p1: Importing... [id=p1]
+func parseResourceImportStarted(Line string, log *ParsedLog) (bool, error) {
+	match := resourceImportStarted.Match(Line)
+	if !match {
+		return false, nil
+	}
+	tokens := strings.Split(Line, ": Importing...")
+	if len(tokens) < 2 {
+		msg := fmt.Sprintf("Unable to parse resource import line: %v\n", Line)
+		return false, &LineParseError{Msg: msg}
+	}
+
+	// Knowing the resource whose import started, insert everything in the log
+	log.RegisterNewResource(tokens[0])
+	log.SetOperation(tokens[0], Import)
+	log.SetModificationStartedEvent(tokens[0], log.CurrentEvent)
+	log.SetModificationStartedIndex(tokens[0], log.CurrentModificationStartedIndex)
+	log.CurrentModificationStartedIndex += 1
+	log.CurrentEvent += 1
+	log.Emit(Event{Type: ResourceStarted, Resource: tokens[0], Operation: Import})
+	return true, nil
+}
+
+// Handle line that indicates the import of a resource was completed. Terraform
+// does not always print a duration for imports. E.g:
+// aws_ssm_parameter.p1: Import complete [id=p1]
+// aws_ssm_parameter.p1: Import complete after 2s [id=p1]
+func parseResourceImported(Line string, log *ParsedLog) (bool, error) {
+	match := resourceImported.Match(Line)
+	if !match {
+		return false, nil
+	}
+
+	tokens := strings.Split(Line, ": Import complete")
+	if len(tokens) < 2 {
+		msg := fmt.Sprintf("Unable to parse resource import line: %v\n", Line)
+		return false, &LineParseError{Msg: msg}
+	}
+	resource := tokens[0]
+
+	// Only some versions of Terraform print " after ...s [id=...]"
+	Duration := float64(0)
+	if strings.HasPrefix(tokens[1], " after ") {
+		tokens2 := strings.Split(strings.TrimPrefix(tokens[1], " after "), " ")
+		Duration = parseCreateDurationString(tokens2[0])
+	}
+
+	// We know the resource and the duration, insert everything into the log
+	log.SetTotalTime(resource, Duration)
+	log.SetAfterStatus(resource, Created)
+	log.SetModificationCompletedEvent(resource, log.CurrentEvent)
+	log.SetModificationCompletedIndex(resource, log.CurrentModificationEndedIndex)
+
+	log.ApplyCounts.Import += 1
 	log.CurrentModificationEndedIndex += 1
 	log.CurrentEvent += 1
+	log.Emit(Event{Type: ResourceCompleted, Resource: resource, Operation: Import, Duration: Duration})
+	return true, nil
+}
+
+// Handle the summary line at the end of an apply or destroy. E.g:
+// "Apply complete! Resources: 3 added, 1 changed, 4 destroyed."
+// "Destroy complete! Resources: 4 destroyed."
+func parseApplySummary(Line string, log *ParsedLog) (bool, error) {
+	match := applySummary.Match(Line)
+	if !match {
+		return false, nil
+	}
+	tokens := strings.Split(strings.TrimSuffix(Line, "."), "Resources: ")
+	counts, err := parseChangeCounts(tokens[1], ApplyLabels)
+	if err != nil {
+		return false, err
+	}
+	log.ApplySummary = &counts
+	log.Emit(Event{Type: Summary, Counts: counts})
 	return true, nil
 }
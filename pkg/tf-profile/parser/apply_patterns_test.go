@@ -34,6 +34,30 @@ func TestParseCreateFailed(t *testing.T) {
 	assert.Equal(t, Failed, log.Resources["foo"].AfterStatus)
 }
 
+func TestParseErrored(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+
+	modified, err := parseResourceCreationStarted("foo: Creating...", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	log.TakeEvents()
+
+	modified, err = parseResourceErrored("foo: Creation errored after 1m10s", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(70000), log.Resources["foo"].TotalTime)
+	assert.Equal(t, Failed, log.Resources["foo"].AfterStatus)
+	assert.Equal(t, 1, log.Resources["foo"].ModificationCompletedEvent)
+
+	// The error that follows does not report the failure again
+	modified, err = parseResourceCreationFailed("with foo,", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	Events := log.TakeEvents()
+	assert.Equal(t, 1, len(Events))
+	assert.Equal(t, ResourceFailed, Events[0].Type)
+}
+
 func TestResourceDestruction(t *testing.T) {
 	log := ParsedLog{Resources: map[string]ResourceMetric{}}
 
@@ -61,3 +85,38 @@ func TestResourceModification(t *testing.T) {
 	assert.Equal(t, float64(10000), log.Resources["foo"].TotalTime)
 	assert.Equal(t, Created, log.Resources["foo"].AfterStatus)
 }
+
+func TestResourceImport(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+
+	modified, err := parseResourceImportStarted("foo: Importing... [id=foo]", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, Import, log.Resources["foo"].Operation)
+
+	modified, err = parseResourceImported("foo: Import complete [id=foo]", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(0), log.Resources["foo"].TotalTime)
+	assert.Equal(t, Created, log.Resources["foo"].AfterStatus)
+
+	parseResourceImportStarted("bar: Importing... [id=bar]", &log)
+	modified, err = parseResourceImported("bar: Import complete after 1m1s [id=bar]", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(61000), log.Resources["bar"].TotalTime)
+}
+
+func TestParseApplySummary(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+
+	modified, err := parseApplySummary("Apply complete! Resources: 1 imported, 3 added, 1 changed, 4 destroyed, 1 forgotten.", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, ChangeCounts{Import: 1, Add: 3, Change: 1, Destroy: 4, Forget: 1}, *log.ApplySummary)
+
+	modified, err = parseApplySummary("Destroy complete! Resources: 4 destroyed.", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, ChangeCounts{Destroy: 4}, *log.ApplySummary)
+}
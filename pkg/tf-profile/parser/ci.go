@@ -0,0 +1,119 @@
+package tfprofile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CI systems wrap every line of a job log in an envelope: a timestamp, and
+// markers for collapsible sections. Envelopes are stripped before a line is
+// parsed, see stripCIEnvelope.
+const (
+	CIAuto    = "auto"
+	CINone    = "none"
+	CIGitHub  = "github"
+	CIGitLab  = "gitlab"
+	CIJenkins = "jenkins"
+	CIAzure   = "azure"
+)
+
+var (
+	// Flavor of CI envelope to strip, set with SetCIFlavor
+	ciFlavor = CIAuto
+
+	// GitHub Actions and Azure DevOps: "2024-01-15T10:23:45.1234567Z aws_vpc.this: Creating..."
+	// GitLab with timestamps enabled adds a stream id: "2024-01-15T10:23:45.123456Z 00O aws_vpc.this: Creating..."
+	isoTimestamp = regexp.MustCompile(`^(?P<time>\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?Z) (?:[0-9a-f]{2}[OE]\+? )?`)
+	// Markers of GitHub Actions and Azure DevOps, e.g. "##[group]Run terraform apply"
+	// or "##vso[task.setvariable variable=x]y"
+	hashMarker = regexp.MustCompile(`^##(?:\[(?P<name>[a-z]+)\]|vso\[)`)
+	// GitLab section markers: "section_start:1705314225:step_script\r"
+	gitlabSection = regexp.MustCompile(`^section_(?:start|end):\d+:[^\s\r]+\r?`)
+	// Jenkins timestamper plugin: "[2024-01-15T10:23:45.123Z] " or "10:23:45 "
+	jenkinsTimestamp = regexp.MustCompile(`^\[(?P<time>\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2})?)\] `)
+	jenkinsClock     = regexp.MustCompile(`^\d{2}:\d{2}:\d{2} `)
+)
+
+// Markers of GitHub Actions and Azure DevOps that start a line without Terraform
+// output. Other markers (e.g. "##[error]") are followed by output of the job.
+var ciMarkersWithoutOutput = map[string]bool{
+	"group":    true,
+	"endgroup": true,
+	"section":  true,
+	"command":  true,
+}
+
+// Select the CI envelope to strip from every line: one of auto, none, github,
+// gitlab, jenkins or azure. With auto (the default), all envelopes are
+// recognized, except the short "HH:MM:SS" timestamps of Jenkins, which are too
+// ambiguous.
+func SetCIFlavor(flavor string) error {
+	switch strings.ToLower(flavor) {
+	case CIAuto, CINone, CIGitHub, CIGitLab, CIJenkins, CIAzure:
+		ciFlavor = strings.ToLower(flavor)
+		return nil
+	default:
+		return fmt.Errorf("Unknown CI flavor %v (expected auto, none, github, gitlab, jenkins or azure)", flavor)
+	}
+}
+
+// Strip the CI envelope from a line. Returns the line without envelope and the
+// time at which the CI system logged it (zero if unknown). found is false for
+// lines without Terraform output, e.g. the start of a collapsible section.
+func stripCIEnvelope(line string) (output string, at time.Time, found bool) {
+	if ciFlavor == CINone || line == "" {
+		return line, time.Time{}, true
+	}
+	github := ciFlavor == CIAuto || ciFlavor == CIGitHub || ciFlavor == CIAzure
+	gitlab := ciFlavor == CIAuto || ciFlavor == CIGitLab
+	jenkins := ciFlavor == CIAuto || ciFlavor == CIJenkins
+
+	if (github || gitlab) && isDigit(line[0]) {
+		if match := isoTimestamp.FindStringSubmatch(line); match != nil {
+			at, _ = time.Parse(time.RFC3339Nano, match[1])
+			line = line[len(match[0]):]
+		}
+	}
+	if jenkins {
+		if match := jenkinsTimestamp.FindStringSubmatch(line); match != nil {
+			at = parseJenkinsTime(match[1])
+			line = line[len(match[0]):]
+		} else if ciFlavor == CIJenkins {
+			line = line[len(jenkinsClock.FindString(line)):]
+		}
+		if strings.HasPrefix(line, "[Pipeline] ") {
+			return "", at, false
+		}
+	}
+	if github && strings.HasPrefix(line, "##") {
+		if match := hashMarker.FindStringSubmatch(line); match != nil {
+			if match[1] == "" || ciMarkersWithoutOutput[match[1]] {
+				return "", at, false
+			}
+			line = line[len(match[0]):]
+		}
+	}
+	if gitlab && strings.HasPrefix(line, "section_") && gitlabSection.MatchString(line) {
+		return "", at, false
+	}
+	return line, at, true
+}
+
+// Parse a timestamp added by the Jenkins timestamper plugin, zero if the
+// format is not recognized
+func parseJenkinsTime(in string) time.Time {
+	layouts := []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999-0700", "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999"}
+	for _, layout := range layouts {
+		at, err := time.Parse(layout, in)
+		if err == nil {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
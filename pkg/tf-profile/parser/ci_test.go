@@ -0,0 +1,63 @@
+package tfprofile
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripCIEnvelope(t *testing.T) {
+	at := time.Date(2024, 1, 15, 10, 23, 45, 123456700, time.UTC)
+	tests := []struct {
+		flavor string
+		line   string
+		output string
+		at     time.Time
+		found  bool
+	}{
+		// GitHub Actions and Azure DevOps
+		{CIAuto, "2024-01-15T10:23:45.1234567Z aws_vpc.this: Creating...", "aws_vpc.this: Creating...", at, true},
+		{CIAuto, "2024-01-15T10:23:45.1234567Z ##[group]Run terraform apply", "", at, false},
+		{CIAuto, "2024-01-15T10:23:45.1234567Z ##[error]Error: creating VPC", "Error: creating VPC", at, true},
+		{CIAzure, "2024-01-15T10:23:45.1234567Z ##[section]Starting: Terraform apply", "", at, false},
+		{CIAzure, "##vso[task.setvariable variable=plan]1", "", time.Time{}, false},
+		// GitLab
+		{CIGitLab, "2024-01-15T10:23:45.1234567Z 01O aws_vpc.this: Creating...", "aws_vpc.this: Creating...", at, true},
+		{CIAuto, "section_start:1705314225:step_script\r", "", time.Time{}, false},
+		// Jenkins
+		{CIAuto, "[2024-01-15T10:23:45.1234567Z] aws_vpc.this: Creating...", "aws_vpc.this: Creating...", at, true},
+		{CIAuto, "[Pipeline] sh", "", time.Time{}, false},
+		{CIJenkins, "10:23:45 aws_vpc.this: Creating...", "aws_vpc.this: Creating...", time.Time{}, true},
+		{CIAuto, "10:23:45 aws_vpc.this: Creating...", "10:23:45 aws_vpc.this: Creating...", time.Time{}, true},
+		// Envelopes of other flavors are left alone
+		{CIJenkins, "2024-01-15T10:23:45.1234567Z aws_vpc.this: Creating...", "2024-01-15T10:23:45.1234567Z aws_vpc.this: Creating...", time.Time{}, true},
+		{CINone, "##[group]Run terraform apply", "##[group]Run terraform apply", time.Time{}, true},
+		// Plain Terraform output
+		{CIAuto, "aws_vpc.this: Creating...", "aws_vpc.this: Creating...", time.Time{}, true},
+		{CIAuto, "", "", time.Time{}, true},
+	}
+	defer SetCIFlavor(CIAuto)
+	for _, test := range tests {
+		assert.Nil(t, SetCIFlavor(test.flavor))
+		output, at, found := stripCIEnvelope(test.line)
+		assert.Equal(t, test.output, output, test.line)
+		assert.True(t, test.at.Equal(at), test.line)
+		assert.Equal(t, test.found, found, test.line)
+	}
+	assert.NotNil(t, SetCIFlavor("travis"))
+}
+
+func TestStreamCITimestamps(t *testing.T) {
+	file, _ := os.Open("../../../test/github_actions.log")
+	stream := NewStream(file)
+
+	event, err := stream.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, PhaseChanged, event.Type)
+	assert.Equal(t, 10, event.Line)
+	assert.Equal(t, time.Date(2024, 1, 15, 10, 23, 57, 123456000, time.UTC), event.Time)
+}
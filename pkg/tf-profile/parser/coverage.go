@@ -0,0 +1,77 @@
+package tfprofile
+
+import (
+	"bufio"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+var (
+	// Generic shapes of the lines Terraform prints for resource events. These
+	// deliberately accept any address, so a line that matches one of them but
+	// is not claimed by any parse function points to a gap in the parser.
+	eventShapes = []*regexp.Regexp{
+		regexp.MustCompile(`^\S.*: (Refreshing state|Reading|Creating|Destroying|Modifying|Importing|Preparing import)\.\.\.`),
+		regexp.MustCompile(`^\S.*: (Read|Creation|Destruction|Modifications|Import) (complete|errored)`),
+		regexp.MustCompile(`^\s*# \S.* (will be created|will be destroyed|will be updated in-place|must be replaced|is tainted, so must be replaced|will be replaced, as requested|has moved to .+|will be imported|will no longer be managed by (Terraform|OpenTofu)|will be removed from the OpenTofu state but will not be destroyed|has changed|has been deleted|will be read during apply)$`),
+		regexp.MustCompile(`^\s*# \((moved|imported) from .+\)$`),
+	}
+)
+
+// Parse coverage of a log: how many lines every parse function recognized
+// and which lines look like Terraform events, but were not recognized.
+type Coverage struct {
+	Lines        int
+	Recognized   int
+	Matched      map[string]int
+	Unrecognized []UnrecognizedLine
+}
+
+// Record that a parse function recognized a line
+func (c *Coverage) addMatch(name string) {
+	if c.Matched == nil {
+		c.Matched = map[string]int{}
+	}
+	c.Matched[name] += 1
+}
+
+// Parse a log and report the parse coverage along with the parsed log.
+// Used by `tf-profile doctor` to find lines the parser does not understand.
+func ParseCoverage(file *bufio.Scanner) (ParsedLog, Coverage, error) {
+	coverage := Coverage{Matched: map[string]int{}, Unrecognized: []UnrecognizedLine{}}
+	stream := newStream(file)
+	stream.coverage = &coverage
+
+	for stream.scan() {
+		coverage.Lines += 1
+		if stream.recognized {
+			coverage.Recognized += 1
+		} else if !stream.noise && looksLikeEvent(stream.output) {
+			coverage.Unrecognized = append(coverage.Unrecognized, UnrecognizedLine{Number: stream.lineNumber, Line: stream.line})
+		}
+	}
+	if stream.err != nil {
+		return ParsedLog{}, Coverage{}, stream.err
+	}
+	return stream.Log(), coverage, nil
+}
+
+// Returns true if a line has the shape of a Terraform resource event
+func looksLikeEvent(line string) bool {
+	for _, shape := range eventShapes {
+		if shape.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// Name of a parse function, e.g. "parseResourceCreated"
+func parserName(f ParseFunction) string {
+	name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
@@ -0,0 +1,63 @@
+package tfprofile
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLooksLikeEvent(t *testing.T) {
+	assert.True(t, looksLikeEvent("aws_ssm_parameter.p1: Creation errored after 2s"))
+	assert.True(t, looksLikeEvent(`  # module.a["kube-proxy"].aws_ssm_parameter.p1 will be created`))
+	assert.True(t, looksLikeEvent("  # (moved from aws_ssm_parameter.old)"))
+	assert.False(t, looksLikeEvent("aws_ssm_parameter.p1: Still creating... [10s elapsed]"))
+	assert.False(t, looksLikeEvent("Terraform will perform the following actions:"))
+	assert.False(t, looksLikeEvent(`      + name  = "p1"`))
+}
+
+func TestParseCoverage(t *testing.T) {
+	file, _ := os.Open("../../../test/all_operations.log")
+	log, coverage, err := ParseCoverage(bufio.NewScanner(file))
+	assert.Nil(t, err)
+	assert.True(t, log.ContainsApply)
+	assert.Equal(t, 112, coverage.Lines)
+	assert.Equal(t, 6, coverage.Matched["refreshParser"])
+	assert.Equal(t, 3, coverage.Matched["parseResourceCreated"])
+	assert.Equal(t, []UnrecognizedLine{}, coverage.Unrecognized)
+
+	in := "aws_ssm_parameter.p1: Creating...\n  # (imported from \"p1\")\n"
+	_, coverage, err = ParseCoverage(bufio.NewScanner(strings.NewReader(in)))
+	assert.Nil(t, err)
+	assert.Equal(t, []UnrecognizedLine{{Number: 2, Line: "  # (imported from \"p1\")"}}, coverage.Unrecognized)
+}
+
+func TestStrictParse(t *testing.T) {
+	in := "aws_ssm_parameter.p1: Creating...\n  # (imported from \"p1\")\n"
+
+	_, _, err := ParseRuns(bufio.NewScanner(strings.NewReader(in)), false, false)
+	assert.Nil(t, err)
+
+	_, _, err = ParseRuns(bufio.NewScanner(strings.NewReader(in)), false, true)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "line 2: # (imported from \"p1\")")
+
+	// A failing apply is understood completely
+	in = "aws_s3_bucket.b: Creating...\naws_s3_bucket.b: Creation errored after 10s\n"
+	log, _, err := ParseRuns(bufio.NewScanner(strings.NewReader(in)), false, true)
+	assert.Nil(t, err)
+	assert.Equal(t, Failed, log.Resources["aws_s3_bucket.b"].AfterStatus)
+}
+
+func TestResourceNameWithSpaces(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+	modified, err := parsePlanWillBeCreated(`  # module.a["kube proxy"].aws_ssm_parameter.p1 will be created`, &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	_, ok := log.Resources[`module.a["kube proxy"].aws_ssm_parameter.p1`]
+	assert.True(t, ok)
+}
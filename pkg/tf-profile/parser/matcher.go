@@ -0,0 +1,26 @@
+package tfprofile
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A precompiled pattern to recognize a line in a Terraform log. Most lines in
+// a log do not match any pattern, so every matcher has a literal hint that
+// must occur in a matching line. The (much more expensive) regex is only
+// evaluated for lines that contain the hint.
+type matcher struct {
+	hint string
+	re   *regexp.Regexp
+}
+
+// Create a matcher. The hint must be a substring of every line that matches
+// the pattern, e.g. ": Creating..." for "<resource>: Creating...".
+func newMatcher(hint string, pattern string) matcher {
+	return matcher{hint: hint, re: regexp.MustCompile(pattern)}
+}
+
+// Returns true if the line matches the pattern
+func (m matcher) Match(line string) bool {
+	return strings.Contains(line, m.hint) && m.re.MatchString(line)
+}
@@ -10,12 +10,31 @@ import (
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
 )
 
-type parseFunction = func(Line string, log *ParsedLog) (bool, error)
+// A parse function inspects a single line of a log. If it recognizes the line,
+// it records what it learned in the log and returns true.
+type ParseFunction = func(Line string, log *ParsedLog) (bool, error)
 
-var RefreshParsers = []parseFunction{
+// A parse function registered with RegisterParser
+type registeredParser struct {
+	name  string
+	parse ParseFunction
+}
+
+// Built-in parse functions for every phase. More can be added with RegisterParser.
+var refreshParsers = []ParseFunction{
 	refreshParser,
+	parseDriftChanged,
+	parseDriftDeleted,
+}
+
+// Data sources are read during refresh, but also during apply when they
+// depend on resources that are created. These parsers are active in all phases.
+var dataSourceParsers = []ParseFunction{
+	parseDataSourceReading,
+	parseDataSourceRead,
 }
-var PlanParsers = []parseFunction{
+var planParsers = []ParseFunction{
+	parsePlanAttribute, // First, so lines in a plan block are never mistaken for other lines
 	parseStartPlan,
 	parsePlanTainted,
 	parsePlanExplicitReplace,
@@ -23,71 +42,197 @@ var PlanParsers = []parseFunction{
 	parsePlanWillBeModified,
 	parsePlanForcedReplace,
 	parsePlanWillBeCreated,
+	parsePlanMoved,
+	parsePlanMovedFrom,
+	parsePlanWillBeImported,
+	parsePlanImportedFrom,
+	parsePlanWillBeForgotten,
+	parsePlanWillBeRead,
+	parsePreparingImport,
+	parsePlanSummary,
 }
-var ApplyParsers = []parseFunction{
+var applyParsers = []ParseFunction{
 	parseResourceCreationStarted,
 	parseResourceCreated,
 	parseResourceCreationFailed,
+	parseResourceErrored,
 	parseResourceDestructionStarted,
 	parseResourceDestroyed,
 	parseResourceModificationStarted,
 	parseResourceModified,
+	parseResourceImportStarted,
+	parseResourceImported,
+	parseApplySummary,
+}
+
+// Parse functions registered with RegisterParser, by phase
+var registeredParsers = map[Phase][]registeredParser{}
+
+// Register an additional parse function, active during the given phase (see
+// parseLine for how phases are tracked). Registered parse functions are only
+// tried for lines that none of the built-in parse functions recognize, in the
+// order in which they were registered. The name identifies the parse function
+// in the output of `tf-profile doctor`.
+func RegisterParser(phase Phase, name string, f ParseFunction) {
+	registeredParsers[phase] = append(registeredParsers[phase], registeredParser{name: name, parse: f})
 }
 
 // Parse a Terraform log into a ParsedLog object. This function will
 // pass line by line over the file, apply parse functions (see above)
 // until one of them recognizes the line and extracts information. In
-// that case the line is considered "handled" and the next one is scanned
-// Possible optimization here: since Terraform has distinct refresh,
-// plan, apply phases we could skip parse functions of previous phases.
+// that case the line is considered "handled" and the next one is scanned.
+// Since Terraform has distinct refresh, plan and apply phases, parse
+// functions of earlier phases are skipped once a later phase has started.
 func Parse(file *bufio.Scanner, tee bool) (ParsedLog, error) {
-	tflog := ParsedLog{Resources: map[string]ResourceMetric{}}
+	tflog, _, err := parse(file, tee, false, false)
+	return tflog, err
+}
+
+// Parse a Terraform log that may contain multiple Terraform runs (e.g. a plan,
+// an apply and a retry of that apply). Returns the full log parsed as one,
+// as Parse would, and a separate ParsedLog for every run. See runs.go for how
+// the boundaries between runs are detected. For Terragrunt output, a run
+// contains the runs of all units that ran together (e.g. a `run-all apply`).
+// Every line is parsed once, so a run holds the metrics of its resources as
+// they were at the end of the run (except for provider calls, which are
+// counted per run). In strict mode, parsing fails when a line looks like a
+// Terraform event but no parse function recognizes it.
+func ParseRuns(file *bufio.Scanner, tee bool, strict bool) (ParsedLog, []ParsedLog, error) {
+	return parse(file, tee, true, strict)
+}
 
-	for file.Scan() {
-		line := RemoveTerminalFormatting(file.Text())
+func parse(file *bufio.Scanner, tee bool, split bool, strict bool) (ParsedLog, []ParsedLog, error) {
+	stream := newStream(file)
+	if split {
+		stream.splitter = newRunSplitter()
+	}
+	unrecognized := []UnrecognizedLine{}
 
+	for stream.scan() {
 		if tee {
-			fmt.Println(line)
+			fmt.Println(stream.line)
 		}
 
-		// Apply refresh parsers until one modifies the log
-		for _, f := range RefreshParsers {
-			modified, err := f(line, &tflog)
-			if err != nil {
-				return ParsedLog{}, err
-			}
-			if modified {
-				tflog.ContainsRefresh = true
-				break
-			}
+		if strict && !stream.recognized && !stream.noise && looksLikeEvent(stream.output) {
+			unrecognized = append(unrecognized, UnrecognizedLine{Number: stream.lineNumber, Line: stream.line})
 		}
+	}
+	if stream.err != nil {
+		return ParsedLog{}, nil, stream.err
+	}
 
-		// Apply plan parsers until one modifies the log
-		for _, f := range PlanParsers {
-			modified, err := f(line, &tflog)
-			if err != nil {
-				return ParsedLog{}, err
-			}
-			if modified {
-				tflog.ContainsPlan = true
-				break
-			}
+	runs := []ParsedLog{}
+	if split {
+		stream.splitter.cut(&stream.log, stream.units)
+		runs = stream.splitter.runs
+	}
+	if len(unrecognized) > 0 {
+		return ParsedLog{}, nil, &UnrecognizedLinesError{Lines: unrecognized}
+	}
+
+	// Summary lines only describe a single run, not the log as a whole
+	tflog := stream.Log()
+	if len(runs) > 1 {
+		tflog.PlanSummary = nil
+		tflog.ApplySummary = nil
+	}
+	return tflog, runs, nil
+}
+
+// Apply all parse functions to a single line. Returns true if any of them
+// recognized the line. If coverage is not nil, every match is recorded in it.
+func parseLine(line string, tflog *ParsedLog, coverage *Coverage) (bool, error) {
+	detectTool(line, tflog)
+	updatePhase(line, tflog)
+
+	// Apply refresh parsers until one modifies the log
+	recognized := false
+	var err error
+	if tflog.CurrentPhase == RefreshPhase {
+		recognized, err = tryParsers(refreshParsers, registeredParsers[RefreshPhase], line, tflog, coverage)
+		if err != nil {
+			return false, err
+		}
+	}
+	if !recognized {
+		recognized, err = tryParsers(dataSourceParsers, nil, line, tflog, coverage)
+		if err != nil {
+			return false, err
 		}
+		// Data sources read after the plan are part of the apply
+		if recognized && tflog.CurrentPhase != RefreshPhase {
+			tflog.ContainsApply = true
+			tflog.CurrentPhase = ApplyPhase
+		}
+	}
+	if recognized && tflog.CurrentPhase == RefreshPhase {
+		tflog.ContainsRefresh = true
+	}
+
+	// Apply plan parsers until one modifies the log
+	if tflog.CurrentPhase <= PlanPhase {
+		modified, err := tryParsers(planParsers, registeredParsers[PlanPhase], line, tflog, coverage)
+		if err != nil {
+			return false, err
+		}
+		if modified {
+			tflog.ContainsPlan = true
+			recognized = true
+		}
+	}
 
-		// Apply apply parsers until one modifies the log.
-		for _, f := range ApplyParsers {
-			modified, err := f(line, &tflog)
-			if err != nil {
-				return ParsedLog{}, err
+	// Apply apply parsers until one modifies the log
+	modified, err := tryParsers(applyParsers, registeredParsers[ApplyPhase], line, tflog, coverage)
+	if err != nil {
+		return false, err
+	}
+	if modified {
+		tflog.ContainsApply = true
+		tflog.CurrentPhase = ApplyPhase
+		recognized = true
+	}
+	return recognized, nil
+}
+
+// Apply parse functions to a line until one of them modifies the log. Registered
+// parse functions are only tried if no built-in parse function recognized the line.
+func tryParsers(parsers []ParseFunction, registered []registeredParser, line string, tflog *ParsedLog, coverage *Coverage) (bool, error) {
+	for _, f := range parsers {
+		modified, err := f(line, tflog)
+		if err != nil {
+			return false, err
+		}
+		if modified {
+			if coverage != nil {
+				coverage.addMatch(parserName(f))
 			}
-			if modified {
-				tflog.ContainsApply = true
-				break
+			return true, nil
+		}
+	}
+	for _, r := range registered {
+		modified, err := r.parse(line, tflog)
+		if err != nil {
+			return false, err
+		}
+		if modified {
+			if coverage != nil {
+				coverage.addMatch(r.name)
 			}
+			return true, nil
 		}
 	}
+	return false, nil
+}
 
-	return tflog, nil
+// Move to the phase a line belongs to. A run moves forward from refresh to
+// plan to apply, but a new run (e.g. a retry in the same log) starts over.
+func updatePhase(line string, tflog *ParsedLog) {
+	switch {
+	case strings.Contains(line, initializingBackend), refreshingState.Match(line):
+		tflog.CurrentPhase = RefreshPhase
+	case isStartPlan(line, tflog):
+		tflog.CurrentPhase = PlanPhase
+	}
 }
 
 // Convert a create duration string into milliseconds
@@ -112,3 +257,26 @@ func parseCreateDurationString(in string) float64 {
 		return float64(1000.0 * seconds)
 	}
 }
+
+// Convert a list of counts from a summary line into ChangeCounts, e.g.
+// "3 to add, 1 to change, 4 to destroy" or "3 added, 1 changed, 4 destroyed".
+// The labels determine which kind of change each count belongs to.
+func parseChangeCounts(in string, labels [5]string) (ChangeCounts, error) {
+	counts := [5]int{}
+	for _, item := range strings.Split(in, ", ") {
+		tokens := strings.SplitN(item, " ", 2)
+		if len(tokens) < 2 {
+			return ChangeCounts{}, &LineParseError{Msg: fmt.Sprintf("Unable to parse summary: %v\n", in)}
+		}
+		count, err := strconv.Atoi(tokens[0])
+		if err != nil {
+			return ChangeCounts{}, &LineParseError{Msg: fmt.Sprintf("Unable to parse summary: %v\n", in)}
+		}
+		for idx, label := range labels {
+			if tokens[1] == label {
+				counts[idx] = count
+			}
+		}
+	}
+	return ChangeCounts{Import: counts[0], Add: counts[1], Change: counts[2], Destroy: counts[3], Forget: counts[4]}, nil
+}
@@ -2,7 +2,9 @@ package tfprofile
 
 import (
 	"bufio"
+	"bytes"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -32,6 +34,9 @@ func TestFullParse(t *testing.T) {
 		DesiredStatus:              Created,
 		AfterStatus:                Created,
 		Operation:                  Create,
+		RefreshIndex:               -1,
+		RefreshEvent:               -1,
+		Drift:                      NoDrift,
 	}
 	if metrics != expected {
 		t.Fatalf("Expected %v, got %v\n", expected, metrics)
@@ -49,6 +54,9 @@ func TestFullParse(t *testing.T) {
 		DesiredStatus:              Created,
 		AfterStatus:                Created,
 		Operation:                  Create,
+		RefreshIndex:               -1,
+		RefreshEvent:               -1,
+		Drift:                      NoDrift,
 	}
 	if metrics2 != expected2 {
 		t.Fatalf("Expected %v, got %v\n", expected2, metrics2)
@@ -94,3 +102,136 @@ func TestParserSanityCheck(t *testing.T) {
 		}
 	}
 }
+
+func TestImportMoveParse(t *testing.T) {
+	file, _ := os.Open("../../../test/import_move.log")
+	s := bufio.NewScanner(file)
+
+	log, err := Parse(s, false)
+	assert.Nil(t, err)
+
+	assert.Equal(t, Import, log.Resources["aws_ssm_parameter.imported"].Operation)
+	assert.Equal(t, Created, log.Resources["aws_ssm_parameter.imported"].AfterStatus)
+	assert.Equal(t, Move, log.Resources["aws_ssm_parameter.new_name"].Operation)
+	assert.Equal(t, "aws_ssm_parameter.p1", log.Resources["aws_ssm_parameter.new_name"].MovedFrom)
+	assert.Equal(t, Modify, log.Resources["aws_ssm_parameter.renamed"].Operation)
+	assert.Equal(t, "aws_ssm_parameter.p2", log.Resources["aws_ssm_parameter.renamed"].MovedFrom)
+	assert.Equal(t, Forget, log.Resources["aws_ssm_parameter.forgotten"].Operation)
+}
+
+func TestParseRuns(t *testing.T) {
+	file, _ := os.Open("../../../test/multiple_runs.log")
+	s := bufio.NewScanner(file)
+
+	log, runs, err := ParseRuns(s, false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(runs))
+
+	// First run: plan only
+	assert.True(t, runs[0].ContainsRefresh)
+	assert.True(t, runs[0].ContainsPlan)
+	assert.False(t, runs[0].ContainsApply)
+
+	// Second run: failed apply
+	assert.True(t, runs[1].ContainsApply)
+	assert.Equal(t, Failed, runs[1].Resources["aws_ssm_parameter.p2"].AfterStatus)
+
+	// Third run: successful retry
+	assert.True(t, runs[2].ContainsApply)
+	assert.Equal(t, Created, runs[2].Resources["aws_ssm_parameter.p2"].AfterStatus)
+	assert.Equal(t, float64(3000), runs[2].Resources["aws_ssm_parameter.p2"].TotalTime)
+
+	// The full log is still parsed as a whole
+	assert.Equal(t, Created, log.Resources["aws_ssm_parameter.p2"].AfterStatus)
+}
+
+func TestParseSingleRun(t *testing.T) {
+	file, _ := os.Open("../../../test/all_operations.log")
+	s := bufio.NewScanner(file)
+
+	log, runs, err := ParseRuns(s, false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(runs))
+	assert.Equal(t, log.Resources, runs[0].Resources)
+}
+
+func TestParseRunsTerragrunt(t *testing.T) {
+	// The same `run-all apply`, twice
+	content, _ := os.ReadFile("../../../test/terragrunt.log")
+	s := bufio.NewScanner(bytes.NewReader(append(content, content...)))
+
+	_, runs, err := ParseRuns(s, false, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(runs))
+	for _, run := range runs {
+		assert.Equal(t, []string{"network", "queue"}, run.Units)
+		assert.Equal(t, 4, len(run.Resources))
+		assert.Equal(t, 4, len(run.PlannedChanges))
+		assert.Equal(t, Created, run.Resources["[network] aws_vpc.this"].AfterStatus)
+		assert.Equal(t, &ChangeCounts{Add: 4}, run.PlanSummary)
+		assert.Equal(t, &ChangeCounts{Add: 4}, run.ApplySummary)
+		assert.Equal(t, ChangeCounts{Add: 4}, run.ApplyCounts)
+		assert.True(t, run.ContainsPlan)
+		assert.True(t, run.ContainsApply)
+	}
+}
+
+func TestParseRunsTrace(t *testing.T) {
+	// The same apply with trace output, twice
+	content, _ := os.ReadFile("../../../test/trace.log")
+	s := bufio.NewScanner(bytes.NewReader(append(content, content...)))
+
+	log, runs, err := ParseRuns(s, false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(runs))
+	for _, run := range runs {
+		assert.True(t, run.ContainsTrace)
+		assert.Equal(t, ProviderRPC{Calls: 2, TotalTime: 2195}, run.ProviderRPCs["ApplyResourceChange"])
+		assert.Equal(t, 2, len(run.Resources))
+		assert.Equal(t, 3, run.Resources["aws_ssm_parameter.p1"].ProviderCalls)
+		assert.Equal(t, 2, run.Resources["aws_ssm_parameter.p2"].HTTPRetries)
+		assert.Equal(t, Created, run.Resources["aws_ssm_parameter.p2"].AfterStatus)
+		assert.Equal(t, &ChangeCounts{Add: 1, Change: 1}, run.ApplySummary)
+	}
+	assert.Equal(t, ProviderRPC{Calls: 4, TotalTime: 4390}, log.ProviderRPCs["ApplyResourceChange"])
+	assert.Equal(t, 6, log.Resources["aws_ssm_parameter.p1"].ProviderCalls)
+}
+
+func BenchmarkParse(b *testing.B) {
+	files, _ := filepath.Glob("../../../test/*.log")
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(filepath.Base(file), func(b *testing.B) {
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				_, _, err := ParseRuns(bufio.NewScanner(bytes.NewReader(content)), false, false)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterParser(t *testing.T) {
+	defer func(saved map[Phase][]registeredParser) { registeredParsers = saved }(registeredParsers)
+	registeredParsers = map[Phase][]registeredParser{}
+
+	RegisterParser(ApplyPhase, "owner", func(Line string, log *ParsedLog) (bool, error) {
+		if !strings.HasPrefix(Line, "owner: ") {
+			return false, nil
+		}
+		log.RegisterNewResource(strings.TrimPrefix(Line, "owner: "))
+		return true, nil
+	})
+
+	in := "aws_ssm_parameter.p1: Creating...\nowner: team-a\n"
+	log, coverage, err := ParseCoverage(bufio.NewScanner(strings.NewReader(in)))
+	assert.Nil(t, err)
+	assert.Contains(t, log.Resources, "team-a")
+	assert.Equal(t, 1, coverage.Matched["owner"])
+	assert.Equal(t, 1, coverage.Matched["parseResourceCreationStarted"])
+}
@@ -2,26 +2,34 @@ package tfprofile
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
 )
 
 var (
-	startPlan       = "Terraform will perform the following actions:"
-	isTainted       = fmt.Sprintf("%v is tainted, so must be replaced", resourceName)
-	willBeCreated   = fmt.Sprintf("%v will be created", resourceName)
-	explicitReplace = fmt.Sprintf("%v will be replaced, as requested", resourceName)
-	willBeDestroyed = fmt.Sprintf("%v will be destroyed", resourceName)
-	willBeModified  = fmt.Sprintf("%v will be updated in-place", resourceName)
-	forcedReplace   = fmt.Sprintf("%v must be replaced", resourceName)
+	isTainted       = newMatcher(" is tainted, so must be replaced", fmt.Sprintf("%v is tainted, so must be replaced", resourceName))
+	willBeCreated   = newMatcher(" will be created", fmt.Sprintf("%v will be created", resourceName))
+	explicitReplace = newMatcher(" will be replaced, as requested", fmt.Sprintf("%v will be replaced, as requested", resourceName))
+	willBeDestroyed = newMatcher(" will be destroyed", fmt.Sprintf("%v will be destroyed", resourceName))
+	willBeModified  = newMatcher(" will be updated in-place", fmt.Sprintf("%v will be updated in-place", resourceName))
+	forcedReplace   = newMatcher(" must be replaced", fmt.Sprintf("%v must be replaced", resourceName))
+	hasMoved        = newMatcher(" has moved to ", fmt.Sprintf("# %v has moved to %v", resourceName, resourceName))
+	movedFrom       = newMatcher("# (moved from ", fmt.Sprintf(`^\s*# \(moved from %v\)$`, resourceName))
+	willBeImported  = newMatcher(" will be imported", fmt.Sprintf("# %v will be imported", resourceName))
+	importedFrom    = newMatcher("# (imported from ", `^\s*# \(imported from ".*"\)$`)
+	willBeRead      = newMatcher(" will be read during apply", fmt.Sprintf("# %v will be read during apply", resourceName))
+	preparingImport = newMatcher(": Preparing import...", fmt.Sprintf("%v: Preparing import...", resourceName))
+	planBlockStart  = newMatcher(" {", `^\s*(\S+\s+)?(resource|data) ".*" ".*" {$`)
+	planHeader      = newMatcher("# ", fmt.Sprintf(`^\s*# %v (will be|must be|is tainted|has moved to) `, resourceName))
+	planSummary     = newMatcher("Plan: ", `^Plan: (.*)\.$`)
+	noChanges       = newMatcher("No changes. ", `^No changes\. `)
 )
 
 // Handle line that indicates the start of a Terraform plan:
-// "Terraform will perform the following actions:"
+// "Terraform will perform the following actions:" (see tool.go for OpenTofu)
 func parseStartPlan(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(resourceCreated, Line)
+	match := isStartPlan(Line, log)
 	if !match {
 		return false, nil
 	}
@@ -32,7 +40,7 @@ func parseStartPlan(Line string, log *ParsedLog) (bool, error) {
 // Handle line that indicates a resource is tainted. E.g:
 // "  # aws_ssm_parameter.p1 is tainted, so must be replaced"
 func parsePlanTainted(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(isTainted, Line)
+	match := isTainted.Match(Line)
 	if !match {
 		return false, nil
 	}
@@ -45,6 +53,9 @@ func parsePlanTainted(Line string, log *ParsedLog) (bool, error) {
 	resource := strings.Split(tokens[1], " is tainted, so must be replaced")[0]
 
 	log.RegisterNewResource(resource)
+	log.CurrentPlanResource = resource
+	log.CurrentPlanPath = nil
+	log.SetPlannedChange(resource, Replace, "tainted")
 	log.SetDesiredStatus(resource, Created)
 	return true, nil
 }
@@ -52,7 +63,7 @@ func parsePlanTainted(Line string, log *ParsedLog) (bool, error) {
 // Handle line that indicates a resource has been marked to be replaced. E.g:
 // "  # aws_ssm_parameter.p1 will be replaced, as requested"
 func parsePlanExplicitReplace(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(explicitReplace, Line)
+	match := explicitReplace.Match(Line)
 	if !match {
 		return false, nil
 	}
@@ -65,6 +76,9 @@ func parsePlanExplicitReplace(Line string, log *ParsedLog) (bool, error) {
 	resource := strings.Split(tokens[1], " will be replaced, as requested")[0]
 
 	log.RegisterNewResource(resource)
+	log.CurrentPlanResource = resource
+	log.CurrentPlanPath = nil
+	log.SetPlannedChange(resource, Replace, "requested")
 	log.SetDesiredStatus(resource, Created)
 	return true, nil
 }
@@ -72,7 +86,7 @@ func parsePlanExplicitReplace(Line string, log *ParsedLog) (bool, error) {
 // Handle line that indicates a resource will be destroyed. E.g:
 // "  # aws_ssm_parameter.p1 will be destroyed"
 func parsePlanWillBeDestroyed(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(willBeDestroyed, Line)
+	match := willBeDestroyed.Match(Line)
 	if !match {
 		return false, nil
 	}
@@ -85,6 +99,9 @@ func parsePlanWillBeDestroyed(Line string, log *ParsedLog) (bool, error) {
 	resource := strings.Split(tokens[1], " will be destroyed")[0]
 
 	log.RegisterNewResource(resource)
+	log.CurrentPlanResource = resource
+	log.CurrentPlanPath = nil
+	log.SetPlannedChange(resource, Destroy, "")
 	log.SetDesiredStatus(resource, NotCreated)
 	return true, nil
 }
@@ -92,7 +109,7 @@ func parsePlanWillBeDestroyed(Line string, log *ParsedLog) (bool, error) {
 // Handle line that indicates a resource will be modified. E.g:
 // " # aws_ssm_parameter.p5 will be updated in-place"
 func parsePlanWillBeModified(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(willBeModified, Line)
+	match := willBeModified.Match(Line)
 	if !match {
 		return false, nil
 	}
@@ -105,6 +122,9 @@ func parsePlanWillBeModified(Line string, log *ParsedLog) (bool, error) {
 	resource := strings.Split(tokens[1], " will be updated in-place")[0]
 
 	log.RegisterNewResource(resource)
+	log.CurrentPlanResource = resource
+	log.CurrentPlanPath = nil
+	log.SetPlannedChange(resource, Modify, "")
 	log.SetDesiredStatus(resource, Created)
 	return true, nil
 }
@@ -112,7 +132,7 @@ func parsePlanWillBeModified(Line string, log *ParsedLog) (bool, error) {
 // Handle line that indicates a resource must be replaced. E.g:
 // "# aws_ssm_parameter.p6 must be replaced"
 func parsePlanForcedReplace(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(forcedReplace, Line)
+	match := forcedReplace.Match(Line)
 	if !match {
 		return false, nil
 	}
@@ -125,6 +145,9 @@ func parsePlanForcedReplace(Line string, log *ParsedLog) (bool, error) {
 	resource := strings.Split(tokens[1], " must be replaced")[0]
 
 	log.RegisterNewResource(resource)
+	log.CurrentPlanResource = resource
+	log.CurrentPlanPath = nil
+	log.SetPlannedChange(resource, Replace, "forced")
 	log.SetDesiredStatus(resource, Created)
 	return true, nil
 }
@@ -132,7 +155,7 @@ func parsePlanForcedReplace(Line string, log *ParsedLog) (bool, error) {
 // Handle line that indicates a resource will be created. E.g:
 // "# aws_ssm_parameter.p6 will be created"
 func parsePlanWillBeCreated(Line string, log *ParsedLog) (bool, error) {
-	match, _ := regexp.MatchString(willBeCreated, Line)
+	match := willBeCreated.Match(Line)
 	if !match {
 		return false, nil
 	}
@@ -145,6 +168,317 @@ func parsePlanWillBeCreated(Line string, log *ParsedLog) (bool, error) {
 	resource := strings.Split(tokens[1], " will be created")[0]
 
 	log.RegisterNewResource(resource)
+	log.CurrentPlanResource = resource
+	log.CurrentPlanPath = nil
+	log.SetPlannedChange(resource, Create, "")
 	log.SetDesiredStatus(resource, Created)
 	return true, nil
 }
+
+// Handle line that indicates a resource has moved to a new address. E.g:
+// "  # aws_ssm_parameter.p1 has moved to aws_ssm_parameter.p2"
+func parsePlanMoved(Line string, log *ParsedLog) (bool, error) {
+	match := hasMoved.Match(Line)
+	if !match {
+		return false, nil
+	}
+
+	tokens := strings.Split(Line, "# ")
+	if len(tokens) < 2 {
+		msg := fmt.Sprintf("Unable to parse moved resource: %v\n", Line)
+		return false, &LineParseError{Msg: msg}
+	}
+	addresses := strings.Split(tokens[1], " has moved to ")
+	if len(addresses) < 2 {
+		msg := fmt.Sprintf("Unable to parse moved resource: %v\n", Line)
+		return false, &LineParseError{Msg: msg}
+	}
+	resource := addresses[1]
+
+	log.RegisterNewResource(resource)
+	log.CurrentPlanResource = resource
+	log.CurrentPlanPath = nil
+	log.SetPlannedChange(resource, Move, "")
+	log.SetOperation(resource, Move)
+	log.SetMovedFrom(resource, addresses[0])
+	log.SetDesiredStatus(resource, Created)
+	return true, nil
+}
+
+// Handle line that annotates the previous plan line when a resource was moved
+// and has other changes as well. E.g:
+// "  # aws_ssm_parameter.p2 will be updated in-place"
+// "  # (moved from aws_ssm_parameter.p1)"
+func parsePlanMovedFrom(Line string, log *ParsedLog) (bool, error) {
+	match := movedFrom.Match(Line)
+	if !match || log.CurrentPlanResource == "" {
+		return false, nil
+	}
+
+	tokens := strings.Split(Line, "(moved from ")
+	previous := strings.TrimSuffix(tokens[1], ")")
+
+	log.SetMovedFrom(log.CurrentPlanResource, previous)
+	return true, nil
+}
+
+// Handle line that indicates a resource will be imported. E.g:
+// "  # aws_ssm_parameter.p1 will be imported"
+func parsePlanWillBeImported(Line string, log *ParsedLog) (bool, error) {
+	match := willBeImported.Match(Line)
+	if !match {
+		return false, nil
+	}
+
+	tokens := strings.Split(Line, "# ")
+	if len(tokens) < 2 {
+		msg := fmt.Sprintf("Unable to parse resource to import: %v\n", Line)
+		return false, &LineParseError{Msg: msg}
+	}
+	resource := strings.Split(tokens[1], " will be imported")[0]
+
+	log.RegisterNewResource(resource)
+	log.CurrentPlanResource = resource
+	log.CurrentPlanPath = nil
+	log.SetPlannedChange(resource, Import, "")
+	log.SetOperation(resource, Import)
+	log.SetPlannedImport(resource)
+	log.SetBeforeStatus(resource, NotCreated)
+	log.SetDesiredStatus(resource, Created)
+	return true, nil
+}
+
+// Handle line that annotates the previous plan line when a resource will be
+// imported and has other changes as well. E.g:
+// "  # aws_ssm_parameter.p1 will be updated in-place"
+// "  # (imported from "p1")"
+func parsePlanImportedFrom(Line string, log *ParsedLog) (bool, error) {
+	match := importedFrom.Match(Line)
+	if !match || log.CurrentPlanResource == "" {
+		return false, nil
+	}
+
+	log.SetPlannedImport(log.CurrentPlanResource)
+	log.SetBeforeStatus(log.CurrentPlanResource, NotCreated)
+	return true, nil
+}
+
+// Handle line that indicates a resource will be removed from the state without
+// destroying it (`removed` block). E.g:
+// "  # aws_ssm_parameter.p1 will no longer be managed by Terraform"
+// "  # aws_ssm_parameter.p1 will be removed from the OpenTofu state but will not be destroyed"
+func parsePlanWillBeForgotten(Line string, log *ParsedLog) (bool, error) {
+	resource := forgottenResource(Line, log)
+	if resource == "" {
+		return false, nil
+	}
+
+	log.RegisterNewResource(resource)
+	log.CurrentPlanResource = resource
+	log.CurrentPlanPath = nil
+	log.SetPlannedChange(resource, Forget, "")
+	log.SetOperation(resource, Forget)
+	log.SetDesiredStatus(resource, NotCreated)
+	log.SetAfterStatus(resource, NotCreated)
+	return true, nil
+}
+
+// The resource in a line that announces a resource will be forgotten, using the
+// first pattern that matches. Empty if no pattern matches.
+func forgottenResource(Line string, log *ParsedLog) string {
+	for _, d := range dialectsFor(log) {
+		for _, m := range d.willBeForgotten {
+			if m.Match(Line) {
+				return m.re.FindStringSubmatch(Line)[1]
+			}
+		}
+	}
+	return ""
+}
+
+// Handle line that indicates a data source can only be read during apply. E.g:
+// "  # data.aws_eks_cluster.this will be read during apply"
+func parsePlanWillBeRead(Line string, log *ParsedLog) (bool, error) {
+	match := willBeRead.Match(Line)
+	if !match {
+		return false, nil
+	}
+
+	tokens := strings.Split(Line, "# ")
+	if len(tokens) < 2 {
+		msg := fmt.Sprintf("Unable to parse data source to read: %v\n", Line)
+		return false, &LineParseError{Msg: msg}
+	}
+	resource := strings.Split(tokens[1], " will be read during apply")[0]
+
+	log.RegisterNewResource(resource)
+	log.CurrentPlanResource = resource
+	log.CurrentPlanPath = nil
+	log.SetPlannedChange(resource, Read, "")
+	log.SetOperation(resource, Read)
+	log.SetDesiredStatus(resource, Created)
+	return true, nil
+}
+
+// Handle line that indicates an import block is being planned. E.g:
+// aws_ssm_parameter.p1: Preparing import... [id=p1]
+func parsePreparingImport(Line string, log *ParsedLog) (bool, error) {
+	match := preparingImport.Match(Line)
+	if !match {
+		return false, nil
+	}
+	tokens := strings.Split(Line, ": Preparing import...")
+	if len(tokens) < 2 {
+		msg := fmt.Sprintf("Unable to parse import preparation line: %v\n", Line)
+		return false, &LineParseError{Msg: msg}
+	}
+
+	resource := tokens[0]
+	log.RegisterNewResource(resource)
+	log.SetOperation(resource, Import)
+	log.SetBeforeStatus(resource, NotCreated)
+	return true, nil
+}
+
+// Handle a line in the body of a plan block, recording changed attributes of
+// the resource announced in the header (see log.CurrentPlanResource). E.g:
+// "-/+ resource "aws_ssm_parameter" "p6" {"
+// "      ~ name           = "p6" -> "new-p6" # forces replacement"
+// "      + arn            = (known after apply)"
+// "    }"
+// Nested blocks, maps and lists are tracked in log.CurrentPlanPath, so nested
+// attributes are recorded with their full path (e.g. "tags.owner").
+func parsePlanAttribute(Line string, log *ParsedLog) (bool, error) {
+	if log.CurrentPlanResource == "" {
+		return false, nil
+	}
+
+	// The header of the next plan block, the plan summary or an empty line
+	// outside of a heredoc: the current block was never closed (e.g. in a
+	// truncated log), so stop claiming lines for it
+	if planHeader.Match(Line) || planSummary.Match(Line) || (Line == "" && log.CurrentPlanHeredoc == "") {
+		log.CurrentPlanResource = ""
+		log.CurrentPlanPath = nil
+		log.CurrentPlanHeredoc = ""
+		return false, nil
+	}
+
+	// Inside a heredoc string, only look for its end marker
+	if log.CurrentPlanHeredoc != "" {
+		if strings.TrimSpace(Line) == log.CurrentPlanHeredoc {
+			log.CurrentPlanHeredoc = ""
+		}
+		return true, nil
+	}
+
+	// Opening line of the plan block
+	if len(log.CurrentPlanPath) == 0 {
+		match := planBlockStart.Match(Line)
+		if !match {
+			return false, nil
+		}
+		log.CurrentPlanPath = []string{""}
+		return true, nil
+	}
+
+	// Split off trailing comments such as "# forces replacement"
+	code, comment, _ := strings.Cut(strings.TrimSpace(Line), " # ")
+	if strings.HasPrefix(code, "# ") {
+		return true, nil // "# (2 unchanged attributes hidden)"
+	}
+
+	// Closing a nested block, or the plan block itself
+	if strings.HasPrefix(code, "}") || strings.HasPrefix(code, "]") || strings.HasPrefix(code, ")") {
+		if strings.Contains(comment, "forces replacement") {
+			log.AddPlannedAttribute(log.CurrentPlanResource, AttributeChange{
+				Name:              joinPlanPath(log.CurrentPlanPath...),
+				ForcesReplacement: true,
+			})
+		}
+		log.CurrentPlanPath = log.CurrentPlanPath[:len(log.CurrentPlanPath)-1]
+		if len(log.CurrentPlanPath) == 0 {
+			log.CurrentPlanResource = ""
+		}
+		return true, nil
+	}
+
+	action, rest := splitPlanAction(code)
+	name, value, isAssignment := strings.Cut(rest, " = ")
+	if !isAssignment {
+		// Either a nested block ("~ ingress {") or an element of a list ("+ "a",")
+		name, value = strings.TrimSuffix(rest, "{"), rest
+	}
+	name = strings.Trim(strings.TrimSpace(name), `"`)
+	value = strings.TrimSpace(value)
+
+	// Elements of a list are recorded as a change to the list itself
+	parent := joinPlanPath(log.CurrentPlanPath...)
+	path := joinPlanPath(parent, name)
+	if !isAssignment && !strings.HasSuffix(value, "{") || name == "" {
+		path = parent
+	}
+
+	if path != "" && (action != "" || strings.Contains(comment, "forces replacement")) {
+		log.AddPlannedAttribute(log.CurrentPlanResource, AttributeChange{
+			Name:              path,
+			Action:            action,
+			ForcesReplacement: strings.Contains(comment, "forces replacement"),
+			KnownAfterApply:   strings.Contains(value, "(known after apply)"),
+			Sensitive:         strings.Contains(value, "(sensitive value)") || strings.Contains(value, "(sensitive)"),
+		})
+	}
+
+	// Values that continue on the next lines
+	if strings.HasSuffix(value, "{") || strings.HasSuffix(value, "[") || strings.HasSuffix(value, "(") {
+		log.CurrentPlanPath = append(log.CurrentPlanPath, name)
+	} else if strings.HasPrefix(value, "<<") {
+		log.CurrentPlanHeredoc = strings.TrimLeft(value, "<-")
+	}
+	return true, nil
+}
+
+// Split the action symbol (+, -, ~, -/+, +/-) from a line in a plan block.
+// Returns an empty action for unchanged attributes.
+func splitPlanAction(code string) (string, string) {
+	for _, action := range []string{"-/+", "+/-", "+", "-", "~"} {
+		if strings.HasPrefix(code, action+" ") {
+			return action, strings.TrimPrefix(code, action+" ")
+		}
+	}
+	return "", code
+}
+
+// Join the path of a nested attribute with dots, skipping empty parts
+func joinPlanPath(parts ...string) string {
+	nonEmpty := []string{}
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, ".")
+}
+
+// Handle the summary line of a plan. E.g:
+// "Plan: 1 to import, 3 to add, 1 to change, 4 to destroy."
+// "No changes. Your infrastructure matches the configuration."
+func parsePlanSummary(Line string, log *ParsedLog) (bool, error) {
+	match := noChanges.Match(Line)
+	if match {
+		log.PlanSummary = &ChangeCounts{}
+		log.Emit(Event{Type: Summary})
+		return true, nil
+	}
+
+	match = planSummary.Match(Line)
+	if !match {
+		return false, nil
+	}
+	counts, err := parseChangeCounts(strings.TrimPrefix(strings.TrimSuffix(Line, "."), "Plan: "), PlanLabels)
+	if err != nil {
+		return false, err
+	}
+	log.PlanSummary = &counts
+	log.Emit(Event{Type: Summary, Counts: counts})
+	return true, nil
+}
@@ -58,3 +58,146 @@ func TestParseErrors(t *testing.T) {
 	_, err = parsePlanWillBeCreated("foo will be created", &log)
 	assert.NotNil(t, err)
 }
+
+func TestParsePlanImportMoveForget(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+
+	modified, err := parsePlanMoved("  # foo has moved to bar", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, Move, log.Resources["bar"].Operation)
+	assert.Equal(t, "foo", log.Resources["bar"].MovedFrom)
+
+	modified, err = parsePlanWillBeModified("  # baz will be updated in-place", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	modified, err = parsePlanMovedFrom("  # (moved from qux)", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, "qux", log.Resources["baz"].MovedFrom)
+
+	modified, err = parsePlanWillBeImported("  # foo will be imported", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, Import, log.Resources["foo"].Operation)
+	assert.Equal(t, NotCreated, log.Resources["foo"].BeforeStatus)
+
+	modified, err = parsePlanWillBeForgotten("  # old will no longer be managed by Terraform", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, Forget, log.Resources["old"].Operation)
+
+	modified, err = parsePreparingImport("new: Preparing import... [id=new]", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, Import, log.Resources["new"].Operation)
+}
+
+func TestParsePlanAttributes(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+	lines := []string{
+		`  # foo must be replaced`,
+		`-/+ resource "aws_ssm_parameter" "foo" {`,
+		`      ~ arn            = "arn:aws:ssm:eu-west-1:123:parameter/p6" -> (known after apply)`,
+		`      ~ name           = "p6" -> "new-p6" # forces replacement`,
+		`        tier           = "Standard"`,
+		`      ~ value          = (sensitive value)`,
+		`      ~ tags           = {`,
+		`          + "owner" = "someone"`,
+		`        }`,
+		`      ~ ingress {`,
+		`          ~ cidr_blocks = [`,
+		`              + "10.0.0.0/16",`,
+		`            ] # forces replacement`,
+		`        }`,
+		`      ~ user_data      = <<-EOT`,
+		`          - echo "}"`,
+		`        EOT`,
+		`        # (2 unchanged attributes hidden)`,
+		`    }`,
+	}
+	for _, line := range lines {
+		modified := false
+		for _, f := range planParsers {
+			modified, _ = f(line, &log)
+			if modified {
+				break
+			}
+		}
+		assert.True(t, modified, line)
+	}
+
+	change := log.PlannedChanges["foo"]
+	assert.Equal(t, Replace, change.Operation)
+	assert.Equal(t, "forced", change.ReplaceReason)
+	assert.Equal(t, "", log.CurrentPlanResource)
+
+	Expected := []AttributeChange{
+		{Name: "arn", Action: "~", KnownAfterApply: true},
+		{Name: "name", Action: "~", ForcesReplacement: true},
+		{Name: "value", Action: "~", Sensitive: true},
+		{Name: "tags", Action: "~"},
+		{Name: "tags.owner", Action: "+"},
+		{Name: "ingress", Action: "~"},
+		{Name: "ingress.cidr_blocks", Action: "~", ForcesReplacement: true},
+		{Name: "user_data", Action: "~"},
+	}
+	assert.Equal(t, Expected, change.Attributes)
+
+	// Lines outside of a plan block are not claimed
+	modified, _ := parsePlanAttribute(`      ~ name = "a" -> "b"`, &log)
+	assert.False(t, modified)
+}
+
+func TestParseTruncatedPlanBlock(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+	lines := []string{
+		`  # foo will be created`,
+		`  + resource "aws_ssm_parameter" "foo" {`,
+		`      + tags = {`,
+		`      + value = <<-EOT`,
+		`  # bar will be created`,
+		`  + resource "aws_ssm_parameter" "bar" {`,
+		`      + name = "bar"`,
+		`      + allowed = [`,
+		``,
+		`Plan: 2 to add, 0 to change, 0 to destroy.`,
+	}
+	for _, line := range lines {
+		_, err := parseLine(line, &log, nil)
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, Create, log.PlannedChanges["bar"].Operation)
+	assert.Equal(t, []AttributeChange{{Name: "name", Action: "+"}, {Name: "allowed", Action: "+"}}, log.PlannedChanges["bar"].Attributes)
+	assert.Equal(t, &ChangeCounts{Add: 2}, log.PlanSummary)
+	assert.Equal(t, "", log.CurrentPlanResource)
+}
+
+func TestParsePlanSummary(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+
+	modified, err := parsePlanSummary("Plan: 1 to import, 3 to add, 1 to change, 4 to destroy.", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, ChangeCounts{Import: 1, Add: 3, Change: 1, Destroy: 4}, *log.PlanSummary)
+
+	modified, err = parsePlanSummary("No changes. Your infrastructure matches the configuration.", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, ChangeCounts{}, *log.PlanSummary)
+
+	_, err = parsePlanSummary("Plan: many to add.", &log)
+	assert.NotNil(t, err)
+}
+
+func TestParsePlanWillBeRead(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+	modified, err := parsePlanWillBeRead(`  # data.aws_eks_addon_version.this["kube-proxy"] will be read during apply`, &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+
+	resource := `data.aws_eks_addon_version.this["kube-proxy"]`
+	assert.Equal(t, Read, log.Resources[resource].Operation)
+	assert.Equal(t, Read, log.PlannedChanges[resource].Operation)
+}
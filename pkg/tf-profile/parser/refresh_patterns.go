@@ -2,30 +2,155 @@ package tfprofile
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
 )
 
-// Parse a refresh line and records the resource in the log.
+var (
+	// All matchers that recognize interesting logs during the refresh phase
+	refreshingState     = newMatcher(": Refreshing state...", fmt.Sprintf("%v: Refreshing state...", resourceName))
+	dataSourceReading   = newMatcher(": Reading...", fmt.Sprintf("%v: Reading...", resourceName))
+	dataSourceRead      = newMatcher(": Read complete after", fmt.Sprintf("%v: Read complete after", resourceName))
+	driftHasChanged     = newMatcher(" has changed", fmt.Sprintf("# %v has changed$", resourceName))
+	driftHasBeenDeleted = newMatcher(" has been deleted", fmt.Sprintf("# %v has been deleted$", resourceName))
+)
+
+// Parse a refresh line and records the resource in the log. E.g:
+// aws_ssm_parameter.p1: Refreshing state... [id=p1]
 func refreshParser(Line string, log *ParsedLog) (bool, error) {
-	regex := fmt.Sprintf("%v: Refreshing state...", resourceName)
-	match, _ := regexp.MatchString(regex, Line)
+	match := refreshingState.Match(Line)
 	if !match {
 		return false, nil
 	}
 	tokens := strings.Split(Line, ": Refreshing state...")
 	if len(tokens) < 2 {
-		msg := fmt.Sprintf("Unable to parse resource creation line: %v\n", Line)
+		msg := fmt.Sprintf("Unable to parse resource refresh line: %v\n", Line)
 		return false, &LineParseError{Msg: msg}
 	}
 
-	// Knowing the resource whose creation stared, insert everything in the log
+	// Knowing the resource that was refreshed, insert everything in the log
 	resource := tokens[0]
 	log.RegisterNewResource(resource)
 	log.SetModificationStartedEvent(resource, -1)
 	log.SetModificationStartedIndex(resource, -1)
+	log.SetRefreshIndex(resource, log.CurrentRefreshIndex)
+	log.SetRefreshEvent(resource, log.CurrentEvent)
+
+	log.CurrentRefreshIndex += 1
+	log.CurrentEvent += 1
+	return true, nil
+}
+
+// Handle line that indicates a data source is being read. E.g:
+// data.aws_availability_zones.available: Reading...
+func parseDataSourceReading(Line string, log *ParsedLog) (bool, error) {
+	match := dataSourceReading.Match(Line)
+	if !match {
+		return false, nil
+	}
+	tokens := strings.Split(Line, ": Reading...")
+	if len(tokens) < 2 {
+		msg := fmt.Sprintf("Unable to parse data source read line: %v\n", Line)
+		return false, &LineParseError{Msg: msg}
+	}
+
+	resource := tokens[0]
+	log.RegisterNewResource(resource)
+	log.SetOperation(resource, Read)
 
+	// Data sources that depend on resources of the apply are read during the
+	// apply, and recorded like a modification of the resource. Other reads
+	// are part of the refresh.
+	if log.CurrentPhase != RefreshPhase {
+		log.SetModificationStartedEvent(resource, log.CurrentEvent)
+		log.SetModificationStartedIndex(resource, log.CurrentModificationStartedIndex)
+		log.CurrentModificationStartedIndex += 1
+		log.CurrentEvent += 1
+		log.Emit(Event{Type: ResourceStarted, Resource: resource, Operation: Read})
+		return true, nil
+	}
+
+	log.SetModificationStartedEvent(resource, -1)
+	log.SetModificationStartedIndex(resource, -1)
+	log.SetRefreshIndex(resource, log.CurrentRefreshIndex)
+	log.SetRefreshEvent(resource, log.CurrentEvent)
+
+	log.CurrentRefreshIndex += 1
+	log.CurrentEvent += 1
+	log.Emit(Event{Type: ResourceStarted, Resource: resource, Operation: Read})
+	return true, nil
+}
+
+// Handle line that indicates a data source has been read. E.g:
+// data.aws_availability_zones.available: Read complete after 1s [id=us-west-2]
+func parseDataSourceRead(Line string, log *ParsedLog) (bool, error) {
+	match := dataSourceRead.Match(Line)
+	if !match {
+		return false, nil
+	}
+	tokens := strings.Split(Line, ": Read complete after ")
+	if len(tokens) < 2 {
+		msg := fmt.Sprintf("Unable to parse data source read line: %v\n", Line)
+		return false, &LineParseError{Msg: msg}
+	}
+	resource := tokens[0]
+
+	// The next token will contain the read time (" Read complete after ...s [id=...]")
+	tokens2 := strings.Split(tokens[1], " ")
+	Duration := parseCreateDurationString(tokens2[0])
+
+	log.SetTotalTime(resource, Duration)
+	log.SetAfterStatus(resource, Created)
+	if log.CurrentPhase != RefreshPhase {
+		log.SetModificationCompletedEvent(resource, log.CurrentEvent)
+		log.SetModificationCompletedIndex(resource, log.CurrentModificationEndedIndex)
+		log.CurrentModificationEndedIndex += 1
+		log.CurrentEvent += 1
+	}
+	log.Emit(Event{Type: ResourceCompleted, Resource: resource, Operation: Read, Duration: Duration})
+	return true, nil
+}
+
+// Handle line in the "Objects have changed outside of Terraform" section
+// that indicates a resource was modified outside of Terraform. E.g:
+// "  # aws_ssm_parameter.p1 has changed"
+func parseDriftChanged(Line string, log *ParsedLog) (bool, error) {
+	match := driftHasChanged.Match(Line)
+	if !match {
+		return false, nil
+	}
+
+	tokens := strings.Split(Line, "# ")
+	if len(tokens) < 2 {
+		msg := fmt.Sprintf("Unable to parse changed resource: %v\n", Line)
+		return false, &LineParseError{Msg: msg}
+	}
+	resource := strings.Split(tokens[1], " has changed")[0]
+
+	log.RegisterNewResource(resource)
+	log.SetDrift(resource, DriftChanged)
+	return true, nil
+}
+
+// Handle line in the "Objects have changed outside of Terraform" section
+// that indicates a resource was deleted outside of Terraform. E.g:
+// "  # aws_ssm_parameter.p1 has been deleted"
+func parseDriftDeleted(Line string, log *ParsedLog) (bool, error) {
+	match := driftHasBeenDeleted.Match(Line)
+	if !match {
+		return false, nil
+	}
+
+	tokens := strings.Split(Line, "# ")
+	if len(tokens) < 2 {
+		msg := fmt.Sprintf("Unable to parse deleted resource: %v\n", Line)
+		return false, &LineParseError{Msg: msg}
+	}
+	resource := strings.Split(tokens[1], " has been deleted")[0]
+
+	log.RegisterNewResource(resource)
+	log.SetDrift(resource, DriftDeleted)
+	log.SetBeforeStatus(resource, NotCreated)
 	return true, nil
 }
@@ -0,0 +1,94 @@
+package tfprofile
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRefresh(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+
+	modified, err := refreshParser("foo: Refreshing state... [id=foo]", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	modified, err = refreshParser("bar: Refreshing state... [id=bar]", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 0, log.Resources["foo"].RefreshIndex)
+	assert.Equal(t, 1, log.Resources["bar"].RefreshIndex)
+	assert.Equal(t, -1, log.Resources["foo"].ModificationStartedIndex)
+	assert.Equal(t, 2, log.CurrentEvent)
+}
+
+func TestParseDataSourceRead(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+
+	modified, err := parseDataSourceReading("data.foo.bar: Reading...", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	modified, err = parseDataSourceRead("data.foo.bar: Read complete after 1m2s [id=bar]", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+
+	assert.Equal(t, Read, log.Resources["data.foo.bar"].Operation)
+	assert.Equal(t, float64(62000), log.Resources["data.foo.bar"].TotalTime)
+	assert.Equal(t, 0, log.Resources["data.foo.bar"].RefreshIndex)
+}
+
+func TestParseDataSourceReadDuringApply(t *testing.T) {
+	in := `Terraform will perform the following actions:
+  # aws_ssm_parameter.p1 will be created
+data.foo.bar: Reading...
+data.foo.bar: Read complete after 2s [id=bar]
+`
+	log, err := Parse(bufio.NewScanner(strings.NewReader(in)), false)
+	assert.Nil(t, err)
+
+	assert.False(t, log.ContainsRefresh)
+	assert.True(t, log.ContainsApply)
+	Metric := log.Resources["data.foo.bar"]
+	assert.Equal(t, Read, Metric.Operation)
+	assert.Equal(t, -1, Metric.RefreshIndex)
+	assert.Equal(t, 0, Metric.ModificationStartedEvent)
+	assert.Equal(t, 1, Metric.ModificationCompletedEvent)
+	assert.Equal(t, 0, log.CurrentRefreshIndex)
+}
+
+func TestParseDrift(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+
+	modified, err := parseDriftChanged("  # foo has changed", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, DriftChanged, log.Resources["foo"].Drift)
+
+	modified, err = parseDriftDeleted("  # bar has been deleted", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, DriftDeleted, log.Resources["bar"].Drift)
+	assert.Equal(t, NotCreated, log.Resources["bar"].BeforeStatus)
+
+	modified, _ = parseDriftChanged("  # foo has changed something else", &log)
+	assert.False(t, modified)
+}
+
+func TestFullDriftParse(t *testing.T) {
+	file, _ := os.Open("../../../test/drift.log")
+	s := bufio.NewScanner(file)
+
+	log, err := Parse(s, false)
+	assert.Nil(t, err)
+	assert.True(t, log.ContainsRefresh)
+
+	assert.Equal(t, DriftChanged, log.Resources["aws_ssm_parameter.p1"].Drift)
+	assert.Equal(t, DriftDeleted, log.Resources["aws_ssm_parameter.p2"].Drift)
+	assert.Equal(t, NoDrift, log.Resources["aws_ssm_parameter.p3"].Drift)
+	assert.Equal(t, Create, log.Resources["aws_ssm_parameter.p2"].Operation)
+	assert.Equal(t, 3, log.Resources["aws_ssm_parameter.p3"].RefreshIndex)
+}
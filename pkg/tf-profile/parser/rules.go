@@ -0,0 +1,153 @@
+package tfprofile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/config"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+// Compile custom rules (see config.Rule) into parse functions and register
+// them for their phase. Built-in parse functions take precedence.
+func AddRules(rules []Rule) error {
+	for _, rule := range rules {
+		f, phase, err := compileRule(rule)
+		if err != nil {
+			return err
+		}
+		RegisterParser(phase, "rule:"+rule.Name, f)
+	}
+	return nil
+}
+
+// Turn a rule into a parse function, along with the phase it belongs to
+func compileRule(rule Rule) (ParseFunction, Phase, error) {
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Invalid pattern in rule %v: %v", rule.Name, err)
+	}
+	if re.SubexpIndex("resource") < 0 {
+		return nil, 0, fmt.Errorf("Pattern of rule %v has no (?P<resource>...) capture", rule.Name)
+	}
+	if re.SubexpIndex("event") < 0 && parseRuleEvent(rule.Event) < 0 {
+		return nil, 0, fmt.Errorf("Rule %v needs an event capture or an event (started, completed or failed)", rule.Name)
+	}
+
+	phase, err := parseRulePhase(rule.Phase)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Rule %v: %v", rule.Name, err)
+	}
+	op, err := parseRuleOperation(rule.Operation)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Rule %v: %v", rule.Name, err)
+	}
+
+	f := func(Line string, log *ParsedLog) (bool, error) {
+		captures := re.FindStringSubmatch(Line)
+		if captures == nil {
+			return false, nil
+		}
+		resource := captures[re.SubexpIndex("resource")]
+
+		event := parseRuleEvent(rule.Event)
+		if idx := re.SubexpIndex("event"); idx >= 0 && captures[idx] != "" {
+			event = parseRuleEvent(captures[idx])
+		}
+
+		Duration := float64(0)
+		if idx := re.SubexpIndex("duration"); idx >= 0 && captures[idx] != "" {
+			d, err := time.ParseDuration(captures[idx])
+			if err != nil {
+				msg := fmt.Sprintf("Unable to parse duration in line matched by rule %v: %v\n", rule.Name, Line)
+				return false, &LineParseError{Msg: msg}
+			}
+			Duration = float64(d.Milliseconds())
+		}
+
+		switch event {
+		case ResourceStarted:
+			ruleStarted(resource, op, log)
+		case ResourceCompleted:
+			if _, found := log.Resources[resource]; !found {
+				ruleStarted(resource, op, log)
+			}
+			ruleCompleted(resource, op, Duration, log)
+		case ResourceFailed:
+			log.RegisterNewResource(resource)
+			log.SetAfterStatus(resource, Failed)
+			log.Emit(Event{Type: ResourceFailed, Resource: resource, Operation: op})
+		default:
+			msg := fmt.Sprintf("Unknown event in line matched by rule %v: %v\n", rule.Name, Line)
+			return false, &LineParseError{Msg: msg}
+		}
+		return true, nil
+	}
+	return f, phase, nil
+}
+
+// Record the start of an operation on a resource matched by a rule
+func ruleStarted(resource string, op Operation, log *ParsedLog) {
+	log.RegisterNewResource(resource)
+	log.SetOperation(resource, op)
+	log.SetModificationStartedIndex(resource, log.CurrentModificationStartedIndex)
+	log.SetModificationStartedEvent(resource, log.CurrentEvent)
+	log.CurrentModificationStartedIndex += 1
+	log.CurrentEvent += 1
+	log.Emit(Event{Type: ResourceStarted, Resource: resource, Operation: op})
+}
+
+// Record the end of an operation on a resource matched by a rule
+func ruleCompleted(resource string, op Operation, Duration float64, log *ParsedLog) {
+	log.SetTotalTime(resource, Duration)
+	log.SetAfterStatus(resource, Created)
+	log.SetModificationCompletedEvent(resource, log.CurrentEvent)
+	log.SetModificationCompletedIndex(resource, log.CurrentModificationEndedIndex)
+	log.CurrentModificationEndedIndex += 1
+	log.CurrentEvent += 1
+	log.Emit(Event{Type: ResourceCompleted, Resource: resource, Operation: op, Duration: Duration})
+}
+
+// Convert the event of a rule into an EventType, -1 if unknown
+func parseRuleEvent(in string) EventType {
+	switch strings.ToLower(in) {
+	case "started":
+		return ResourceStarted
+	case "completed":
+		return ResourceCompleted
+	case "failed":
+		return ResourceFailed
+	default:
+		return -1
+	}
+}
+
+// Convert the phase of a rule into a Phase. Rules are active during the apply
+// by default.
+func parseRulePhase(in string) (Phase, error) {
+	switch strings.ToLower(in) {
+	case "refresh":
+		return RefreshPhase, nil
+	case "plan":
+		return PlanPhase, nil
+	case "apply", "":
+		return ApplyPhase, nil
+	default:
+		return 0, fmt.Errorf("unknown phase %v (expected refresh, plan or apply)", in)
+	}
+}
+
+// Convert the operation of a rule into an Operation, e.g. "create" => Create
+func parseRuleOperation(in string) (Operation, error) {
+	if in == "" {
+		return None, nil
+	}
+	for _, op := range []Operation{None, Create, Modify, Replace, Destroy, Read, Import, Move, Forget} {
+		if strings.EqualFold(op.String(), in) {
+			return op, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown operation %v", in)
+}
@@ -0,0 +1,55 @@
+package tfprofile
+
+import (
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/config"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileRule(t *testing.T) {
+	config, _ := LoadConfig("../../../test/custom_rules.yaml")
+
+	hooks, phase, err := compileRule(config.Rules[0])
+	assert.Nil(t, err)
+	assert.Equal(t, ApplyPhase, phase)
+
+	log := ParsedLog{Resources: map[string]ResourceMetric{}}
+	modified, err := hooks(">>> custom hook apply_post finished in 1m2s", &log)
+	assert.True(t, modified)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(62000), log.Resources["apply_post"].TotalTime)
+	assert.Equal(t, Created, log.Resources["apply_post"].AfterStatus)
+	assert.Equal(t, None, log.Resources["apply_post"].Operation)
+
+	modified, err = hooks("aws_ssm_parameter.p1: Creating...", &log)
+	assert.False(t, modified)
+	assert.Nil(t, err)
+
+	_, err = hooks(">>> custom hook apply_post finished in soon", &log)
+	assert.NotNil(t, err)
+
+	steps, _, err := compileRule(config.Rules[1])
+	assert.Nil(t, err)
+	steps(">>> step build started", &log)
+	assert.Equal(t, Create, log.Resources["build"].Operation)
+	assert.Equal(t, float64(-1), log.Resources["build"].TotalTime)
+	steps(">>> step build failed", &log)
+	assert.Equal(t, Failed, log.Resources["build"].AfterStatus)
+}
+
+func TestInvalidRules(t *testing.T) {
+	rules := []Rule{
+		{Name: "regex", Pattern: `(?P<resource>`, Event: "started"},
+		{Name: "no resource", Pattern: `^hook$`, Event: "started"},
+		{Name: "no event", Pattern: `^(?P<resource>\S+)$`},
+		{Name: "phase", Pattern: `^(?P<resource>\S+)$`, Event: "started", Phase: "deploy"},
+		{Name: "operation", Pattern: `^(?P<resource>\S+)$`, Event: "started", Operation: "upsert"},
+	}
+	for _, rule := range rules {
+		_, _, err := compileRule(rule)
+		assert.NotNil(t, err, rule.Name)
+	}
+}
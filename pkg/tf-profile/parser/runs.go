@@ -0,0 +1,239 @@
+package tfprofile
+
+import (
+	"reflect"
+	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+var (
+	// Lines that indicate the start or the end of a Terraform run
+	initializingBackend = "Initializing the backend..."
+	applyComplete       = newMatcher(" complete!", `^(Apply|Destroy) complete!`)
+)
+
+// Splits the log parsed by a Stream into runs. Every line is parsed once, into
+// the log of the stream (or of a Terragrunt unit). The splitter only keeps
+// track of what changed in those logs since the current run started.
+type runSplitter struct {
+	// Runs completed so far
+	runs []ParsedLog
+	// Progress of the current run by unit, "" for output outside of Terragrunt
+	progress map[string]*runProgress
+	// Units that printed output in the current run, in order of appearance
+	order []string
+}
+
+// Progress of the current run in a single log
+type runProgress struct {
+	// The log when the run started, to find what changed since
+	start ParsedLog
+	// Phases of the run
+	refresh bool
+	plan    bool
+	apply   bool
+	// Resources and plan blocks that events were emitted for during the run
+	resources map[string]bool
+	planned   map[string]bool
+}
+
+func newRunSplitter() *runSplitter {
+	return &runSplitter{progress: map[string]*runProgress{}}
+}
+
+// Progress of the current run in the log of a unit, which starts with the
+// first line of the unit in the run
+func (r *runSplitter) progressOf(unit string, log *ParsedLog) *runProgress {
+	progress, found := r.progress[unit]
+	if !found {
+		progress = &runProgress{
+			start:     snapshot(*log),
+			resources: map[string]bool{},
+			planned:   map[string]bool{},
+		}
+		r.progress[unit] = progress
+		r.order = append(r.order, unit)
+	}
+	return progress
+}
+
+// End the current run. It contains everything that happened in the logs of
+// the stream and its units since the run started.
+func (r *runSplitter) cut(base *ParsedLog, units map[string]*ParsedLog) {
+	run := ParsedLog{Resources: map[string]ResourceMetric{}}
+	if progress, found := r.progress[""]; found {
+		run = progress.run(*base)
+	}
+	unitRuns := map[string]*ParsedLog{}
+	unitOrder := []string{}
+	for _, unit := range r.order {
+		if unit == "" {
+			continue
+		}
+		unitRun := r.progress[unit].run(*units[unit])
+		unitRuns[unit] = &unitRun
+		unitOrder = append(unitOrder, unit)
+	}
+	if len(unitOrder) > 0 {
+		run = mergeUnits(run, unitRuns, unitOrder)
+	}
+
+	r.runs = appendRun(r.runs, run)
+	r.progress = map[string]*runProgress{}
+	r.order = nil
+}
+
+// Parse a line into a log and record what the line contributes to the run.
+// Returns the events the line caused.
+func (p *runProgress) track(log *ParsedLog, parse func() (bool, error)) (bool, []Event, error) {
+	// The log remembers the phases of all runs, so find those of this line
+	refresh, plan, apply := log.ContainsRefresh, log.ContainsPlan, log.ContainsApply
+	log.ContainsRefresh, log.ContainsPlan, log.ContainsApply = false, false, false
+	recognized, err := parse()
+	p.refresh = p.refresh || log.ContainsRefresh
+	p.plan = p.plan || log.ContainsPlan
+	p.apply = p.apply || log.ContainsApply
+	log.ContainsRefresh = log.ContainsRefresh || refresh
+	log.ContainsPlan = log.ContainsPlan || plan
+	log.ContainsApply = log.ContainsApply || apply
+
+	events := log.TakeEvents()
+	for _, event := range events {
+		switch {
+		case event.Type == PlanDecision:
+			p.planned[event.Resource] = true
+		case event.Resource != "":
+			p.resources[event.Resource] = true
+		}
+	}
+	return recognized, events, err
+}
+
+// The part of a log that belongs to the run: resources and plan blocks that
+// were parsed during the run, with their metrics at the end of the run.
+// Provider calls in trace output are only counted for this run.
+func (p *runProgress) run(log ParsedLog) ParsedLog {
+	run := ParsedLog{
+		ContainsRefresh: p.refresh,
+		ContainsPlan:    p.plan,
+		ContainsApply:   p.apply,
+		Resources:       map[string]ResourceMetric{},
+		Tool:            log.Tool,
+		ToolVersion:     log.ToolVersion,
+	}
+	for resource, metric := range log.Resources {
+		before, found := p.start.Resources[resource]
+		if !p.resources[resource] && found && before == metric {
+			continue
+		}
+		// Provider calls are counted over all runs, keep those of this run
+		metric.ProviderCalls -= before.ProviderCalls
+		metric.ProviderTime -= before.ProviderTime
+		metric.HTTPRequests -= before.HTTPRequests
+		metric.HTTPRetries -= before.HTTPRetries
+		metric.HTTPThrottled -= before.HTTPThrottled
+		metric.HTTPServerErrors -= before.HTTPServerErrors
+		run.Resources[resource] = metric
+	}
+	for resource, change := range log.PlannedChanges {
+		before, found := p.start.PlannedChanges[resource]
+		if p.planned[resource] || !found || !reflect.DeepEqual(before, change) {
+			if run.PlannedChanges == nil {
+				run.PlannedChanges = map[string]PlannedChange{}
+			}
+			run.PlannedChanges[resource] = change
+		}
+	}
+	for rpc, calls := range log.ProviderRPCs {
+		before := p.start.ProviderRPCs[rpc]
+		if calls == before {
+			continue
+		}
+		if run.ProviderRPCs == nil {
+			run.ProviderRPCs = map[string]ProviderRPC{}
+		}
+		run.ProviderRPCs[rpc] = ProviderRPC{Calls: calls.Calls - before.Calls, TotalTime: calls.TotalTime - before.TotalTime}
+		run.ContainsTrace = true
+	}
+
+	// Summary lines are stored as new pointers, so they changed if printed during the run
+	if log.PlanSummary != p.start.PlanSummary {
+		run.PlanSummary = log.PlanSummary
+	}
+	if log.ApplySummary != p.start.ApplySummary {
+		run.ApplySummary = log.ApplySummary
+	}
+	run.ApplyCounts = ChangeCounts{
+		Import:  log.ApplyCounts.Import - p.start.ApplyCounts.Import,
+		Add:     log.ApplyCounts.Add - p.start.ApplyCounts.Add,
+		Change:  log.ApplyCounts.Change - p.start.ApplyCounts.Change,
+		Destroy: log.ApplyCounts.Destroy - p.start.ApplyCounts.Destroy,
+		Forget:  log.ApplyCounts.Forget - p.start.ApplyCounts.Forget,
+	}
+	return run
+}
+
+// A copy of a log that is not changed by parsing more lines into the log
+func snapshot(log ParsedLog) ParsedLog {
+	New := log
+	New.Resources = map[string]ResourceMetric{}
+	for resource, metric := range log.Resources {
+		New.Resources[resource] = metric
+	}
+	New.PlannedChanges = map[string]PlannedChange{}
+	for resource, change := range log.PlannedChanges {
+		change.Attributes = append([]AttributeChange{}, change.Attributes...)
+		New.PlannedChanges[resource] = change
+	}
+	New.ProviderRPCs = map[string]ProviderRPC{}
+	for rpc, calls := range log.ProviderRPCs {
+		New.ProviderRPCs[rpc] = calls
+	}
+	return New
+}
+
+// Returns true if a line of a log marks the start of a new run, given the
+// progress of the current run in that log. This is the case when:
+// - the backend is initialized (`terraform init`, or the start of a Terragrunt run)
+// - a plan is announced, but the current run already contains planned changes or an apply
+// - a resource is refreshed, but the current run already contains planned changes or an apply
+func startsNewRun(line string, progress *runProgress, log *ParsedLog) bool {
+	if progress.isEmpty() {
+		return false
+	}
+	if strings.Contains(line, initializingBackend) {
+		return true
+	}
+
+	afterPlan := len(progress.planned) > 0 || progress.apply
+	if afterPlan && isStartPlan(line, log) {
+		return true
+	}
+	match := refreshingState.Match(line)
+	return afterPlan && match
+}
+
+// Returns true if a line marks the end of a run, e.g.
+// "Apply complete! Resources: 3 added, 1 changed, 4 destroyed."
+func endsRun(line string) bool {
+	match := applyComplete.Match(line)
+	return match
+}
+
+func (p *runProgress) isEmpty() bool {
+	return len(p.resources) == 0 && !p.refresh && !p.plan && !p.apply
+}
+
+// A run without resources or phases is not worth reporting
+func isEmptyRun(run ParsedLog) bool {
+	return len(run.Resources) == 0 && !run.ContainsRefresh && !run.ContainsPlan && !run.ContainsApply
+}
+
+// Add a run to the list, unless it is empty
+func appendRun(runs []ParsedLog, run ParsedLog) []ParsedLog {
+	if isEmptyRun(run) {
+		return runs
+	}
+	return append(runs, run)
+}
@@ -0,0 +1,223 @@
+package tfprofile
+
+import (
+	"bufio"
+	"io"
+	"time"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+// A Stream parses a Terraform log line by line and yields the events found
+// in it, as soon as the line that causes them is read. This allows tools to
+// react to a Terraform run while it is in progress, e.g.:
+//
+//	stream := NewStream(os.Stdin)
+//	for {
+//		event, err := stream.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//	}
+//
+// The log parsed so far is available with Log().
+//
+// Output of Terragrunt `run-all` commands is supported as well: lines are
+// routed to a separate log per unit, based on the prefix Terragrunt adds to
+// them (see terragrunt.go). Events carry the unit of the line.
+type Stream struct {
+	file *bufio.Scanner
+	log  ParsedLog
+	// Logs of Terragrunt units, and the units in order of appearance
+	units     map[string]*ParsedLog
+	unitOrder []string
+	// Current line and whether a parse function recognized it
+	line       string
+	lineNumber int
+	recognized bool
+	// Terraform output in the current line, without CI envelope (see ci.go)
+	// or Terragrunt prefix. Lines without Terraform output are noise.
+	output string
+	noise  bool
+	// Time at which a CI system logged the current line, zero if unknown
+	time time.Time
+	// Terragrunt unit that printed the current line, if any
+	unit       string
+	terragrunt bool
+	// A line that only Terragrunt prints was seen, see splitTerragruntPrefix
+	terragruntDetected bool
+	// Provider calls in trace output, see trace.go
+	trace *traceState
+	// Events of the current line that were not returned by Next() yet
+	pending []Event
+	// Last phase announced with a PhaseChanged event, by unit
+	phases map[string]Phase
+	// If not nil, parse coverage is recorded here
+	coverage *Coverage
+	// If not nil, the log is split into runs here, see runs.go
+	splitter *runSplitter
+	err      error
+}
+
+// Create a Stream that reads a Terraform log from r
+func NewStream(r io.Reader) *Stream {
+	return newStream(bufio.NewScanner(r))
+}
+
+func newStream(file *bufio.Scanner) *Stream {
+	return &Stream{
+		file:   file,
+		log:    ParsedLog{Resources: map[string]ResourceMetric{}},
+		units:  map[string]*ParsedLog{},
+		phases: map[string]Phase{},
+		trace:  newTraceState(),
+	}
+}
+
+// Return the next event in the log. Returns io.EOF when the log has been read
+// completely, or the error that stopped parsing.
+func (s *Stream) Next() (Event, error) {
+	for len(s.pending) == 0 {
+		if !s.scan() {
+			if s.err != nil {
+				return Event{}, s.err
+			}
+			return Event{}, io.EOF
+		}
+	}
+	event := s.pending[0]
+	s.pending = s.pending[1:]
+	return event, nil
+}
+
+// The log parsed so far. For Terragrunt output, the logs of all units are
+// combined: resources are stored as "[unit] address" (see UnitAddress).
+func (s *Stream) Log() ParsedLog {
+	if len(s.unitOrder) == 0 {
+		return s.log
+	}
+	return mergeUnits(s.log, s.units, s.unitOrder)
+}
+
+// Read and parse the next line. Afterwards, the line, whether it was
+// recognized and the events it caused are stored in the stream. Returns
+// false at the end of the input or when parsing fails.
+func (s *Stream) scan() bool {
+	if !s.file.Scan() {
+		s.err = s.file.Err()
+		return false
+	}
+	s.line = RemoveTerminalFormatting(s.file.Text())
+	s.lineNumber += 1
+
+	output, at, found := stripCIEnvelope(s.line)
+	s.time = at
+	s.noise = !found
+	s.unit, s.terragrunt = "", false
+	if found {
+		if record, isTrace := parseTraceRecord(output, at); isTrace {
+			// Internal logs of Terraform, not its output
+			if s.splitter != nil {
+				s.splitter.progressOf("", &s.log)
+			}
+			s.trace.add(record, &s.log)
+			if s.coverage != nil {
+				s.coverage.addMatch("trace")
+			}
+			s.output, s.noise, s.recognized = output, true, true
+			s.pending = nil
+			return true
+		}
+		s.terragruntDetected = s.terragruntDetected || isTerragruntLine(output)
+		s.unit, output, s.terragrunt = splitTerragruntPrefix(output, s.terragruntDetected)
+		// Terragrunt itself, not a Terraform run
+		s.noise = s.terragrunt && s.unit == ""
+	}
+	s.output = output
+	if s.noise {
+		s.recognized = false
+		s.pending = nil
+		return true
+	}
+
+	log := &s.log
+	if s.terragrunt {
+		log = s.unitLog(s.unit)
+	}
+	recognized, events, err := s.parseRun(output, log)
+	if err != nil {
+		s.err = err
+		return false
+	}
+	s.recognized = recognized
+
+	if phase, announced := s.phases[s.unit]; recognized && (!announced || log.CurrentPhase != phase) {
+		s.phases[s.unit] = log.CurrentPhase
+		events = append([]Event{{Type: PhaseChanged}}, events...)
+	}
+
+	for i := range events {
+		events[i].Line = s.lineNumber
+		events[i].Time = s.time
+		events[i].Phase = log.CurrentPhase
+		events[i].Unit = s.unit
+	}
+	s.pending = events
+	return true
+}
+
+// Parse a line with parseShared and return the events it caused. If the log
+// is split into runs, a new run is started before the line if needed, and
+// after the line if it ends the run. Terragrunt units finish their runs at
+// different times, so those runs only end when one of the units starts a new one.
+func (s *Stream) parseRun(line string, log *ParsedLog) (bool, []Event, error) {
+	if s.splitter == nil {
+		recognized, err := s.parseShared(line, log)
+		return recognized, log.TakeEvents(), err
+	}
+	progress := s.splitter.progressOf(s.unit, log)
+	if startsNewRun(line, progress, log) {
+		s.splitter.cut(&s.log, s.units)
+		progress = s.splitter.progressOf(s.unit, log)
+	}
+	recognized, events, err := progress.track(log, func() (bool, error) {
+		return s.parseShared(line, log)
+	})
+	if err == nil && !s.terragrunt && endsRun(line) {
+		s.splitter.cut(&s.log, s.units)
+	}
+	return recognized, events, err
+}
+
+// Parse a line into the log of the stream or of one of its units. All logs
+// share the same event indices, so resources of different units can be
+// compared chronologically after merging.
+func (s *Stream) parseShared(line string, log *ParsedLog) (bool, error) {
+	if log == &s.log {
+		return parseLine(line, log, s.coverage)
+	}
+	log.CurrentModificationStartedIndex = s.log.CurrentModificationStartedIndex
+	log.CurrentModificationEndedIndex = s.log.CurrentModificationEndedIndex
+	log.CurrentEvent = s.log.CurrentEvent
+	log.CurrentRefreshIndex = s.log.CurrentRefreshIndex
+
+	recognized, err := parseLine(line, log, s.coverage)
+
+	s.log.CurrentModificationStartedIndex = log.CurrentModificationStartedIndex
+	s.log.CurrentModificationEndedIndex = log.CurrentModificationEndedIndex
+	s.log.CurrentEvent = log.CurrentEvent
+	s.log.CurrentRefreshIndex = log.CurrentRefreshIndex
+	return recognized, err
+}
+
+// The log of a Terragrunt unit, created when the unit is first seen
+func (s *Stream) unitLog(unit string) *ParsedLog {
+	log, found := s.units[unit]
+	if !found {
+		log = &ParsedLog{Resources: map[string]ResourceMetric{}}
+		s.units[unit] = log
+		s.unitOrder = append(s.unitOrder, unit)
+	}
+	return log
+}
@@ -0,0 +1,70 @@
+package tfprofile
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	file, _ := os.Open("../../../test/all_operations.log")
+	stream := NewStream(file)
+
+	events := []Event{}
+	for {
+		event, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		// The log has no timestamps
+		assert.True(t, event.Time.IsZero())
+		events = append(events, event)
+	}
+
+	assert.Equal(t, 26, len(events))
+	assert.Equal(t, Event{Type: PhaseChanged, Line: 1, Phase: RefreshPhase}, events[0])
+	assert.Equal(t, Event{Type: PhaseChanged, Line: 14, Phase: PlanPhase}, events[1])
+	assert.Equal(t, Event{Type: PlanDecision, Line: 16, Phase: PlanPhase, Resource: "aws_ssm_parameter.p1", Operation: Replace, ReplaceReason: "tainted"}, events[2])
+	assert.Equal(t, Event{Type: Summary, Line: 87, Phase: PlanPhase, Counts: ChangeCounts{Add: 3, Change: 1, Destroy: 4}}, events[7])
+	assert.Equal(t, Event{Type: PhaseChanged, Line: 95, Phase: ApplyPhase}, events[8])
+	assert.Equal(t, Event{Type: ResourceStarted, Line: 95, Phase: ApplyPhase, Resource: "aws_ssm_parameter.p4", Operation: Destroy}, events[9])
+	assert.Equal(t, Event{Type: ResourceCompleted, Line: 108, Phase: ApplyPhase, Resource: "aws_ssm_parameter.p1", Operation: Create}, events[22])
+
+	// The stream builds the same log as Parse
+	file, _ = os.Open("../../../test/all_operations.log")
+	expected, err := Parse(bufio.NewScanner(file), false)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, stream.Log())
+}
+
+func TestStreamFailure(t *testing.T) {
+	in := `aws_ssm_parameter.p1: Creating...
+aws_ssm_parameter.p1: Creation complete after 1m2s [id=p1]
+aws_ssm_parameter.p2: Creating...
+│   with aws_ssm_parameter.p2,
+`
+	stream := NewStream(strings.NewReader(in))
+	expected := []Event{
+		{Type: PhaseChanged, Line: 1, Phase: ApplyPhase},
+		{Type: ResourceStarted, Line: 1, Phase: ApplyPhase, Resource: "aws_ssm_parameter.p1", Operation: Create},
+		{Type: ResourceCompleted, Line: 2, Phase: ApplyPhase, Resource: "aws_ssm_parameter.p1", Operation: Create, Duration: 62000},
+		{Type: ResourceStarted, Line: 3, Phase: ApplyPhase, Resource: "aws_ssm_parameter.p2", Operation: Create},
+		{Type: ResourceFailed, Line: 4, Phase: ApplyPhase, Resource: "aws_ssm_parameter.p2", Operation: Create},
+	}
+	for _, e := range expected {
+		event, err := stream.Next()
+		assert.Nil(t, err)
+		event.Time = time.Time{}
+		assert.Equal(t, e, event)
+	}
+	_, err := stream.Next()
+	assert.Equal(t, io.EOF, err)
+}
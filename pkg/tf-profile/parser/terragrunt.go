@@ -0,0 +1,136 @@
+package tfprofile
+
+import (
+	"regexp"
+	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+var (
+	// Output of a unit, as printed by Terragrunt >= 0.67:
+	// "14:03:21.441 STDOUT [live/vpc] terraform: aws_vpc.this: Creating..."
+	terragruntOutput = newMatcher("] ", `^(?:\d{2}:\d{2}:\d{2}(?:\.\d+)?\s+)?(?:STDOUT|STDERR)\s+\[(?P<unit>[^\]]+)\]\s+(?:terraform|tofu):(?: (?P<line>.*))?$`)
+	// Messages of Terragrunt itself, which contain no Terraform output:
+	// "14:03:20.112 INFO   [live/vpc] Downloading Terraform configurations..."
+	terragruntMessage = regexp.MustCompile(`^(?:\d{2}:\d{2}:\d{2}(?:\.\d+)?\s+)?(?:TRACE|DEBUG|INFO|WARN|ERROR)\s+`)
+	// Output of a unit, as printed by older versions with --terragrunt-include-module-prefix:
+	// "[/home/user/live/vpc] aws_vpc.this: Creating..."
+	terragruntPrefix = regexp.MustCompile(`^\[(?P<unit>[^\]\s]+)\] (?P<line>.*)$`)
+)
+
+// Terragrunt prints its own log lines as "[terragrunt] ..." in older versions
+const terragruntSelf = "terragrunt"
+
+// Detect lines printed by Terragrunt during a `run-all`. Returns the unit that
+// printed the line and the Terraform output without the Terragrunt prefix.
+// For messages of Terragrunt itself, found is true but unit is empty: those
+// lines contain no Terraform output. For other lines, found is false.
+//
+// Many other tools start their lines with a bracketed token as well (e.g.
+// "[INFO] ...", "[ci/job] ..." or "[2024/01/02] ..."), so the prefix of older
+// versions is only accepted once the log is known to come from Terragrunt (see
+// isTerragruntLine), or if the unit is an absolute path as printed by
+// --terragrunt-include-module-prefix.
+func splitTerragruntPrefix(line string, detected bool) (unit string, output string, found bool) {
+	if strings.HasPrefix(line, "[") {
+		match := terragruntPrefix.FindStringSubmatch(line)
+		if match != nil && match[1] == terragruntSelf {
+			return "", "", true
+		}
+		if match != nil && (detected || strings.HasPrefix(match[1], "/")) {
+			return match[1], match[2], true
+		}
+	}
+
+	if !terragruntOutput.Match(line) {
+		if terragruntMessage.MatchString(line) {
+			return "", "", true
+		}
+		return "", line, false
+	}
+	match := terragruntOutput.re.FindStringSubmatch(line)
+	return match[1], match[2], true
+}
+
+// Returns true for lines that are only printed by Terragrunt: its own log
+// lines in older versions, or the output of a unit in newer versions
+func isTerragruntLine(line string) bool {
+	return strings.HasPrefix(line, "["+terragruntSelf+"] ") || terragruntOutput.Match(line)
+}
+
+// Combine the logs of all Terragrunt units into one log. Resources are stored
+// under their unit address (see UnitAddress). Summary lines are added up, as
+// long as every unit printed one.
+func mergeUnits(base ParsedLog, units map[string]*ParsedLog, order []string) ParsedLog {
+	merged := base
+	merged.Units = order
+	merged.Resources = map[string]ResourceMetric{}
+	for resource, metric := range base.Resources {
+		merged.Resources[resource] = metric
+	}
+	merged.PlannedChanges = map[string]PlannedChange{}
+	for resource, change := range base.PlannedChanges {
+		merged.PlannedChanges[resource] = change
+	}
+
+	planSummaries := []*ChangeCounts{}
+	applySummaries := []*ChangeCounts{}
+	if base.PlanSummary != nil {
+		planSummaries = append(planSummaries, base.PlanSummary)
+	}
+	if base.ApplySummary != nil {
+		applySummaries = append(applySummaries, base.ApplySummary)
+	}
+
+	for _, unit := range order {
+		log := units[unit]
+		for resource, metric := range log.Resources {
+			metric.Unit = unit
+			if metric.MovedFrom != "" {
+				metric.MovedFrom = UnitAddress(unit, metric.MovedFrom)
+			}
+			merged.Resources[UnitAddress(unit, resource)] = metric
+		}
+		for resource, change := range log.PlannedChanges {
+			merged.PlannedChanges[UnitAddress(unit, resource)] = change
+		}
+
+		if merged.Tool == UnknownTool {
+			merged.Tool = log.Tool
+			merged.ToolVersion = log.ToolVersion
+		}
+		merged.ContainsRefresh = merged.ContainsRefresh || log.ContainsRefresh
+		merged.ContainsPlan = merged.ContainsPlan || log.ContainsPlan
+		merged.ContainsApply = merged.ContainsApply || log.ContainsApply
+		merged.ApplyCounts = addCounts(merged.ApplyCounts, log.ApplyCounts)
+		planSummaries = append(planSummaries, log.PlanSummary)
+		applySummaries = append(applySummaries, log.ApplySummary)
+	}
+
+	merged.PlanSummary = sumSummaries(planSummaries)
+	merged.ApplySummary = sumSummaries(applySummaries)
+	return merged
+}
+
+// Add up summary lines, nil if any of them is missing
+func sumSummaries(summaries []*ChangeCounts) *ChangeCounts {
+	sum := ChangeCounts{}
+	for _, summary := range summaries {
+		if summary == nil {
+			return nil
+		}
+		sum = addCounts(sum, *summary)
+	}
+	return &sum
+}
+
+func addCounts(a ChangeCounts, b ChangeCounts) ChangeCounts {
+	return ChangeCounts{
+		Import:  a.Import + b.Import,
+		Add:     a.Add + b.Add,
+		Change:  a.Change + b.Change,
+		Destroy: a.Destroy + b.Destroy,
+		Forget:  a.Forget + b.Forget,
+	}
+}
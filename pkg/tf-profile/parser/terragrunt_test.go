@@ -0,0 +1,126 @@
+package tfprofile
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitTerragruntPrefix(t *testing.T) {
+	tests := []struct {
+		line     string
+		detected bool
+		unit     string
+		output   string
+		found    bool
+	}{
+		{"14:03:21.441 STDOUT [live/vpc] terraform: aws_vpc.this: Creating...", false, "live/vpc", "aws_vpc.this: Creating...", true},
+		{"STDOUT [vpc] tofu:   + resource \"aws_vpc\" \"this\" {", false, "vpc", "  + resource \"aws_vpc\" \"this\" {", true},
+		{"14:03:21.441 STDOUT [vpc] terraform:", false, "vpc", "", true},
+		{"[/home/user/live/vpc] aws_vpc.this: Creating...", false, "/home/user/live/vpc", "aws_vpc.this: Creating...", true},
+		{"[vpc] aws_vpc.this: Creating...", true, "vpc", "aws_vpc.this: Creating...", true},
+		{"14:03:20.305 INFO   [vpc] Downloading Terraform configurations", false, "", "", true},
+		{"[terragrunt] 2023/01/01 12:00:00 Running command: terraform apply", false, "", "", true},
+		{"aws_vpc.this: Creating...", false, "", "aws_vpc.this: Creating...", false},
+		{"      + tags = [\"a\"]", false, "", "      + tags = [\"a\"]", false},
+		// Bracketed tokens of other tools, without Terragrunt in the log
+		{"[step-3] aws_vpc.this: Creating...", false, "", "[step-3] aws_vpc.this: Creating...", false},
+		{"[INFO] Running terraform apply", false, "", "[INFO] Running terraform apply", false},
+		{"[ci/job] aws_vpc.this: Creating...", false, "", "[ci/job] aws_vpc.this: Creating...", false},
+		{"[2024/01/02] aws_vpc.this: Creating...", false, "", "[2024/01/02] aws_vpc.this: Creating...", false},
+	}
+	for _, test := range tests {
+		unit, output, found := splitTerragruntPrefix(test.line, test.detected)
+		assert.Equal(t, test.unit, unit, test.line)
+		assert.Equal(t, test.output, output, test.line)
+		assert.Equal(t, test.found, found, test.line)
+	}
+}
+
+func TestBracketedLinesWithoutTerragrunt(t *testing.T) {
+	in := `[INFO] Running terraform apply
+[2024/01/02] null_resource.w: Creating...
+[ci/job] null_resource.w: Creation complete after 1s [id=0]
+[step-3] null_resource.x: Creating...
+[step-3] null_resource.x: Creation complete after 1s [id=1]
+null_resource.y: Creating...
+null_resource.y: Creation complete after 2s [id=2]
+`
+	stream := NewStream(strings.NewReader(in))
+	for _, err := stream.Next(); err == nil; _, err = stream.Next() {
+	}
+	log := stream.Log()
+	assert.Equal(t, 0, len(log.Units))
+	for resource, metric := range log.Resources {
+		assert.Equal(t, "", metric.Unit, resource)
+	}
+	assert.Equal(t, Created, log.Resources["null_resource.y"].AfterStatus)
+
+	// The same prefix is a unit once Terragrunt printed a line of its own
+	in = `[terragrunt] 2023/01/01 12:00:00 Running command: terraform apply
+[vpc] null_resource.x: Creating...
+[vpc] null_resource.x: Creation complete after 1s [id=1]
+`
+	stream = NewStream(strings.NewReader(in))
+	for _, err := stream.Next(); err == nil; _, err = stream.Next() {
+	}
+	log = stream.Log()
+	assert.Equal(t, []string{"vpc"}, log.Units)
+	assert.Equal(t, Created, log.Resources["[vpc] null_resource.x"].AfterStatus)
+}
+
+func TestParseTerragrunt(t *testing.T) {
+	file, _ := os.Open("../../../test/terragrunt.log")
+	log, runs, err := ParseRuns(bufio.NewScanner(file), false, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(runs))
+
+	assert.Equal(t, []string{"network", "queue"}, log.Units)
+	assert.Equal(t, 4, len(log.Resources))
+	assert.Equal(t, "network", log.Resources["[network] aws_vpc.this"].Unit)
+	assert.Equal(t, float64(14000), log.Resources["[network] aws_vpc.this"].TotalTime)
+	assert.Equal(t, Created, log.Resources["[queue] aws_sqs_queue.jobs"].AfterStatus)
+	assert.Equal(t, Create, log.PlannedChanges["[network] aws_subnet.private[1]"].Operation)
+
+	// Event indices are shared by all units
+	assert.Equal(t, 0, log.Resources["[queue] aws_sqs_queue.jobs"].ModificationStartedEvent)
+	assert.Equal(t, 1, log.Resources["[network] aws_vpc.this"].ModificationStartedEvent)
+	assert.Equal(t, 2, log.Resources["[queue] aws_sqs_queue.jobs"].ModificationCompletedEvent)
+
+	// Summary lines of all units are added up
+	assert.Equal(t, &ChangeCounts{Add: 4}, log.PlanSummary)
+	assert.Equal(t, &ChangeCounts{Add: 4}, log.ApplySummary)
+	assert.Equal(t, []string{}, log.SummaryMismatches())
+}
+
+func TestStreamTerragrunt(t *testing.T) {
+	in := `STDOUT [a] terraform: null_resource.x: Creating...
+STDOUT [b] terraform: null_resource.x: Creating...
+STDOUT [a] terraform: null_resource.x: Creation complete after 1s [id=1]
+`
+	stream := NewStream(strings.NewReader(in))
+	events := []Event{}
+	for event, err := stream.Next(); err == nil; event, err = stream.Next() {
+		event.Time = time.Time{}
+		events = append(events, event)
+	}
+	expected := []Event{
+		{Type: PhaseChanged, Line: 1, Phase: ApplyPhase, Unit: "a"},
+		{Type: ResourceStarted, Line: 1, Phase: ApplyPhase, Unit: "a", Resource: "null_resource.x", Operation: Create},
+		{Type: PhaseChanged, Line: 2, Phase: ApplyPhase, Unit: "b"},
+		{Type: ResourceStarted, Line: 2, Phase: ApplyPhase, Unit: "b", Resource: "null_resource.x", Operation: Create},
+		{Type: ResourceCompleted, Line: 3, Phase: ApplyPhase, Unit: "a", Resource: "null_resource.x", Operation: Create, Duration: 1000},
+	}
+	assert.Equal(t, expected, events)
+
+	log := stream.Log()
+	assert.Equal(t, []string{"a", "b"}, log.Units)
+	assert.Equal(t, Created, log.Resources["[a] null_resource.x"].AfterStatus)
+	assert.Equal(t, -1, log.Resources["[b] null_resource.x"].ModificationCompletedEvent)
+}
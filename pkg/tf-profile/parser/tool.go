@@ -0,0 +1,100 @@
+package tfprofile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+var (
+	// Printed by `terraform version` and `tofu version`, e.g. "OpenTofu v1.6.2 on linux_amd64"
+	toolVersion = regexp.MustCompile(`^(Terraform|OpenTofu) v(\d+\.\d+\.\d+\S*)`)
+	// Banners that name the tool, e.g. "OpenTofu used the selected providers to generate..."
+	toolBanner = regexp.MustCompile(`^(?:(Terraform|OpenTofu) (?:used the selected providers|will perform the following actions|has been successfully initialized|has compared your real infrastructure)|(?:Note: )?Objects have changed outside of (Terraform|OpenTofu))`)
+)
+
+// Wording that differs between Terraform and OpenTofu
+type dialect struct {
+	// Start of the plan, e.g. "Terraform will perform the following actions:"
+	startPlan string
+	// Resource removed from the state without being destroyed (`removed` block).
+	// The first capture is the resource.
+	willBeForgotten []matcher
+}
+
+// Parse patterns per tool. Once the tool that printed a log is detected, only
+// its own patterns are used. Until then, the patterns of all tools are tried.
+// Patterns do not depend on the version of the tool, which is only reported.
+var dialects = map[Tool]dialect{
+	Terraform: {
+		startPlan: "Terraform will perform the following actions:",
+		willBeForgotten: []matcher{
+			newMatcher(" will no longer be managed by Terraform", fmt.Sprintf("# (%v) will no longer be managed by Terraform", resourceName)),
+		},
+	},
+	OpenTofu: {
+		startPlan: "OpenTofu will perform the following actions:",
+		willBeForgotten: []matcher{
+			newMatcher(" will be removed from the OpenTofu state", fmt.Sprintf("# (%v) will be removed from the OpenTofu state but will not be destroyed", resourceName)),
+			newMatcher(" will no longer be managed by OpenTofu", fmt.Sprintf("# (%v) will no longer be managed by OpenTofu", resourceName)),
+		},
+	},
+}
+
+// Tools in the order in which their patterns are tried
+var tools = []Tool{Terraform, OpenTofu}
+
+// Dialects to try for a log: only the one of the detected tool, if any
+func dialectsFor(log *ParsedLog) []dialect {
+	if log.Tool != UnknownTool {
+		return []dialect{dialects[log.Tool]}
+	}
+	result := []dialect{}
+	for _, tool := range tools {
+		result = append(result, dialects[tool])
+	}
+	return result
+}
+
+// Returns true if the line announces the start of a plan
+func isStartPlan(line string, log *ParsedLog) bool {
+	for _, d := range dialectsFor(log) {
+		if strings.Contains(line, d.startPlan) {
+			return true
+		}
+	}
+	return false
+}
+
+// Detect the tool that printed the log (and its version) from version lines
+// and banners. A version line always wins: it is printed once, by the tool
+// itself, while banners may appear in the output of a wrapper as well.
+func detectTool(line string, log *ParsedLog) {
+	if !strings.Contains(line, "Terraform") && !strings.Contains(line, "OpenTofu") {
+		return
+	}
+	if match := toolVersion.FindStringSubmatch(line); match != nil {
+		log.Tool = parseTool(match[1])
+		log.ToolVersion = match[2]
+		return
+	}
+	if log.Tool != UnknownTool {
+		return
+	}
+	if match := toolBanner.FindStringSubmatch(line); match != nil {
+		log.Tool = parseTool(match[1] + match[2])
+	}
+}
+
+func parseTool(in string) Tool {
+	switch in {
+	case "Terraform":
+		return Terraform
+	case "OpenTofu":
+		return OpenTofu
+	default:
+		return UnknownTool
+	}
+}
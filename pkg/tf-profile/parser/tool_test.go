@@ -0,0 +1,78 @@
+package tfprofile
+
+import (
+	"bufio"
+	"os"
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectTool(t *testing.T) {
+	tests := []struct {
+		lines   []string
+		tool    Tool
+		version string
+	}{
+		{[]string{"Terraform v1.5.7"}, Terraform, "1.5.7"},
+		{[]string{"OpenTofu v1.6.2", "on linux_amd64"}, OpenTofu, "1.6.2"},
+		{[]string{"Terraform v1.8.0-beta1 on darwin_arm64"}, Terraform, "1.8.0-beta1"},
+		{[]string{"OpenTofu has been successfully initialized!"}, OpenTofu, ""},
+		{[]string{"Note: Objects have changed outside of OpenTofu"}, OpenTofu, ""},
+		{[]string{"Terraform will perform the following actions:"}, Terraform, ""},
+		// The first banner wins, a version line always wins
+		{[]string{"Terraform used the selected providers to generate the following execution", "OpenTofu will perform the following actions:"}, Terraform, ""},
+		{[]string{"Terraform has been successfully initialized!", "OpenTofu v1.7.0"}, OpenTofu, "1.7.0"},
+		{[]string{"aws_vpc.this: Creating...", "# module.terraform.aws_vpc.this will be created"}, UnknownTool, ""},
+	}
+	for _, test := range tests {
+		log := ParsedLog{}
+		for _, line := range test.lines {
+			detectTool(line, &log)
+		}
+		assert.Equal(t, test.tool, log.Tool, test.lines)
+		assert.Equal(t, test.version, log.ToolVersion, test.lines)
+	}
+}
+
+func TestDialects(t *testing.T) {
+	// Until the tool is known, the patterns of all tools are tried
+	log := ParsedLog{}
+	assert.True(t, isStartPlan("OpenTofu will perform the following actions:", &log))
+	assert.True(t, isStartPlan("Terraform will perform the following actions:", &log))
+
+	log.Tool = Terraform
+	assert.False(t, isStartPlan("OpenTofu will perform the following actions:", &log))
+
+	log = ParsedLog{Resources: map[string]ResourceMetric{}}
+	ok, err := parsePlanWillBeForgotten("  # aws_ssm_parameter.legacy will be removed from the OpenTofu state but will not be destroyed", &log)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, Forget, log.PlannedChanges["aws_ssm_parameter.legacy"].Operation)
+
+	log.Tool = Terraform
+	assert.Equal(t, "", forgottenResource("  # aws_ssm_parameter.legacy will no longer be managed by OpenTofu", &log))
+	assert.Equal(t, "aws_ssm_parameter.legacy", forgottenResource("  # aws_ssm_parameter.legacy will no longer be managed by Terraform", &log))
+}
+
+func TestParseOpenTofu(t *testing.T) {
+	file, _ := os.Open("../../../test/opentofu.log")
+	log, err := Parse(bufio.NewScanner(file), false)
+	assert.Nil(t, err)
+	assert.Equal(t, OpenTofu, log.Tool)
+	assert.Equal(t, "1.6.2", log.ToolVersion)
+	assert.True(t, log.ContainsRefresh && log.ContainsPlan && log.ContainsApply)
+	assert.Equal(t, DriftChanged, log.Resources["aws_ssm_parameter.p2"].Drift)
+	assert.Equal(t, Modify, log.PlannedChanges["aws_ssm_parameter.p2"].Operation)
+	assert.Equal(t, []string{}, log.SummaryMismatches())
+
+	file, _ = os.Open("../../../test/opentofu_removed.log")
+	log, err = Parse(bufio.NewScanner(file), false)
+	assert.Nil(t, err)
+	assert.Equal(t, OpenTofu, log.Tool)
+	assert.Equal(t, "", log.ToolVersion)
+	assert.Equal(t, Forget, log.Resources["aws_ssm_parameter.legacy"].Operation)
+	assert.Equal(t, []string{}, log.SummaryMismatches())
+}
@@ -0,0 +1,201 @@
+package tfprofile
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+// With TF_LOG=trace (or debug), Terraform writes its internal logs to stderr,
+// with TF_LOG=json in JSON format. They contain the RPCs Terraform sends to
+// providers and, for most providers, the HTTP requests these make. Trace lines
+// are recognized in any log (e.g. `terraform apply 2>&1`) and are used to
+// attribute RPC durations, HTTP status codes and retries to resources.
+//
+// Provider logs name the resource type, but not the resource address. An RPC
+// is attributed to the resource of that type for which Terraform core most
+// recently announced the operation, in first-in-first-out order. Announcements
+// without an RPC are dropped when the next graph walk (e.g. the apply after
+// the plan) starts, so they cannot shift the calls of later walks.
+var (
+	// "2024-01-15T10:23:45.123+0100 [TRACE] provider.terraform-provider-aws_v5.31.0_x5: Received request: @module=sdk.proto tf_rpc=ApplyResourceChange ..."
+	// The timestamp may have been stripped as part of a CI envelope.
+	traceLine = regexp.MustCompile(`^(?:(?P<time>\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2}))\s+)?\[(?P<level>TRACE|DEBUG|INFO|WARN|ERROR)\]\s+(?P<message>.*)$`)
+	// Provider name in front of the message, e.g. "provider.terraform-provider-aws_v5.31.0_x5: "
+	traceModule = regexp.MustCompile(`^(provider(?:\.\S+)?): `)
+	// Fields after the message, e.g. "tf_rpc=ApplyResourceChange" or `http.url="https://..."`
+	traceField = regexp.MustCompile(`([@\w.]+)=("(?:[^"\\]|\\.)*"|\S*)`)
+
+	// Messages of Terraform core that announce an operation on a resource,
+	// and the RPC that is sent for it.
+	coreOperations = []struct {
+		rpc string
+		re  *regexp.Regexp
+	}{
+		{"ApplyResourceChange", regexp.MustCompile(`^(\S+): applying the planned \w+ change$`)},
+		{"PlanResourceChange", regexp.MustCompile(`^Re-validating config for "(.+)"$`)},
+		{"ReadResource", regexp.MustCompile(`^NodeAbstractResourceInstance\.refresh for (\S+)$`)},
+		{"ReadDataSource", regexp.MustCompile(`^readDataSource: Re-validating config for "?([^"\s]+)"?$`)},
+	}
+	// "Starting graph walk: walkApply"
+	graphWalk = regexp.MustCompile(`^Starting graph walk: \w+$`)
+)
+
+// Layouts of timestamps in trace logs
+var traceTimeLayouts = []string{"2006-01-02T15:04:05.000Z0700", time.RFC3339Nano}
+
+// A line of trace output, in text or JSON format
+type traceRecord struct {
+	time    time.Time
+	message string
+	fields  map[string]string
+}
+
+// An RPC sent to a provider, between "Received request" and "Served request"
+type providerCall struct {
+	resource string
+	rpc      string
+	start    time.Time
+	// The last HTTP response was throttled or failed, so the next request is a retry
+	retrying bool
+}
+
+// State needed to attribute trace lines to resources
+type traceState struct {
+	// Resources for which core announced an operation, by RPC and resource type
+	announced map[string][]string
+	// RPCs in progress, by tf_req_id
+	calls map[string]*providerCall
+}
+
+func newTraceState() *traceState {
+	return &traceState{announced: map[string][]string{}, calls: map[string]*providerCall{}}
+}
+
+// Parse a line of trace output. Returns false for other lines. at is used
+// when the line has no timestamp of its own (e.g. stripped by CI).
+func parseTraceRecord(line string, at time.Time) (traceRecord, bool) {
+	if strings.HasPrefix(line, `{"@level":`) {
+		return parseJSONTraceRecord(line)
+	}
+	if line == "" || (line[0] != '[' && !isDigit(line[0])) {
+		return traceRecord{}, false
+	}
+	match := traceLine.FindStringSubmatch(line)
+	if match == nil {
+		return traceRecord{}, false
+	}
+
+	record := traceRecord{time: at, message: match[3], fields: map[string]string{}}
+	if match[1] != "" {
+		record.time = parseTraceTime(match[1])
+	}
+	if module := traceModule.FindStringSubmatch(record.message); module != nil {
+		record.fields["@provider"] = module[1]
+		record.message = record.message[len(module[0]):]
+	}
+	// Fields follow the message after ": ", e.g. "Received request: tf_rpc=..."
+	if idx := strings.Index(record.message, ": "); idx >= 0 {
+		rest := record.message[idx+2:]
+		first := strings.SplitN(rest, " ", 2)[0]
+		if strings.Contains(first, "=") {
+			record.message = record.message[:idx]
+			for _, field := range traceField.FindAllStringSubmatch(rest, -1) {
+				record.fields[field[1]] = strings.Trim(field[2], `"`)
+			}
+		}
+	}
+	return record, true
+}
+
+// Parse a line of TF_LOG=json output
+func parseJSONTraceRecord(line string) (traceRecord, bool) {
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return traceRecord{}, false
+	}
+	record := traceRecord{fields: map[string]string{}}
+	for key, value := range raw {
+		switch key {
+		case "@message":
+			record.message = fmt.Sprint(value)
+		case "@timestamp":
+			record.time = parseTraceTime(fmt.Sprint(value))
+		default:
+			record.fields[key] = fmt.Sprint(value)
+		}
+	}
+	return record, true
+}
+
+func parseTraceTime(in string) time.Time {
+	for _, layout := range traceTimeLayouts {
+		at, err := time.Parse(layout, in)
+		if err == nil {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+// Record what a line of trace output says about provider calls
+func (t *traceState) add(record traceRecord, log *ParsedLog) {
+	log.ContainsTrace = true
+
+	if graphWalk.MatchString(record.message) {
+		t.announced = map[string][]string{}
+		return
+	}
+	for _, op := range coreOperations {
+		if match := op.re.FindStringSubmatch(record.message); match != nil {
+			key := op.rpc + " " + ResourceType(match[1])
+			t.announced[key] = append(t.announced[key], match[1])
+			return
+		}
+	}
+
+	id := record.fields["tf_req_id"]
+	if id == "" {
+		return
+	}
+	switch record.message {
+	case "Received request":
+		rpc := record.fields["tf_rpc"]
+		key := rpc + " " + record.fields["tf_resource_type"]
+		call := &providerCall{rpc: rpc, start: record.time}
+		if queue := t.announced[key]; len(queue) > 0 {
+			call.resource = queue[0]
+			t.announced[key] = queue[1:]
+		}
+		t.calls[id] = call
+	case "Served request":
+		call, found := t.calls[id]
+		if !found {
+			return
+		}
+		Duration := float64(0)
+		if !call.start.IsZero() && !record.time.IsZero() {
+			Duration = float64(record.time.Sub(call.start).Milliseconds())
+		}
+		log.AddProviderCall(call.resource, call.rpc, Duration)
+		delete(t.calls, id)
+	case "HTTP Request Sent":
+		if call, found := t.calls[id]; found {
+			log.AddHTTPRequest(call.resource, call.retrying)
+			call.retrying = false
+		}
+	case "HTTP Response Received":
+		call, found := t.calls[id]
+		status, err := strconv.Atoi(record.fields["http.status_code"])
+		if !found || err != nil {
+			return
+		}
+		log.AddHTTPResponse(call.resource, status)
+		call.retrying = IsRetryableStatus(status)
+	}
+}
@@ -0,0 +1,94 @@
+package tfprofile
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceRecord(t *testing.T) {
+	record, ok := parseTraceRecord(`2024-01-15T10:23:42.150+0100 [DEBUG] provider.terraform-provider-aws_v5.31.0_x5: HTTP Response Received: @module=aws http.status_code=429 http.response.body="{\"message\":\"Rate exceeded\"}" tf_req_id=1`, time.Time{})
+	assert.True(t, ok)
+	assert.Equal(t, "HTTP Response Received", record.message)
+	assert.Equal(t, "provider.terraform-provider-aws_v5.31.0_x5", record.fields["@provider"])
+	assert.Equal(t, "aws", record.fields["@module"])
+	assert.Equal(t, "429", record.fields["http.status_code"])
+	assert.Equal(t, "1", record.fields["tf_req_id"])
+	assert.Equal(t, 150, record.time.Nanosecond()/int(time.Millisecond))
+
+	// Without timestamp (stripped by CI), the time of the envelope is used
+	at := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	record, ok = parseTraceRecord(`[TRACE] Re-validating config for "aws_ssm_parameter.p1"`, at)
+	assert.True(t, ok)
+	assert.Equal(t, `Re-validating config for "aws_ssm_parameter.p1"`, record.message)
+	assert.Equal(t, at, record.time)
+
+	record, ok = parseTraceRecord(`{"@level":"trace","@message":"Served request","@timestamp":"2024-01-15T10:23:43.504000+01:00","tf_req_id":"1","tf_rpc":"ApplyResourceChange"}`, time.Time{})
+	assert.True(t, ok)
+	assert.Equal(t, "Served request", record.message)
+	assert.Equal(t, "ApplyResourceChange", record.fields["tf_rpc"])
+	assert.False(t, record.time.IsZero())
+
+	for _, line := range []string{"aws_ssm_parameter.p1: Creating...", "Plan: 1 to add, 0 to change, 0 to destroy.", "[live/vpc] aws_vpc.this: Creating..."} {
+		_, ok = parseTraceRecord(line, time.Time{})
+		assert.False(t, ok, line)
+	}
+}
+
+func TestParseTrace(t *testing.T) {
+	file, _ := os.Open("../../../test/trace.log")
+	log, err := Parse(bufio.NewScanner(file), false)
+	assert.Nil(t, err)
+	assert.True(t, log.ContainsTrace)
+	assert.Equal(t, ProviderRPC{Calls: 2, TotalTime: 2195}, log.ProviderRPCs["ApplyResourceChange"])
+
+	p1 := log.Resources["aws_ssm_parameter.p1"]
+	assert.Equal(t, 3, p1.ProviderCalls)
+	assert.Equal(t, float64(995), p1.ProviderTime)
+	assert.Equal(t, 2, p1.HTTPRequests)
+	assert.Equal(t, 0, p1.HTTPRetries)
+
+	p2 := log.Resources["aws_ssm_parameter.p2"]
+	assert.Equal(t, 2, p2.ProviderCalls)
+	assert.Equal(t, float64(1549), p2.ProviderTime)
+	assert.Equal(t, 3, p2.HTTPRequests)
+	assert.Equal(t, 2, p2.HTTPRetries)
+	assert.Equal(t, 1, p2.HTTPThrottled)
+	assert.Equal(t, 1, p2.HTTPServerErrors)
+	assert.Equal(t, Created, p2.AfterStatus)
+}
+
+func TestTraceAnnouncementWithoutCall(t *testing.T) {
+	in := `2024-01-15T10:23:41.000+0100 [DEBUG] Starting graph walk: walkPlan
+2024-01-15T10:23:41.001+0100 [TRACE] Re-validating config for "aws_ssm_parameter.p1"
+2024-01-15T10:23:42.000+0100 [DEBUG] Starting graph walk: walkApply
+2024-01-15T10:23:42.001+0100 [TRACE] Re-validating config for "aws_ssm_parameter.p2"
+2024-01-15T10:23:42.002+0100 [TRACE] provider.terraform-provider-aws_v5.31.0_x5: Received request: tf_req_id=1 tf_resource_type=aws_ssm_parameter tf_rpc=PlanResourceChange
+2024-01-15T10:23:42.102+0100 [TRACE] provider.terraform-provider-aws_v5.31.0_x5: Served request: tf_req_id=1 tf_resource_type=aws_ssm_parameter tf_rpc=PlanResourceChange
+`
+	log, err := Parse(bufio.NewScanner(strings.NewReader(in)), false)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, log.Resources["aws_ssm_parameter.p1"].ProviderCalls)
+	assert.Equal(t, 1, log.Resources["aws_ssm_parameter.p2"].ProviderCalls)
+	assert.Equal(t, float64(100), log.Resources["aws_ssm_parameter.p2"].ProviderTime)
+}
+
+func TestParseJSONTrace(t *testing.T) {
+	file, _ := os.Open("../../../test/trace_json.log")
+	log, err := Parse(bufio.NewScanner(file), false)
+	assert.Nil(t, err)
+	assert.True(t, log.ContainsTrace)
+
+	p2 := log.Resources["aws_ssm_parameter.p2"]
+	assert.Equal(t, 1, p2.ProviderCalls)
+	assert.Equal(t, float64(1500), p2.ProviderTime)
+	assert.Equal(t, 2, p2.HTTPRequests)
+	assert.Equal(t, 1, p2.HTTPRetries)
+	assert.Equal(t, 1, p2.HTTPThrottled)
+}
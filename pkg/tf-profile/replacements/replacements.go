@@ -0,0 +1,166 @@
+package tfprofile
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/parser"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/readers"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/runs"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/utils"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// One resource that Terraform planned to replace
+type replacement struct {
+	resourceType string
+	resource     string
+	reason       string
+	// Attributes marked with "# forces replacement"
+	forcedBy  []string
+	totalTime float64
+}
+
+// Execute the `tf-profile replacements` command
+func Replacements(args []string, tee bool, run int, strict bool) error {
+	var file *bufio.Scanner
+	var err error
+
+	if len(args) == 1 {
+		file, err = FileReader{File: args[0]}.Read()
+	} else {
+		file, err = StdinReader{}.Read()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	tflog, runs, err := ParseRuns(file, tee, strict)
+	if err != nil {
+		return err
+	}
+
+	PrintRunSummary(os.Stdout, runs)
+	tflog, err = SelectRun(tflog, runs, run)
+	if err != nil {
+		return err
+	}
+
+	PrintReplacements(tflog)
+	return nil
+}
+
+// Print two tables: how often each attribute forced a replacement (grouped by
+// resource type) and the details of every replaced resource.
+func PrintReplacements(log ParsedLog) {
+	headerFmt := color.New(color.FgHiBlue, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgBlue).SprintfFunc()
+
+	replacements := getReplacements(log)
+	if len(replacements) == 0 {
+		fmt.Println("\nNo replacements found in plan.")
+		return
+	}
+
+	counts := table.New("resource_type", "cause", "replacements")
+	counts.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	for _, row := range countCauses(replacements) {
+		counts.AddRow(row.resourceType, row.cause, row.count)
+	}
+
+	details := table.New("resource_type", "resource", "reason", "forced_by", "tot_time")
+	details.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	for _, r := range replacements {
+		details.AddRow(
+			r.resourceType,
+			r.resource,
+			r.reason,
+			strings.Join(r.forcedBy, ", "),
+			FormatDuration(int(r.totalTime/1000)),
+		)
+	}
+
+	fmt.Println() // Create space above the table
+	counts.Print()
+	fmt.Println()
+	details.Print()
+}
+
+// Collect all resources planned for replacement, sorted by resource type and name.
+func getReplacements(log ParsedLog) []replacement {
+	result := []replacement{}
+	for resource, change := range log.PlannedChanges {
+		if change.Operation != Replace {
+			continue
+		}
+		forcedBy := []string{}
+		for _, attribute := range change.Attributes {
+			if attribute.ForcesReplacement {
+				forcedBy = append(forcedBy, attribute.Name)
+			}
+		}
+		totalTime := float64(0)
+		if metric, found := log.Resources[resource]; found && metric.TotalTime > 0 {
+			totalTime = metric.TotalTime
+		}
+		result = append(result, replacement{
+			resourceType: ResourceType(resource),
+			resource:     resource,
+			reason:       change.ReplaceReason,
+			forcedBy:     forcedBy,
+			totalTime:    totalTime,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].resourceType != result[j].resourceType {
+			return result[i].resourceType < result[j].resourceType
+		}
+		return result[i].resource < result[j].resource
+	})
+	return result
+}
+
+type causeCount struct {
+	resourceType string
+	cause        string
+	count        int
+}
+
+// Count how often each cause led to a replacement, per resource type. A cause is
+// either an attribute that forces replacement, or "(tainted)" / "(requested)".
+func countCauses(replacements []replacement) []causeCount {
+	counts := map[[2]string]int{}
+	for _, r := range replacements {
+		causes := r.forcedBy
+		if len(causes) == 0 {
+			causes = []string{fmt.Sprintf("(%v)", r.reason)}
+		}
+		for _, cause := range causes {
+			counts[[2]string{r.resourceType, cause}] += 1
+		}
+	}
+
+	result := []causeCount{}
+	for key, count := range counts {
+		result = append(result, causeCount{key[0], key[1], count})
+	}
+
+	// Sort by type, then most frequent causes first
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].resourceType != result[j].resourceType {
+			return result[i].resourceType < result[j].resourceType
+		}
+		if result[i].count != result[j].count {
+			return result[i].count > result[j].count
+		}
+		return result[i].cause < result[j].cause
+	})
+	return result
+}
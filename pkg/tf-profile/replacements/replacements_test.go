@@ -0,0 +1,45 @@
+package tfprofile
+
+import (
+	"bufio"
+	"os"
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReplacements(t *testing.T) {
+	file, _ := os.Open("../../../test/all_operations.log")
+	log, err := Parse(bufio.NewScanner(file), false)
+	assert.Nil(t, err)
+
+	Out := getReplacements(log)
+	assert.Equal(t, 3, len(Out))
+
+	assert.Equal(t, "aws_ssm_parameter.p1", Out[0].resource)
+	assert.Equal(t, "tainted", Out[0].reason)
+	assert.Equal(t, "aws_ssm_parameter.p3", Out[1].resource)
+	assert.Equal(t, "requested", Out[1].reason)
+	assert.Equal(t, "aws_ssm_parameter.p6", Out[2].resource)
+	assert.Equal(t, "forced", Out[2].reason)
+	assert.Equal(t, []string{"name"}, Out[2].forcedBy)
+
+	Counts := countCauses(Out)
+	Expected := []causeCount{
+		{"aws_ssm_parameter", "(requested)", 1},
+		{"aws_ssm_parameter", "(tainted)", 1},
+		{"aws_ssm_parameter", "name", 1},
+	}
+	assert.Equal(t, Expected, Counts)
+}
+
+func TestReplacements(t *testing.T) {
+	err := Replacements([]string{"../../../test/all_operations.log"}, false, 0, false)
+	assert.Nil(t, err)
+	err = Replacements([]string{"../../../test/multiple_resources.log"}, false, 0, false)
+	assert.Nil(t, err)
+	err = Replacements([]string{"does-not-exist"}, false, 0, false)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,78 @@
+package tfprofile
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/utils"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// Select which log to profile. With run = 0, the full log is used (all runs
+// parsed as one). Otherwise, the Nth run is selected (starting from 1).
+func SelectRun(tflog ParsedLog, runs []ParsedLog, run int) (ParsedLog, error) {
+	if run == 0 {
+		return tflog, nil
+	}
+	if run < 0 || run > len(runs) {
+		return ParsedLog{}, fmt.Errorf("Unable to select run %v, log contains %v run(s).", run, len(runs))
+	}
+	return runs[run-1], nil
+}
+
+// Print a summary of all runs detected in a log. Nothing is printed
+// if the log contains only a single run.
+func PrintRunSummary(w io.Writer, runs []ParsedLog) {
+	if len(runs) < 2 {
+		return
+	}
+
+	headerFmt := color.New(color.FgHiBlue, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgBlue).SprintfFunc()
+
+	tbl := table.New("run", "phases", "resources", "tot_time", "failed")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt).WithWriter(w)
+
+	for idx, run := range runs {
+		resources, totalTime, failed := summarizeRun(run)
+		tbl.AddRow(idx+1, getPhases(run), resources, FormatDuration(int(totalTime/1000)), failed)
+	}
+
+	fmt.Fprintf(w, "\nLog contains %v runs, use --run to select one.\n\n", len(runs))
+	tbl.Print()
+}
+
+// Count resources, cumulative duration and failures in a run
+func summarizeRun(run ParsedLog) (int, float64, int) {
+	resources := 0
+	totalTime := float64(0)
+	failed := 0
+	for _, metric := range run.Resources {
+		resources += metric.NumCalls
+		if metric.TotalTime > 0 {
+			totalTime += metric.TotalTime
+		}
+		if metric.AfterStatus == Failed {
+			failed += metric.NumCalls
+		}
+	}
+	return resources, totalTime, failed
+}
+
+// Describe which phases a run contains, e.g. "refresh,plan,apply"
+func getPhases(run ParsedLog) string {
+	phases := []string{}
+	if run.ContainsRefresh {
+		phases = append(phases, "refresh")
+	}
+	if run.ContainsPlan {
+		phases = append(phases, "plan")
+	}
+	if run.ContainsApply {
+		phases = append(phases, "apply")
+	}
+	return strings.Join(phases, ",")
+}
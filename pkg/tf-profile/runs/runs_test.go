@@ -0,0 +1,52 @@
+package tfprofile
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectRun(t *testing.T) {
+	Full := ParsedLog{Resources: map[string]ResourceMetric{"a": {}, "b": {}}}
+	Runs := []ParsedLog{
+		{Resources: map[string]ResourceMetric{"a": {}}},
+		{Resources: map[string]ResourceMetric{"b": {}}},
+	}
+
+	Selected, err := SelectRun(Full, Runs, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, Full, Selected)
+
+	Selected, err = SelectRun(Full, Runs, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, Runs[1], Selected)
+
+	_, err = SelectRun(Full, Runs, 3)
+	assert.NotNil(t, err)
+}
+
+func TestPrintRunSummary(t *testing.T) {
+	Runs := []ParsedLog{
+		{ContainsRefresh: true, ContainsPlan: true, Resources: map[string]ResourceMetric{
+			"a": {NumCalls: 1, TotalTime: -1},
+		}},
+		{ContainsApply: true, Resources: map[string]ResourceMetric{
+			"a": {NumCalls: 1, TotalTime: 61000, AfterStatus: Created},
+			"b": {NumCalls: 2, TotalTime: 1000, AfterStatus: Failed},
+		}},
+	}
+
+	var out bytes.Buffer
+	PrintRunSummary(&out, Runs)
+	assert.Contains(t, out.String(), "Log contains 2 runs")
+	assert.Contains(t, out.String(), "refresh,plan")
+	assert.Contains(t, out.String(), "1m2s")
+
+	// Single runs are not summarized
+	out.Reset()
+	PrintRunSummary(&out, Runs[:1])
+	assert.Equal(t, "", out.String())
+}
@@ -0,0 +1,84 @@
+package tfprofile
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/utils"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// Select the Terragrunt unit to profile. With an empty unit, all units are
+// profiled together. Otherwise, only the resources of that unit are kept,
+// under their address within the unit.
+func SelectUnit(tflog ParsedLog, unit string) (ParsedLog, error) {
+	if unit == "" {
+		return tflog, nil
+	}
+	if !containsUnit(tflog.Units, unit) {
+		if len(tflog.Units) == 0 {
+			return ParsedLog{}, fmt.Errorf("Unable to select unit %v, log contains no Terragrunt units.", unit)
+		}
+		return ParsedLog{}, fmt.Errorf("Unable to select unit %v, log contains units: %v", unit, strings.Join(tflog.Units, ", "))
+	}
+
+	selected := tflog
+	selected.Units = nil
+	selected.Resources = map[string]ResourceMetric{}
+	for resource, metric := range tflog.Resources {
+		if metric.Unit != unit {
+			continue
+		}
+		_, address := SplitUnit(resource)
+		if metric.MovedFrom != "" {
+			_, metric.MovedFrom = SplitUnit(metric.MovedFrom)
+		}
+		selected.Resources[address] = metric
+	}
+	selected.PlannedChanges = map[string]PlannedChange{}
+	for resource, change := range tflog.PlannedChanges {
+		changeUnit, address := SplitUnit(resource)
+		if changeUnit == unit {
+			selected.PlannedChanges[address] = change
+		}
+	}
+	// Summary lines are only known for all units combined
+	selected.PlanSummary = nil
+	selected.ApplySummary = nil
+	return selected, nil
+}
+
+// Print a summary of all Terragrunt units in a log. Nothing is printed if the
+// log contains less than two units.
+func PrintUnitSummary(w io.Writer, tflog ParsedLog) {
+	if len(tflog.Units) < 2 {
+		return
+	}
+
+	headerFmt := color.New(color.FgHiBlue, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgBlue).SprintfFunc()
+
+	tbl := table.New("unit", "resources", "tot_time", "failed")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt).WithWriter(w)
+
+	for _, unit := range tflog.Units {
+		selected, _ := SelectUnit(tflog, unit)
+		resources, totalTime, failed := summarizeRun(selected)
+		tbl.AddRow(unit, resources, FormatDuration(int(totalTime/1000)), failed)
+	}
+
+	fmt.Fprintf(w, "\nLog contains %v Terragrunt units, use --unit to select one.\n\n", len(tflog.Units))
+	tbl.Print()
+}
+
+func containsUnit(units []string, unit string) bool {
+	for _, u := range units {
+		if u == unit {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package tfprofile
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectUnit(t *testing.T) {
+	Full := ParsedLog{
+		Units: []string{"network", "queue"},
+		Resources: map[string]ResourceMetric{
+			"[network] aws_vpc.this":     {NumCalls: 1, Unit: "network"},
+			"[queue] aws_sqs_queue.jobs": {NumCalls: 1, Unit: "queue"},
+		},
+		PlannedChanges: map[string]PlannedChange{
+			"[network] aws_vpc.this": {Operation: Create},
+		},
+		PlanSummary: &ChangeCounts{Add: 2},
+	}
+
+	Selected, err := SelectUnit(Full, "")
+	assert.Nil(t, err)
+	assert.Equal(t, Full, Selected)
+
+	Selected, err = SelectUnit(Full, "network")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]ResourceMetric{"aws_vpc.this": {NumCalls: 1, Unit: "network"}}, Selected.Resources)
+	assert.Equal(t, map[string]PlannedChange{"aws_vpc.this": {Operation: Create}}, Selected.PlannedChanges)
+	assert.Nil(t, Selected.Units)
+	assert.Nil(t, Selected.PlanSummary)
+
+	_, err = SelectUnit(Full, "database")
+	assert.NotNil(t, err)
+	_, err = SelectUnit(ParsedLog{}, "network")
+	assert.NotNil(t, err)
+}
+
+func TestPrintUnitSummary(t *testing.T) {
+	Full := ParsedLog{
+		Units: []string{"network", "queue"},
+		Resources: map[string]ResourceMetric{
+			"[network] aws_vpc.this":     {NumCalls: 1, TotalTime: 14000, Unit: "network"},
+			"[queue] aws_sqs_queue.jobs": {NumCalls: 1, TotalTime: 12000, AfterStatus: Failed, Unit: "queue"},
+		},
+	}
+
+	var out bytes.Buffer
+	PrintUnitSummary(&out, Full)
+	assert.Contains(t, out.String(), "Log contains 2 Terragrunt units")
+	assert.Contains(t, out.String(), "network")
+	assert.Contains(t, out.String(), "14s")
+
+	out.Reset()
+	PrintUnitSummary(&out, ParsedLog{Units: []string{"network"}})
+	assert.Equal(t, "", out.String())
+}
@@ -2,17 +2,21 @@ package tfprofile
 
 import (
 	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
 )
 
 // Extract the top level module.
-// For example, "module.mymod.aws_subnet.test" will return "module.mymod"
+// For example, "module.mymod.aws_subnet.test" will return "module.mymod".
+// Modules of Terragrunt units keep their unit: "[unit] module.mymod".
 func getTopLevelModule(name string) string {
+	unit, name := SplitUnit(name)
 	split := strings.Split(name, ".")
 	if len(split) < 2 {
 		return ""
 	}
 	if split[0] == "module" {
-		return split[0] + "." + split[1]
+		return UnitAddress(unit, split[0]+"."+split[1])
 	}
 	return ""
 }
@@ -22,6 +26,7 @@ func getTopLevelModule(name string) string {
 // E.g. module.mod1.aws_subnet.test => 1.
 // E.g. module.mod1.module.mod2.aws_subnet.test => 2.
 func getModuleDepth(name string) int {
+	_, name = SplitUnit(name)
 	tokens := len(strings.Split(name, "."))
 	return (tokens - 2) / 2
 }
@@ -29,13 +34,15 @@ func getModuleDepth(name string) int {
 // Given a full resource name, return the name of the deepest module it belongs to
 // (including parent modules)
 func getModule(name string) string {
+	unit, name := SplitUnit(name)
 	split := strings.Split(name, ".")
-	return strings.Join(split[:len(split)-2], ".")
+	return UnitAddress(unit, strings.Join(split[:len(split)-2], "."))
 }
 
 // Given a full resource name, return only the name of the deepest module
 // without parent modules
 func getLeafModuleName(name string) string {
+	_, name = SplitUnit(name)
 	split := strings.Split(name, ".")
 	leaf := ""
 
@@ -46,3 +53,24 @@ func getLeafModuleName(name string) string {
 	}
 	return leaf
 }
+
+// Return the modules a resource belongs to, from the top-level module down to
+// the deepest one. Terragrunt units come first. For example,
+// "[unit] module.a.module.b.aws_subnet.test" => ["[unit]", "module.a", "module.b"].
+func getModulePath(name string) []string {
+	unit, name := SplitUnit(name)
+	path := []string{}
+	if unit != "" {
+		path = append(path, "["+unit+"]")
+	}
+	modules, _ := SplitModules(name)
+	return append(path, modules...)
+}
+
+// Given a full resource name, return the name of the resource within its
+// deepest module, e.g. "module.a.data.aws_region.current" => "data.aws_region.current"
+func getResourceName(name string) string {
+	_, name = SplitUnit(name)
+	_, resource := SplitModules(name)
+	return resource
+}
@@ -3,12 +3,16 @@ package tfprofile
 import (
 	"bufio"
 	"fmt"
+	"os"
 	"sort"
 
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/aggregate"
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/labels"
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/parser"
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/readers"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/runs"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/tfjson"
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/utils"
 	"github.com/fatih/color"
 	"github.com/rodaine/table"
@@ -19,7 +23,45 @@ type Stat struct {
 	value string
 }
 
-func Stats(args []string, tee bool, aggregate bool) error {
+// Create a statistic with a name and a value, e.g. NewStat("Owner", "team-a")
+func NewStat(name string, value string) Stat {
+	return Stat{name, value}
+}
+
+// A section of the output of `tf-profile stats`: a group of related statistics
+// computed from a parsed log
+type Section = func(log ParsedLog) []Stat
+
+// A section and whether it is left out when it is empty. The original sections
+// are always printed, with a blank row after them.
+type statsSection struct {
+	stats    Section
+	optional bool
+}
+
+// Sections printed by `tf-profile stats`, in order. More can be added with
+// RegisterSection.
+var sections = []statsSection{
+	{getBasicStats, false},
+	{getTimeStats, false},
+	{getOperationStats, false},
+	{getImportMoveStats, true},
+	{getAfterStatusStats, false},
+	{getDesiredStateStats, false},
+	{getDriftStats, true},
+	{getModuleStats, false},
+	{getUnitStats, true},
+	{getProviderStats, true},
+	{getSummaryStats, true},
+}
+
+// Add a section to the output of `tf-profile stats`, after all existing
+// sections. It is not printed when it is empty.
+func RegisterSection(section Section) {
+	sections = append(sections, statsSection{section, true})
+}
+
+func Stats(args []string, tee bool, aggregate bool, aggregate_levels string, run int, unit string, plan string, state string, group_by string, label []string, strict bool) error {
 	var file *bufio.Scanner
 	var err error
 
@@ -33,13 +75,41 @@ func Stats(args []string, tee bool, aggregate bool) error {
 		return err
 	}
 
-	tflog, err := Parse(file, tee)
+	tflog, runs, err := ParseRuns(file, tee, strict)
 	if err != nil {
 		return err
 	}
 
+	PrintRunSummary(os.Stdout, runs)
+	tflog, err = SelectRun(tflog, runs, run)
+	if err != nil {
+		return err
+	}
+	PrintUnitSummary(os.Stdout, tflog)
+	tflog, err = SelectUnit(tflog, unit)
+	if err != nil {
+		return err
+	}
+	tflog, err = AddPlanAndState(tflog, plan, state)
+	if err != nil {
+		return err
+	}
+	tflog, err = FilterLabels(tflog, label)
+	if err != nil {
+		return err
+	}
+
+	// Groups are built from individual resources, before aggregating
+	groups := []Group{}
+	if group_by != "" {
+		groups, err = GroupBy(tflog, group_by)
+		if err != nil {
+			return err
+		}
+	}
+
 	if aggregate {
-		tflog, err = Aggregate(tflog)
+		tflog, err = AggregateLevels(tflog, aggregate_levels)
 		if err != nil {
 			return err
 		}
@@ -49,6 +119,9 @@ func Stats(args []string, tee bool, aggregate bool) error {
 	if err != nil {
 		return err
 	}
+	if group_by != "" {
+		PrintGroupStats(groups, group_by)
+	}
 
 	return nil
 }
@@ -61,19 +134,55 @@ func PrintStats(log ParsedLog) error {
 	tbl := table.New("Key", "Value")
 	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 
-	addRows(&tbl, getBasicStats(log))
-	addRows(&tbl, getTimeStats(log))
-	addRows(&tbl, getOperationStats(log))
-	addRows(&tbl, getAfterStatusStats(log))
-	addRows(&tbl, getDesiredStateStats(log))
-	addRows(&tbl, getModuleStats(log))
+	for _, section := range sections {
+		rows := section.stats(log)
+		if len(rows) == 0 && section.optional {
+			continue
+		}
+		addRows(&tbl, rows)
+	}
 
 	fmt.Println() // Create space above the table
 	tbl.Print()
 
+	// Warn when Terraform's own summary disagrees with what was parsed
+	warningFmt := color.New(color.FgYellow).SprintfFunc()
+	for _, warning := range log.SummaryMismatches() {
+		fmt.Println(warningFmt("Warning: %v", warning))
+	}
+
 	return nil
 }
 
+// Print one row per group of resources (see GroupBy), e.g.
+// "aws_iam_role    12 resources, 1m3s cumulative, max 20s, mean 5s, p95 18s, 0 failed (Create:10 Modify:2)"
+func PrintGroupStats(groups []Group, group_by string) {
+	headerFmt := color.New(color.FgHiBlue, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgBlue).SprintfFunc()
+
+	tbl := table.New(group_by, "Value")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	for _, group := range groups {
+		tbl.AddRow(group.Name, formatGroup(group))
+	}
+
+	fmt.Println()
+	tbl.Print()
+}
+
+func formatGroup(group Group) string {
+	return fmt.Sprintf(
+		"%v resources, %v cumulative, max %v, mean %v, p95 %v, %v failed (%v)",
+		group.Metric.NumCalls,
+		FormatDuration(int(group.Metric.TotalTime/1000)),
+		FormatDuration(int(group.MaxTime/1000)),
+		FormatDuration(int(group.MeanTime/1000)),
+		FormatDuration(int(group.P95Time/1000)),
+		group.Failures,
+		group.OperationMix(),
+	)
+}
+
 // Helper to add multiple rows at once
 func addRows(tbl *table.Table, rows []Stat) {
 	for _, stat := range rows {
@@ -87,9 +196,29 @@ func getBasicStats(log ParsedLog) []Stat {
 	for _, resource := range log.Resources {
 		NumCalls += resource.NumCalls
 	}
-	return []Stat{
+	result := []Stat{
 		{"Number of resources in configuration", fmt.Sprint(NumCalls)},
 	}
+	if tool := log.ToolDescription(); tool != "" {
+		result = append(result, Stat{"Tool", tool})
+	}
+
+	// Only known from a JSON plan or state
+	Providers := map[string]int{}
+	for _, resource := range log.Resources {
+		if resource.Provider != "" {
+			Providers[resource.Provider] += resource.NumCalls
+		}
+	}
+	Names := []string{}
+	for provider := range Providers {
+		Names = append(Names, provider)
+	}
+	sort.Strings(Names)
+	for _, provider := range Names {
+		result = append(result, Stat{fmt.Sprintf("Resources of provider %v", provider), fmt.Sprint(Providers[provider])})
+	}
+	return result
 }
 
 func getTimeStats(log ParsedLog) []Stat {
@@ -104,20 +233,68 @@ func getTimeStats(log ParsedLog) []Stat {
 			HighestResource = name
 		}
 	}
-	return []Stat{
+	result := []Stat{
 		{"Cumulative duration", FormatDuration(TotalTime)},
 		{"Longest apply time", FormatDuration(HighestTime / 1000)},
 		{"Longest apply resource", HighestResource},
 	}
+	return append(result, getDistributionStats(log)...)
+}
+
+// Durations of individual instances of aggregated resources. The longest apply
+// time above is the sum over all instances of a resource, so one instance that
+// took 10 minutes looks the same as 600 instances that took 1 second.
+func getDistributionStats(log ParsedLog) []Stat {
+	SlowestInstance, SlowestTime := "", float64(-1)
+	Uneven, UnevenDist := "", Distribution{}
+	for _, name := range sortedResources(log) {
+		metric := log.Resources[name]
+		Dist := metric.Distribution
+		if Dist.Count == 0 {
+			// Not aggregated: the resource is an instance of its own
+			Dist = Distribution{Max: metric.TotalTime, Slowest: name}
+		} else if Dist.StdDev > UnevenDist.StdDev {
+			Uneven, UnevenDist = name, Dist
+		}
+		if Dist.Max > SlowestTime {
+			SlowestInstance, SlowestTime = Dist.Slowest, Dist.Max
+		}
+	}
+	if Uneven == "" {
+		return []Stat{}
+	}
+
+	return []Stat{
+		{"Longest instance time", FormatDuration(int(SlowestTime / 1000))},
+		{"Longest instance", SlowestInstance},
+		{"Most uneven aggregated resource", Uneven},
+		{"Instance durations of most uneven", fmt.Sprintf(
+			"min %v, median %v, mean %v, p90 %v, p99 %v, max %v, stddev %v",
+			FormatDuration(int(UnevenDist.Min/1000)),
+			FormatDuration(int(UnevenDist.Median/1000)),
+			FormatDuration(int(UnevenDist.Mean/1000)),
+			FormatDuration(int(UnevenDist.P90/1000)),
+			FormatDuration(int(UnevenDist.P99/1000)),
+			FormatDuration(int(UnevenDist.Max/1000)),
+			FormatDuration(int(UnevenDist.StdDev/1000)),
+		)},
+	}
 }
 
 func getAfterStatusStats(log ParsedLog) []Stat {
 	StatusCount := make(map[string]int)
+	BeforeCount := 0
 	for _, metrics := range log.Resources {
 		StatusCount[metrics.AfterStatus.String()] += metrics.NumCalls
+		if metrics.BeforeStatus == Created {
+			BeforeCount += metrics.NumCalls
+		}
 	}
 
 	result := []Stat{}
+	if log.ContainsState {
+		result = append(result, Stat{"Resources existing before the run", fmt.Sprint(BeforeCount)})
+	}
 	for status, count := range StatusCount {
 		StatName := fmt.Sprintf("Resources in state %v", status)
 		result = append(result, Stat{StatName, fmt.Sprint(count)})
@@ -167,6 +344,194 @@ func getOperationStats(log ParsedLog) []Stat {
 	return result
 }
 
+func getDriftStats(log ParsedLog) []Stat {
+	Refreshed := 0
+	Changed := 0
+	Deleted := 0
+	Drifted := []string{}
+
+	for name, metric := range log.Resources {
+		if metric.RefreshIndex != -1 {
+			Refreshed += metric.NumCalls
+		}
+		switch metric.Drift {
+		case DriftChanged:
+			Changed += metric.NumCalls
+		case DriftDeleted:
+			Deleted += metric.NumCalls
+		}
+		if metric.Drift != NoDrift {
+			Drifted = append(Drifted, name)
+		}
+	}
+
+	result := []Stat{
+		{"Resources refreshed", fmt.Sprint(Refreshed)},
+		{"Resources changed outside of Terraform", fmt.Sprint(Changed)},
+		{"Resources deleted outside of Terraform", fmt.Sprint(Deleted)},
+	}
+
+	// List drifted resources, sorted on name to make it consistent
+	sort.Strings(Drifted)
+	for _, name := range Drifted {
+		value := fmt.Sprintf("%v (%v)", name, log.Resources[name].Drift)
+		result = append(result, Stat{"Drifted resource", value})
+	}
+	return result
+}
+
+// List resources that were imported, moved or forgotten (removed from
+// the state without being destroyed).
+func getImportMoveStats(log ParsedLog) []Stat {
+	Names := []string{}
+	for name := range log.Resources {
+		Names = append(Names, name)
+	}
+	sort.Strings(Names)
+
+	Imported := []Stat{}
+	Moved := []Stat{}
+	Forgotten := []Stat{}
+	for _, name := range Names {
+		metric := log.Resources[name]
+		if metric.Operation == Import {
+			value := name
+			if metric.TotalTime >= 0 {
+				value = fmt.Sprintf("%v (%v)", name, FormatDuration(int(metric.TotalTime/1000)))
+			}
+			Imported = append(Imported, Stat{"Imported resource", value})
+		}
+		if metric.MovedFrom != "" {
+			value := fmt.Sprintf("%v -> %v", metric.MovedFrom, name)
+			Moved = append(Moved, Stat{"Moved resource", value})
+		}
+		if metric.Operation == Forget {
+			Forgotten = append(Forgotten, Stat{"Forgotten resource", name})
+		}
+	}
+
+	result := append(Imported, Moved...)
+	return append(result, Forgotten...)
+}
+
+// Show the summary lines printed by Terraform, if present in the log
+func getSummaryStats(log ParsedLog) []Stat {
+	Tool := Terraform
+	if log.Tool != UnknownTool {
+		Tool = log.Tool
+	}
+
+	result := []Stat{}
+	if log.PlanSummary != nil {
+		result = append(result, Stat{fmt.Sprintf("%v plan summary", Tool), log.PlanSummary.Format(PlanLabels)})
+	}
+	if log.ApplySummary != nil {
+		result = append(result, Stat{fmt.Sprintf("%v apply summary", Tool), log.ApplySummary.Format(ApplyLabels)})
+	}
+	if len(result) > 0 {
+		check := "Consistent with parsed log"
+		if n := len(log.SummaryMismatches()); n > 0 {
+			check = fmt.Sprintf("%v mismatch(es), see warnings below", n)
+		}
+		result = append(result, Stat{"Summary check", check})
+	}
+	return result
+}
+
+// Statistics about Terragrunt units, only for logs of a `run-all`
+func getUnitStats(log ParsedLog) []Stat {
+	if len(log.Units) == 0 {
+		return []Stat{}
+	}
+
+	Sizes := make(map[string]int)
+	Durations := make(map[string]float64)
+	for _, metric := range log.Resources {
+		Sizes[metric.Unit] += metric.NumCalls
+		if metric.TotalTime > 0 {
+			Durations[metric.Unit] += metric.TotalTime
+		}
+	}
+
+	LargestUnit, SlowestUnit := "/", "/"
+	for _, unit := range log.Units {
+		if Sizes[unit] > Sizes[LargestUnit] {
+			LargestUnit = unit
+		}
+		if Durations[unit] > Durations[SlowestUnit] {
+			SlowestUnit = unit
+		}
+	}
+
+	return []Stat{
+		{"Number of Terragrunt units", fmt.Sprint(len(log.Units))},
+		{"Largest unit", LargestUnit},
+		{"Size of largest unit", fmt.Sprint(Sizes[LargestUnit])},
+		{"Slowest unit", SlowestUnit},
+		{"Cumulative duration of slowest unit", FormatDuration(int(Durations[SlowestUnit] / 1000))},
+	}
+}
+
+// Provider API calls, only for logs with TF_LOG=trace output
+func getProviderStats(log ParsedLog) []Stat {
+	if !log.ContainsTrace {
+		return []Stat{}
+	}
+
+	TotalCalls, TotalTime := 0, float64(0)
+	RPCs := []string{}
+	for rpc, calls := range log.ProviderRPCs {
+		TotalCalls += calls.Calls
+		TotalTime += calls.TotalTime
+		RPCs = append(RPCs, rpc)
+	}
+	sort.Strings(RPCs)
+
+	Requests, Retries, Throttled, ServerErrors := 0, 0, 0, 0
+	SlowestResource, MostThrottledResource := "/", "/"
+	for _, resource := range sortedResources(log) {
+		metric := log.Resources[resource]
+		Requests += metric.HTTPRequests
+		Retries += metric.HTTPRetries
+		Throttled += metric.HTTPThrottled
+		ServerErrors += metric.HTTPServerErrors
+		if metric.ProviderTime > log.Resources[SlowestResource].ProviderTime {
+			SlowestResource = resource
+		}
+		if metric.HTTPThrottled > log.Resources[MostThrottledResource].HTTPThrottled {
+			MostThrottledResource = resource
+		}
+	}
+
+	result := []Stat{
+		{"Provider API calls", fmt.Sprint(TotalCalls)},
+		{"Provider API time", fmt.Sprintf("%vms", int(TotalTime))},
+	}
+	for _, rpc := range RPCs {
+		calls := log.ProviderRPCs[rpc]
+		result = append(result, Stat{fmt.Sprintf("Calls of %v", rpc), fmt.Sprintf("%v (%vms)", calls.Calls, int(calls.TotalTime))})
+	}
+	return append(result,
+		Stat{"Slowest resource in provider", SlowestResource},
+		Stat{"Provider time of slowest resource", fmt.Sprintf("%vms", int(log.Resources[SlowestResource].ProviderTime))},
+		Stat{"HTTP requests", fmt.Sprint(Requests)},
+		Stat{"HTTP retries", fmt.Sprint(Retries)},
+		Stat{"Throttled responses (429)", fmt.Sprint(Throttled)},
+		Stat{"Server errors (5xx)", fmt.Sprint(ServerErrors)},
+		Stat{"Most throttled resource", MostThrottledResource},
+	)
+}
+
+// Names of all resources in a log, sorted, so that ties are broken by name
+func sortedResources(log ParsedLog) []string {
+	result := []string{}
+	for resource := range log.Resources {
+		result = append(result, resource)
+	}
+	sort.Strings(result)
+	return result
+}
+
 func getModuleStats(log ParsedLog) []Stat {
 	LargestTopLevelModule := "/"
 	LargestTopLevelModuleSize := 0
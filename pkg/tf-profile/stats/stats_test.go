@@ -3,7 +3,10 @@ package tfprofile
 import (
 	"testing"
 
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/aggregate"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/config"
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/labels"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,6 +25,41 @@ func TestBasicStats(t *testing.T) {
 	assert.Equal(t, "4", Out[0].value)
 }
 
+func TestToolStats(t *testing.T) {
+	In := ParsedLog{Tool: OpenTofu, ToolVersion: "1.6.2", PlanSummary: &ChangeCounts{Add: 1}}
+	assert.Equal(t, Stat{"Tool", "OpenTofu v1.6.2"}, getBasicStats(In)[1])
+	assert.Equal(t, Stat{"OpenTofu plan summary", "1 to add, 0 to change, 0 to destroy"}, getSummaryStats(In)[0])
+}
+
+func TestProviderStats(t *testing.T) {
+	assert.Equal(t, []Stat{}, getProviderStats(ParsedLog{}))
+
+	In := ParsedLog{
+		ContainsTrace: true,
+		ProviderRPCs: map[string]ProviderRPC{
+			"ReadResource":        {Calls: 2, TotalTime: 300},
+			"ApplyResourceChange": {Calls: 1, TotalTime: 1500},
+		},
+		Resources: map[string]ResourceMetric{
+			"a": {NumCalls: 1, ProviderCalls: 2, ProviderTime: 1700, HTTPRequests: 3, HTTPRetries: 1, HTTPThrottled: 1},
+			"b": {NumCalls: 1, ProviderCalls: 1, ProviderTime: 100, HTTPRequests: 2, HTTPRetries: 1, HTTPServerErrors: 1},
+		},
+	}
+	assert.Equal(t, []Stat{
+		{"Provider API calls", "3"},
+		{"Provider API time", "1800ms"},
+		{"Calls of ApplyResourceChange", "1 (1500ms)"},
+		{"Calls of ReadResource", "2 (300ms)"},
+		{"Slowest resource in provider", "a"},
+		{"Provider time of slowest resource", "1700ms"},
+		{"HTTP requests", "5"},
+		{"HTTP retries", "2"},
+		{"Throttled responses (429)", "1"},
+		{"Server errors (5xx)", "1"},
+		{"Most throttled resource", "a"},
+	}, getProviderStats(In))
+}
+
 func TestTimeStats(t *testing.T) {
 	In := ParsedLog{
 		Resources: map[string]ResourceMetric{
@@ -87,14 +125,153 @@ func TestModuleStats(t *testing.T) {
 	assert.Equal(t, Expected, Out)
 }
 
+func TestUnitStats(t *testing.T) {
+	In := ParsedLog{
+		Units: []string{"network", "queue"},
+		Resources: map[string]ResourceMetric{
+			"[network] aws_vpc.this":                   {NumCalls: 1, TotalTime: 14000, Unit: "network"},
+			"[network] module.subnets.aws_subnet.a[*]": {NumCalls: 2, TotalTime: 2000, Unit: "network"},
+			"[queue] aws_sqs_queue.jobs":               {NumCalls: 1, TotalTime: 30000, Unit: "queue"},
+		},
+	}
+	Expected := []Stat{
+		{"Number of Terragrunt units", "2"},
+		{"Largest unit", "network"},
+		{"Size of largest unit", "3"},
+		{"Slowest unit", "queue"},
+		{"Cumulative duration of slowest unit", "30s"},
+	}
+	assert.Equal(t, Expected, getUnitStats(In))
+	assert.Equal(t, []Stat{}, getUnitStats(ParsedLog{}))
+
+	// Modules are reported per unit
+	assert.Equal(t, "[network] module.subnets", getTopLevelModule("[network] module.subnets.aws_subnet.a[*]"))
+	assert.Equal(t, 1, getModuleDepth("[network] module.subnets.aws_subnet.a[*]"))
+	assert.Equal(t, "subnets", getLeafModuleName("[network] module.subnets.aws_subnet.a[*]"))
+}
+
 func TestFullStats(t *testing.T) {
-	err := Stats([]string{"../../../test/aggregate.log"}, false, true)
+	err := Stats([]string{"../../../test/aggregate.log"}, false, true, "resource", 0, "", "", "", "", nil, false)
+	assert.Nil(t, err)
+	err = Stats([]string{"../../../test/multiple_resources.log"}, false, true, "resource", 0, "", "", "", "", nil, false)
+	assert.Nil(t, err)
+	err = Stats([]string{"../../../test/null_resources.log"}, false, true, "resource", 0, "", "", "", "", nil, false)
 	assert.Nil(t, err)
-	err = Stats([]string{"../../../test/multiple_resources.log"}, false, true)
+	err = Stats([]string{"../../../test/many_modules.log"}, false, true, "all", 0, "", "", "", "", nil, false)
 	assert.Nil(t, err)
-	err = Stats([]string{"../../../test/null_resources.log"}, false, true)
+	err = Stats([]string{"../../../test/many_modules.log"}, false, true, "type", 0, "", "", "", "", nil, false)
+	assert.NotNil(t, err)
+	t.Cleanup(ResetLabelRules)
+	err = AddLabelRules([]LabelRule{{Match: "module.core*", Labels: map[string]string{"team": "core"}}})
+	assert.Nil(t, err)
+	err = Stats([]string{"../../../test/many_modules.log"}, false, true, "all", 0, "", "", "", "label:team", []string{"team"}, false)
+	assert.Nil(t, err)
+	err = Stats([]string{"../../../test/many_modules.log"}, false, true, "all", 0, "", "", "", "", []string{"team=data"}, false)
+	assert.NotNil(t, err)
+	err = Stats([]string{"../../../test/many_modules.log"}, false, true, "all", 0, "", "", "", "", []string{"=team"}, false)
+	assert.NotNil(t, err)
+
+	err = Stats([]string{"../../../test/terragrunt.log"}, false, true, "resource", 0, "queue", "", "", "", nil, false)
 	assert.Nil(t, err)
 
-	err = Stats([]string{"does-not-exist"}, false, true)
+	err = Stats([]string{"does-not-exist"}, false, true, "resource", 0, "", "", "", "", nil, false)
 	assert.NotNil(t, err)
 }
+
+func TestDriftStats(t *testing.T) {
+	In := ParsedLog{
+		Resources: map[string]ResourceMetric{
+			"a": {NumCalls: 1, RefreshIndex: 0, Drift: NoDrift},
+			"b": {NumCalls: 1, RefreshIndex: 1, Drift: DriftChanged},
+			"c": {NumCalls: 2, RefreshIndex: 2, Drift: DriftDeleted},
+			"d": {NumCalls: 1, RefreshIndex: -1, Drift: NoDrift},
+		},
+	}
+	Out := getDriftStats(In)
+	Expected := []Stat{
+		{"Resources refreshed", "4"},
+		{"Resources changed outside of Terraform", "1"},
+		{"Resources deleted outside of Terraform", "2"},
+		{"Drifted resource", "b (Changed)"},
+		{"Drifted resource", "c (Deleted)"},
+	}
+	assert.Equal(t, Expected, Out)
+}
+
+func TestImportMoveStats(t *testing.T) {
+	In := ParsedLog{
+		Resources: map[string]ResourceMetric{
+			"a": {NumCalls: 1, TotalTime: 2000, Operation: Import},
+			"b": {NumCalls: 1, Operation: Move, MovedFrom: "old_b"},
+			"c": {NumCalls: 1, Operation: Modify, MovedFrom: "old_c"},
+			"d": {NumCalls: 1, Operation: Forget},
+			"e": {NumCalls: 1, Operation: Create},
+		},
+	}
+	Out := getImportMoveStats(In)
+	Expected := []Stat{
+		{"Imported resource", "a (2s)"},
+		{"Moved resource", "old_b -> b"},
+		{"Moved resource", "old_c -> c"},
+		{"Forgotten resource", "d"},
+	}
+	assert.Equal(t, Expected, Out)
+}
+
+func TestSummaryStats(t *testing.T) {
+	In := ParsedLog{
+		Resources:    map[string]ResourceMetric{},
+		PlanSummary:  &ChangeCounts{Add: 1},
+		ApplySummary: &ChangeCounts{Add: 1},
+		ApplyCounts:  ChangeCounts{Add: 1},
+	}
+	Expected := []Stat{
+		{"Terraform plan summary", "1 to add, 0 to change, 0 to destroy"},
+		{"Terraform apply summary", "1 added, 0 changed, 0 destroyed"},
+		{"Summary check", "1 mismatch(es), see warnings below"},
+	}
+	assert.Equal(t, Expected, getSummaryStats(In))
+
+	assert.Equal(t, []Stat{}, getSummaryStats(ParsedLog{}))
+}
+
+func TestRegisterSection(t *testing.T) {
+	defer func(saved []statsSection) { sections = saved }(sections)
+
+	RegisterSection(func(log ParsedLog) []Stat {
+		return []Stat{NewStat("Owner", "team-a")}
+	})
+	assert.Equal(t, 12, len(sections))
+	assert.Equal(t, []Stat{{"Owner", "team-a"}}, sections[11].stats(ParsedLog{}))
+	assert.True(t, sections[11].optional)
+	assert.Nil(t, PrintStats(ParsedLog{Resources: map[string]ResourceMetric{}}))
+}
+
+func TestGroupStats(t *testing.T) {
+	group := Group{Name: "helm_release", Metric: ResourceMetric{NumCalls: 3, TotalTime: 92000}, MaxTime: 82000, MeanTime: 30000, P95Time: 82000, Operations: map[Operation]int{Create: 3}}
+	assert.Equal(t, "3 resources, 1m32s cumulative, max 1m22s, mean 30s, p95 1m22s, 0 failed (Create:3)", formatGroup(group))
+
+	err := Stats([]string{"../../../test/argo.log"}, false, true, "resource", 0, "", "", "", "provider", nil, false)
+	assert.Nil(t, err)
+}
+
+func TestDistributionStats(t *testing.T) {
+	In := ParsedLog{
+		Resources: map[string]ResourceMetric{
+			"a[*]": {NumCalls: 3, TotalTime: 12000, Distribution: Distribution{Count: 3, Min: 1000, Max: 10000, Mean: 4000, Median: 1000, P90: 10000, P99: 10000, StdDev: 4243, Slowest: "a[2]"}},
+			"b[*]": {NumCalls: 2, TotalTime: 4000, Distribution: Distribution{Count: 2, Min: 2000, Max: 2000, Mean: 2000, Median: 2000, P90: 2000, P99: 2000, Slowest: "b[0]"}},
+			"c":    {NumCalls: 1, TotalTime: 11000},
+		},
+	}
+	assert.Equal(t, []Stat{
+		{"Longest instance time", "11s"},
+		{"Longest instance", "c"},
+		{"Most uneven aggregated resource", "a[*]"},
+		{"Instance durations of most uneven", "min 1s, median 1s, mean 4s, p90 10s, p99 10s, max 10s, stddev 4s"},
+	}, getDistributionStats(In))
+
+	// Nothing to report without aggregated resources
+	delete(In.Resources, "a[*]")
+	delete(In.Resources, "b[*]")
+	assert.Equal(t, []Stat{}, getDistributionStats(In))
+}
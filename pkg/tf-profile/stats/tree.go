@@ -0,0 +1,228 @@
+package tfprofile
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/aggregate"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/labels"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/parser"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/readers"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/runs"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/utils"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+)
+
+// A module in the output of `tf-profile tree`. The statistics include all
+// resources of the module and its submodules.
+type ModuleNode struct {
+	Name       string
+	NumCalls   int
+	TotalTime  float64
+	Failures   int
+	Operations map[Operation]int
+	Children   map[string]*ModuleNode
+	// Resources in the module itself, not in a submodule
+	Resources []string
+}
+
+// Thresholds that decide which parts of the tree are printed, see PrintTree
+type TreeOptions struct {
+	// Deepest level of modules to print, 0 for all levels
+	Depth int
+	// Modules that took less time (in ms) are not printed
+	MinTime float64
+	// Modules that took less than this percentage of the total time are
+	// printed without their submodules
+	Collapse float64
+	// The resources of modules with at most this many resources are printed
+	Expand int
+}
+
+// Execute the `tf-profile tree` command
+func Tree(args []string, tee bool, aggregate bool, aggregate_levels string, run int, unit string, label []string, options TreeOptions, strict bool) error {
+	var file *bufio.Scanner
+	var err error
+
+	if len(args) == 1 {
+		file, err = FileReader{File: args[0]}.Read()
+	} else {
+		file, err = StdinReader{}.Read()
+	}
+	if err != nil {
+		return err
+	}
+
+	tflog, runs, err := ParseRuns(file, tee, strict)
+	if err != nil {
+		return err
+	}
+
+	PrintRunSummary(os.Stdout, runs)
+	tflog, err = SelectRun(tflog, runs, run)
+	if err != nil {
+		return err
+	}
+	PrintUnitSummary(os.Stdout, tflog)
+	tflog, err = SelectUnit(tflog, unit)
+	if err != nil {
+		return err
+	}
+	tflog, err = FilterLabels(tflog, label)
+	if err != nil {
+		return err
+	}
+
+	if aggregate {
+		tflog, err = AggregateLevels(tflog, aggregate_levels)
+		if err != nil {
+			return err
+		}
+	}
+
+	PrintTree(tflog, BuildModuleTree(tflog), options)
+	return nil
+}
+
+func newModuleNode(name string) *ModuleNode {
+	return &ModuleNode{Name: name, Operations: map[Operation]int{}, Children: map[string]*ModuleNode{}}
+}
+
+// Build the module hierarchy of a log. The root node holds the resources of
+// the root module and the statistics of the whole run. Terragrunt units
+// form the first level of the tree.
+func BuildModuleTree(log ParsedLog) *ModuleNode {
+	root := newModuleNode(RootModule)
+	for name, metric := range log.Resources {
+		node := root
+		node.add(metric)
+		for _, module := range getModulePath(name) {
+			if _, ok := node.Children[module]; !ok {
+				node.Children[module] = newModuleNode(module)
+			}
+			node = node.Children[module]
+			node.add(metric)
+		}
+		node.Resources = append(node.Resources, name)
+	}
+	return root
+}
+
+func (n *ModuleNode) add(metric ResourceMetric) {
+	n.NumCalls += metric.NumCalls
+	if metric.TotalTime > 0 {
+		n.TotalTime += metric.TotalTime
+	}
+	if metric.AfterStatus == Failed {
+		n.Failures += metric.NumCalls
+	}
+	n.Operations[metric.Operation] += metric.NumCalls
+}
+
+// Submodules, sorted by cumulative duration (descending), then by name
+func (n *ModuleNode) SortedChildren() []*ModuleNode {
+	Children := []*ModuleNode{}
+	for _, child := range n.Children {
+		Children = append(Children, child)
+	}
+	sort.Slice(Children, func(i int, j int) bool {
+		if Children[i].TotalTime != Children[j].TotalTime {
+			return Children[i].TotalTime > Children[j].TotalTime
+		}
+		return Children[i].Name < Children[j].Name
+	})
+	return Children
+}
+
+// Print the module hierarchy as an indented tree, with one row per module
+func PrintTree(log ParsedLog, root *ModuleNode, options TreeOptions) {
+	headerFmt := color.New(color.FgHiBlue, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgBlue).SprintfFunc()
+
+	tbl := table.New("module", "n", "tot_time", "failed", "operations")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	for _, row := range treeRows(log, root, 0, root.TotalTime, options) {
+		tbl.AddRow(row...)
+	}
+
+	fmt.Println() // Create space above the table
+	tbl.Print()
+}
+
+// Rows for a module and everything below it that passes the thresholds
+func treeRows(log ParsedLog, node *ModuleNode, level int, total float64, options TreeOptions) [][]interface{} {
+	indent := strings.Repeat("  ", level)
+	name := indent + node.Name
+
+	expand := options.Depth == 0 || level < options.Depth
+	if level > 0 && node.TotalTime < total*options.Collapse/100 {
+		expand = false
+	}
+	if !expand && len(node.Children) > 0 {
+		name = fmt.Sprintf("%v (+%v modules)", name, countModules(node))
+	}
+
+	rows := [][]interface{}{{
+		name,
+		node.NumCalls,
+		FormatDuration(int(node.TotalTime / 1000)),
+		node.Failures,
+		Group{Operations: node.Operations}.OperationMix(),
+	}}
+	if !expand {
+		return rows
+	}
+
+	if len(node.Resources) > 0 && len(node.Resources) <= options.Expand {
+		rows = append(rows, resourceRows(log, node, indent+"  ")...)
+	}
+	for _, child := range node.SortedChildren() {
+		if child.TotalTime < options.MinTime {
+			continue
+		}
+		rows = append(rows, treeRows(log, child, level+1, total, options)...)
+	}
+	return rows
+}
+
+// Rows for the resources of a module, slowest first
+func resourceRows(log ParsedLog, node *ModuleNode, indent string) [][]interface{} {
+	Resources := append([]string{}, node.Resources...)
+	sort.Slice(Resources, func(i int, j int) bool {
+		if log.Resources[Resources[i]].TotalTime != log.Resources[Resources[j]].TotalTime {
+			return log.Resources[Resources[i]].TotalTime > log.Resources[Resources[j]].TotalTime
+		}
+		return Resources[i] < Resources[j]
+	})
+
+	rows := [][]interface{}{}
+	for _, resource := range Resources {
+		metric := log.Resources[resource]
+		Failures := 0
+		if metric.AfterStatus == Failed {
+			Failures = metric.NumCalls
+		}
+		rows = append(rows, []interface{}{
+			indent + getResourceName(resource),
+			metric.NumCalls,
+			FormatDuration(int(metric.TotalTime / 1000)),
+			Failures,
+			fmt.Sprintf("%v:%v", metric.Operation, metric.NumCalls),
+		})
+	}
+	return rows
+}
+
+// Number of modules below a module, at any depth
+func countModules(node *ModuleNode) int {
+	count := len(node.Children)
+	for _, child := range node.Children {
+		count += countModules(child)
+	}
+	return count
+}
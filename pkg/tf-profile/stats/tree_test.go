@@ -0,0 +1,82 @@
+package tfprofile
+
+import (
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModulePath(t *testing.T) {
+	assert.Equal(t, []string{}, getModulePath("aws_subnet.test"))
+	assert.Equal(t, []string{"module.a", `module.b["x.y"]`}, getModulePath(`module.a.module.b["x.y"].aws_subnet.test[0]`))
+	assert.Equal(t, []string{"module.a"}, getModulePath("module.a.data.aws_region.current"))
+	assert.Equal(t, []string{"[network]", "module.vpc"}, getModulePath("[network] module.vpc.aws_vpc.this"))
+
+	assert.Equal(t, "aws_subnet.test[0]", getResourceName(`module.a.module.b["x.y"].aws_subnet.test[0]`))
+	assert.Equal(t, "data.aws_region.current", getResourceName("[network] module.a.data.aws_region.current"))
+}
+
+func treeLog() ParsedLog {
+	return ParsedLog{Resources: map[string]ResourceMetric{
+		"aws_iam_role.r":                          {NumCalls: 1, TotalTime: 2000, Operation: Create, AfterStatus: Created},
+		"module.app.aws_s3_bucket.b":              {NumCalls: 1, TotalTime: 60000, Operation: Create, AfterStatus: Created},
+		"module.app.module.db.aws_db_instance.d":  {NumCalls: 1, TotalTime: 300000, Operation: Modify, AfterStatus: Failed},
+		"module.app.module.db.aws_db_subnet.s[*]": {NumCalls: 3, TotalTime: 9000, Operation: Create, AfterStatus: Created},
+		"module.dns.aws_route53_record.r":         {NumCalls: 1, TotalTime: 1000, Operation: Create, AfterStatus: Created},
+	}}
+}
+
+func TestBuildModuleTree(t *testing.T) {
+	Root := BuildModuleTree(treeLog())
+	assert.Equal(t, 7, Root.NumCalls)
+	assert.Equal(t, float64(372000), Root.TotalTime)
+	assert.Equal(t, []string{"aws_iam_role.r"}, Root.Resources)
+
+	App := Root.Children["module.app"]
+	assert.Equal(t, 5, App.NumCalls)
+	assert.Equal(t, 1, App.Failures)
+	assert.Equal(t, map[Operation]int{Create: 4, Modify: 1}, App.Operations)
+	assert.Equal(t, 4, App.Children["module.db"].NumCalls)
+
+	Children := Root.SortedChildren()
+	assert.Equal(t, "module.app", Children[0].Name)
+	assert.Equal(t, "module.dns", Children[1].Name)
+}
+
+func TestTreeRows(t *testing.T) {
+	log := treeLog()
+	Root := BuildModuleTree(log)
+	Names := func(options TreeOptions) []interface{} {
+		result := []interface{}{}
+		for _, row := range treeRows(log, Root, 0, Root.TotalTime, options) {
+			result = append(result, row[0])
+		}
+		return result
+	}
+
+	assert.Equal(t, []interface{}{"(root)", "  module.app", "    module.db", "  module.dns"}, Names(TreeOptions{}))
+	assert.Equal(t, []interface{}{"(root)", "  module.app (+1 modules)", "  module.dns"}, Names(TreeOptions{Depth: 1}))
+	assert.Equal(t, []interface{}{"(root)", "  module.app", "    module.db"}, Names(TreeOptions{MinTime: 2000}))
+	assert.Equal(t, []interface{}{
+		"(root)", "  aws_iam_role.r", "  module.app", "    aws_s3_bucket.b", "    module.db", "  module.dns", "    aws_route53_record.r",
+	}, Names(TreeOptions{Depth: 2, Expand: 1}))
+
+	// module.app took almost all of the time, module.dns less than 1%
+	assert.Equal(t, []interface{}{"(root)", "  module.app", "    module.db", "  module.dns"}, Names(TreeOptions{Collapse: 1}))
+	Root.Children["module.dns"].Children["module.zone"] = newModuleNode("module.zone")
+	assert.Equal(t, []interface{}{"(root)", "  module.app", "    module.db", "  module.dns (+1 modules)"}, Names(TreeOptions{Collapse: 1}))
+
+	Rows := treeRows(log, Root, 0, Root.TotalTime, TreeOptions{Depth: 1})
+	assert.Equal(t, []interface{}{"  module.app (+1 modules)", 5, "6m9s", 1, "Create:4 Modify:1"}, Rows[1])
+}
+
+func TestTree(t *testing.T) {
+	err := Tree([]string{"../../../test/many_modules.log"}, false, true, "module", 0, "", nil, TreeOptions{Depth: 2, Expand: 2}, false)
+	assert.Nil(t, err)
+	err = Tree([]string{"../../../test/terragrunt.log"}, false, true, "resource", 0, "", nil, TreeOptions{}, false)
+	assert.Nil(t, err)
+	err = Tree([]string{"does-not-exist"}, false, true, "resource", 0, "", nil, TreeOptions{}, false)
+	assert.NotNil(t, err)
+}
@@ -3,20 +3,51 @@ package tfprofile
 import (
 	"bufio"
 	"fmt"
+	"os"
 
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/aggregate"
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/parser"
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/readers"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/runs"
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/sort"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/tfjson"
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/utils"
 
 	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/labels"
 	"github.com/fatih/color"
 	"github.com/rodaine/table"
 )
 
+// Options of the `tf-profile table` command
+type TableOptions struct {
+	// Max recursive module depth before aggregating (not implemented yet)
+	MaxDepth int
+	// Print the log while parsing it
+	Tee bool
+	// Comma-separated list of KEY=(asc|desc), see the sort package
+	Sort string
+	// Aggregate count[] and for_each[], at the given levels
+	Aggregate       bool
+	AggregateLevels string
+	// Only profile this run (0 for all runs) and this Terragrunt unit
+	Run  int
+	Unit string
+	// JSON plan and state from before the run, if any
+	Plan  string
+	State string
+	// Show one row per group of resources instead, see GroupBy
+	GroupBy string
+	// Only profile the resources with these labels, see FilterLabels
+	Labels []string
+	// Add the distribution columns, see DistributionColumns
+	Distribution bool
+	// Fail on unrecognized lines
+	Strict bool
+}
+
 // Execute the `tf-profile table` command
-func Table(args []string, max_depth int, tee bool, sort string, aggregate bool) error {
+func Table(args []string, options TableOptions) error {
 	var file *bufio.Scanner
 	var err error
 
@@ -30,19 +61,52 @@ func Table(args []string, max_depth int, tee bool, sort string, aggregate bool)
 		return err
 	}
 
-	tflog, err := Parse(file, tee)
+	tflog, runs, err := ParseRuns(file, options.Tee, options.Strict)
+	if err != nil {
+		return err
+	}
+
+	PrintRunSummary(os.Stdout, runs)
+	tflog, err = SelectRun(tflog, runs, options.Run)
+	if err != nil {
+		return err
+	}
+	PrintUnitSummary(os.Stdout, tflog)
+	tflog, err = SelectUnit(tflog, options.Unit)
 	if err != nil {
 		return err
 	}
+	tflog, err = AddPlanAndState(tflog, options.Plan, options.State)
+	if err != nil {
+		return err
+	}
+	tflog, err = FilterLabels(tflog, options.Labels)
+	if err != nil {
+		return err
+	}
+
+	// Groups are built from individual resources, so aggregating is not needed
+	if options.GroupBy != "" {
+		groups, err := GroupBy(tflog, options.GroupBy)
+		if err != nil {
+			return err
+		}
+		PrintGroupTable(groups, options.GroupBy)
+		return nil
+	}
 
-	if aggregate {
-		tflog, err = Aggregate(tflog)
+	if options.Aggregate {
+		tflog, err = AggregateLevels(tflog, options.AggregateLevels)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = PrintTable(tflog, sort)
+	extra := []Column{}
+	if options.Distribution {
+		extra = DistributionColumns
+	}
+	err = PrintTable(tflog, options.Sort, extra...)
 	if err != nil {
 		return err
 	}
@@ -50,32 +114,112 @@ func Table(args []string, max_depth int, tee bool, sort string, aggregate bool)
 	return nil
 }
 
+// A column in the output of `tf-profile table`. Value returns the content of
+// the column for one resource in the log.
+type Column struct {
+	Name  string
+	Value func(log ParsedLog, resource string) interface{}
+}
+
+// Columns printed by `tf-profile table`, in order. More can be added with
+// RegisterColumn.
+var columns = []Column{
+	{"resource", func(log ParsedLog, resource string) interface{} {
+		if log.Resources[resource].Unit == "" {
+			return resource
+		}
+		_, address := SplitUnit(resource) // The unit has a column of its own
+		return address
+	}},
+	{"n", func(log ParsedLog, resource string) interface{} { return log.Resources[resource].NumCalls }},
+	{"tot_time", func(log ParsedLog, resource string) interface{} {
+		return FormatDuration(int(log.Resources[resource].TotalTime / 1000)) // Display as "10s" or "1m30s"
+	}},
+	{"modify_started", func(log ParsedLog, resource string) interface{} {
+		return removeMinusOne(log.Resources[resource].ModificationStartedIndex)
+	}},
+	{"modify_ended", func(log ParsedLog, resource string) interface{} {
+		return removeMinusOne(log.Resources[resource].ModificationCompletedIndex)
+	}},
+	{"desired_state", func(log ParsedLog, resource string) interface{} { return log.Resources[resource].DesiredStatus }},
+	{"operation", func(log ParsedLog, resource string) interface{} { return log.Resources[resource].Operation }},
+	{"final_state", func(log ParsedLog, resource string) interface{} { return log.Resources[resource].AfterStatus }},
+	{"drift", func(log ParsedLog, resource string) interface{} { return log.Resources[resource].Drift }},
+}
+
+// Column printed after the resource column for logs of Terragrunt units
+var unitColumn = Column{"unit", func(log ParsedLog, resource string) interface{} { return log.Resources[resource].Unit }}
+
+// Column printed after the resource (and unit) column when providers are known
+var providerColumn = Column{"provider", func(log ParsedLog, resource string) interface{} { return log.Resources[resource].Provider }}
+
+// Column printed before the desired_state column when the state before the run is known
+var beforeStateColumn = Column{"before_state", func(log ParsedLog, resource string) interface{} { return log.Resources[resource].BeforeStatus }}
+
+// Optional columns with the distribution of the durations of the instances
+// of aggregated resources (see Distribution). Empty ("/") for resources
+// that were not aggregated.
+var DistributionColumns = []Column{
+	distributionColumn("min_time", func(d Distribution) interface{} { return formatMillis(d.Min) }),
+	distributionColumn("max_time", func(d Distribution) interface{} { return formatMillis(d.Max) }),
+	distributionColumn("mean_time", func(d Distribution) interface{} { return formatMillis(d.Mean) }),
+	distributionColumn("median_time", func(d Distribution) interface{} { return formatMillis(d.Median) }),
+	distributionColumn("p90_time", func(d Distribution) interface{} { return formatMillis(d.P90) }),
+	distributionColumn("p99_time", func(d Distribution) interface{} { return formatMillis(d.P99) }),
+	distributionColumn("stddev", func(d Distribution) interface{} { return formatMillis(d.StdDev) }),
+	distributionColumn("slowest", func(d Distribution) interface{} { return d.Slowest }),
+}
+
+func distributionColumn(name string, value func(d Distribution) interface{}) Column {
+	return Column{name, func(log ParsedLog, resource string) interface{} {
+		Dist := log.Resources[resource].Distribution
+		if Dist.Count == 0 {
+			return "/"
+		}
+		return value(Dist)
+	}}
+}
+
+// Display a duration in ms as "10s" or "1m30s"
+func formatMillis(ms float64) string {
+	return FormatDuration(int(ms / 1000))
+}
+
+// Columns printed at the end for logs with TF_LOG=trace output
+var providerColumns = []Column{
+	{"api_calls", func(log ParsedLog, resource string) interface{} { return log.Resources[resource].ProviderCalls }},
+	{"api_time", func(log ParsedLog, resource string) interface{} {
+		return fmt.Sprintf("%vms", int(log.Resources[resource].ProviderTime)) // Provider calls are often sub-second
+	}},
+	{"http_requests", func(log ParsedLog, resource string) interface{} { return log.Resources[resource].HTTPRequests }},
+	{"retries", func(log ParsedLog, resource string) interface{} { return log.Resources[resource].HTTPRetries }},
+	{"throttled", func(log ParsedLog, resource string) interface{} { return log.Resources[resource].HTTPThrottled }},
+	{"server_errors", func(log ParsedLog, resource string) interface{} { return log.Resources[resource].HTTPServerErrors }},
+}
+
+// Add a column to the output of `tf-profile table`, after all existing columns
+func RegisterColumn(column Column) {
+	columns = append(columns, column)
+}
+
 // Print a parsed log in tabular format, optionally sorting by certain columns
 // sort_spec is a comma-separated list of "column_name=(asc|desc)", e.g. "n=asc,tot_time=desc"
-func PrintTable(log ParsedLog, sort_spec string) error {
+// Extra columns (e.g. DistributionColumns) are printed after the built-in columns.
+func PrintTable(log ParsedLog, sort_spec string, extra ...Column) error {
 	headerFmt := color.New(color.FgHiBlue, color.Underline).SprintfFunc()
 	columnFmt := color.New(color.FgBlue).SprintfFunc()
 
-	tbl := table.New("resource", "n", "tot_time", "modify_started", "modify_ended", "desired_state", "operation", "final_state")
+	columns := columnsFor(log, extra)
+	headers := []interface{}{}
+	for _, column := range columns {
+		headers = append(headers, column.Name)
+	}
+	tbl := table.New(headers...)
 	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 
 	// Sort the resources according to the sort_spec and create rows
-	for _, r := range Sort(log, sort_spec) {
-		for resource, metric := range log.Resources {
-			if r == resource {
-				tbl.AddRow(
-					resource,
-					(metric.NumCalls),
-					FormatDuration(int(metric.TotalTime/1000)), // Display as "10s" or "1m30s"
-					removeMinusOne(metric.ModificationStartedIndex),
-					removeMinusOne(metric.ModificationCompletedIndex),
-					(metric.DesiredStatus),
-					(metric.Operation),
-					(metric.AfterStatus),
-				)
-				break
-			}
-		}
+	for _, resource := range Sort(log, sort_spec) {
+		tbl.AddRow(rowFor(log, columns, resource)...)
 	}
 
 	fmt.Println() // Create space above the table
@@ -84,6 +228,83 @@ func PrintTable(log ParsedLog, sort_spec string) error {
 	return nil
 }
 
+// Print one row per group of resources (see GroupBy), sorted by cumulative
+// duration. The first column is named after the grouping, e.g. "type".
+func PrintGroupTable(groups []Group, group_by string) {
+	headerFmt := color.New(color.FgHiBlue, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgBlue).SprintfFunc()
+
+	tbl := table.New(group_by, "n", "tot_time", "max_time", "mean_time", "p95_time", "failed", "operations")
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	for _, group := range groups {
+		tbl.AddRow(getGroupRow(group)...)
+	}
+
+	fmt.Println() // Create space above the table
+	tbl.Print()
+}
+
+func getGroupRow(group Group) []interface{} {
+	return []interface{}{
+		group.Name,
+		group.Metric.NumCalls,
+		FormatDuration(int(group.Metric.TotalTime / 1000)),
+		FormatDuration(int(group.MaxTime / 1000)),
+		FormatDuration(int(group.MeanTime / 1000)),
+		FormatDuration(int(group.P95Time / 1000)),
+		group.Failures,
+		group.OperationMix(),
+	}
+}
+
+// Values of all columns for one resource
+func getRow(log ParsedLog, resource string) []interface{} {
+	return rowFor(log, columnsFor(log, nil), resource)
+}
+
+func rowFor(log ParsedLog, columns []Column, resource string) []interface{} {
+	row := []interface{}{}
+	for _, column := range columns {
+		row = append(row, column.Value(log, resource))
+	}
+	return row
+}
+
+// Columns to print for a log. Logs of Terragrunt units get an extra unit column,
+// logs with a JSON plan or state a provider column (and a before_state column
+// for a state) and logs with trace output the provider API columns. Extra
+// columns are printed after the built-in columns.
+func columnsFor(log ParsedLog, extra []Column) []Column {
+	result := []Column{columns[0]}
+	if len(log.Units) > 0 {
+		result = append(result, unitColumn)
+	}
+	if hasProviders(log) {
+		result = append(result, providerColumn)
+	}
+	for _, column := range columns[1:] {
+		if column.Name == "desired_state" && log.ContainsState {
+			result = append(result, beforeStateColumn)
+		}
+		result = append(result, column)
+	}
+	result = append(result, extra...)
+	if log.ContainsTrace {
+		result = append(result, providerColumns...)
+	}
+	return result
+}
+
+// Returns true if the provider of any resource is known
+func hasProviders(log ParsedLog) bool {
+	for _, metric := range log.Resources {
+		if metric.Provider != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // Many metrics use -1 as value for "unknown at the time". When a resource change fails,
 // these initial values remain in the log. Before printing, we replace then with '/'
 func removeMinusOne(val int) string {
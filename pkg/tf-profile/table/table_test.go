@@ -1,17 +1,116 @@
 package tfprofile
 
 import (
+	"strings"
 	"testing"
 
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/aggregate"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
 	"github.com/stretchr/testify/assert"
 )
 
 func TestBasicRun(t *testing.T) {
-	err := Table([]string{}, 1, true, "tot_time=asc", true)
+	err := Table([]string{}, TableOptions{MaxDepth: 1, Tee: true, Sort: "tot_time=asc", Aggregate: true, AggregateLevels: "resource"})
 	assert.Nil(t, err)
 }
 
 func TestFileDoesntExist(t *testing.T) {
-	err := Table([]string{"does-not-exist"}, 1, true, "tot_time=asc", true)
+	err := Table([]string{"does-not-exist"}, TableOptions{MaxDepth: 1, Tee: true, Sort: "tot_time=asc", Aggregate: true, AggregateLevels: "resource"})
+	assert.NotNil(t, err)
+}
+
+func TestRegisterColumn(t *testing.T) {
+	defer func(saved []Column) { columns = saved }(columns)
+
+	RegisterColumn(Column{"owner", func(log ParsedLog, resource string) interface{} {
+		return "team-" + strings.Split(resource, ".")[0]
+	}})
+
+	log := ParsedLog{Resources: map[string]ResourceMetric{
+		"aws_ssm_parameter.p1": {NumCalls: 1, TotalTime: 2000, ModificationStartedIndex: -1, Operation: Create},
+	}}
+	Row := getRow(log, "aws_ssm_parameter.p1")
+	assert.Equal(t, 10, len(Row))
+	assert.Equal(t, "aws_ssm_parameter.p1", Row[0])
+	assert.Equal(t, "2s", Row[2])
+	assert.Equal(t, "/", Row[3])
+	assert.Equal(t, "team-aws_ssm_parameter", Row[9])
+}
+
+func TestUnitColumn(t *testing.T) {
+	log := ParsedLog{
+		Units: []string{"network"},
+		Resources: map[string]ResourceMetric{
+			"[network] aws_vpc.this": {NumCalls: 1, TotalTime: 14000, ModificationStartedIndex: 0, Unit: "network"},
+		},
+	}
+	Row := getRow(log, "[network] aws_vpc.this")
+	assert.Equal(t, "aws_vpc.this", Row[0])
+	assert.Equal(t, "network", Row[1])
+	assert.Equal(t, "14s", Row[3])
+
+	err := Table([]string{"../../../test/terragrunt.log"}, TableOptions{MaxDepth: 1, Sort: "tot_time=asc", Aggregate: true, AggregateLevels: "resource", Unit: "network"})
+	assert.Nil(t, err)
+	err = Table([]string{"../../../test/terragrunt.log"}, TableOptions{MaxDepth: 1, Sort: "tot_time=asc", Aggregate: true, AggregateLevels: "resource", Unit: "database"})
 	assert.NotNil(t, err)
 }
+
+func TestSelectRun(t *testing.T) {
+	for _, file := range []string{"../../../test/terragrunt.log", "../../../test/trace.log"} {
+		err := Table([]string{file}, TableOptions{MaxDepth: 1, Sort: "tot_time=asc", AggregateLevels: "resource", Run: 1})
+		assert.Nil(t, err, file)
+		err = Table([]string{file}, TableOptions{MaxDepth: 1, Sort: "tot_time=asc", AggregateLevels: "resource", Run: 2})
+		assert.NotNil(t, err, file)
+	}
+}
+
+func TestProviderColumns(t *testing.T) {
+	log := ParsedLog{
+		ContainsState: true,
+		ContainsTrace: true,
+		Resources: map[string]ResourceMetric{
+			"aws_vpc.this": {NumCalls: 1, BeforeStatus: NotCreated, Provider: "hashicorp/aws", ProviderTime: 1549, HTTPThrottled: 2},
+		},
+	}
+	Names := []string{}
+	for _, column := range columnsFor(log, nil) {
+		Names = append(Names, column.Name)
+	}
+	assert.Equal(t, []string{"resource", "provider", "n", "tot_time", "modify_started", "modify_ended", "before_state", "desired_state", "operation", "final_state", "drift", "api_calls", "api_time", "http_requests", "retries", "throttled", "server_errors"}, Names)
+
+	Row := getRow(log, "aws_vpc.this")
+	assert.Equal(t, "hashicorp/aws", Row[1])
+	assert.Equal(t, NotCreated, Row[6])
+	assert.Equal(t, "1549ms", Row[12])
+	assert.Equal(t, 2, Row[15])
+
+	err := Table([]string{"../../../test/all_operations.log"}, TableOptions{MaxDepth: 1, Sort: "tot_time=asc", Aggregate: true, AggregateLevels: "resource", Plan: "../../../test/all_operations_plan.json", State: "../../../test/all_operations.tfstate"})
+	assert.Nil(t, err)
+	err = Table([]string{"../../../test/all_operations.log"}, TableOptions{MaxDepth: 1, Sort: "tot_time=asc", Aggregate: true, AggregateLevels: "resource", State: "does-not-exist"})
+	assert.NotNil(t, err)
+}
+
+func TestGroupTable(t *testing.T) {
+	Row := getGroupRow(Group{Name: "aws_vpc", Metric: ResourceMetric{NumCalls: 2, TotalTime: 90000}, MaxTime: 60000, MeanTime: 45000, P95Time: 60000, Failures: 1, Operations: map[Operation]int{Create: 2}})
+	assert.Equal(t, []interface{}{"aws_vpc", 2, "1m30s", "1m0s", "45s", "1m0s", 1, "Create:2"}, Row)
+
+	err := Table([]string{"../../../test/many_modules.log"}, TableOptions{GroupBy: "top-module"})
+	assert.Nil(t, err)
+	err = Table([]string{"../../../test/many_modules.log"}, TableOptions{GroupBy: "color"})
+	assert.NotNil(t, err)
+}
+
+func TestDistributionColumns(t *testing.T) {
+	log := ParsedLog{Resources: map[string]ResourceMetric{
+		"r[*]": {NumCalls: 2, TotalTime: 70000, Distribution: Distribution{Count: 2, Min: 10000, Max: 60000, Mean: 35000, Median: 35000, P90: 60000, P99: 60000, StdDev: 25000, Slowest: "r[1]"}},
+		"s":    {NumCalls: 1, TotalTime: 5000},
+	}}
+	Columns := columnsFor(log, DistributionColumns)
+	assert.Equal(t, "min_time", Columns[9].Name)
+	assert.Equal(t, []interface{}{"10s", "1m0s", "35s", "35s", "1m0s", "1m0s", "25s", "r[1]"}, rowFor(log, Columns, "r[*]")[9:])
+	assert.Equal(t, "/", rowFor(log, Columns, "s")[16])
+
+	err := Table([]string{"../../../test/aggregate.log"}, TableOptions{MaxDepth: 1, Sort: "tot_time=asc", Aggregate: true, AggregateLevels: "resource", Distribution: true})
+	assert.Nil(t, err)
+}
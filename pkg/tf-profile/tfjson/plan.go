@@ -0,0 +1,158 @@
+package tfprofile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+// Output of `terraform show -json <planfile>`. Only the fields used by
+// tf-profile are read.
+type Plan struct {
+	FormatVersion   string           `json:"format_version"`
+	ResourceChanges []ResourceChange `json:"resource_changes"`
+	// State before the run, used when no state file is passed
+	PriorState *ShowState `json:"prior_state"`
+}
+
+// Planned change of a single resource instance
+type ResourceChange struct {
+	Address         string `json:"address"`
+	PreviousAddress string `json:"previous_address"`
+	Mode            string `json:"mode"`
+	ProviderName    string `json:"provider_name"`
+	// Set for changes to deposed objects, which are not profiled
+	Deposed string `json:"deposed"`
+	Change  struct {
+		Actions   []string  `json:"actions"`
+		Importing *struct{} `json:"importing"`
+	} `json:"change"`
+	// E.g. "replace_because_tainted" or "delete_because_no_resource_config"
+	ActionReason string `json:"action_reason"`
+}
+
+// Replace reasons of a JSON plan, and how they are called in the human-readable plan
+var replaceReasons = map[string]string{
+	"replace_because_tainted":       "tainted",
+	"replace_by_request":            "requested",
+	"replace_because_cannot_update": "forced",
+}
+
+// Read the output of `terraform show -json <planfile>`
+func ReadPlan(File string) (Plan, error) {
+	content, err := os.ReadFile(File)
+	if err != nil {
+		return Plan{}, err
+	}
+	plan := Plan{}
+	err = json.Unmarshal(content, &plan)
+	if err != nil {
+		return Plan{}, fmt.Errorf("Unable to read JSON plan %v: %v", File, err)
+	}
+	if plan.FormatVersion == "" {
+		return Plan{}, fmt.Errorf("Unable to read JSON plan %v: not the output of `terraform show -json`.", File)
+	}
+	return plan, nil
+}
+
+// Add the changes of a JSON plan to a parsed log. The plan is authoritative:
+// it overrides the operation and desired state parsed from the log. Resources
+// that the log never mentions are added as well.
+func AddPlan(tflog ParsedLog, plan Plan) ParsedLog {
+	if tflog.PlannedChanges == nil {
+		tflog.PlannedChanges = map[string]PlannedChange{}
+	}
+
+	for _, change := range plan.ResourceChanges {
+		if change.Deposed != "" {
+			continue
+		}
+		Op := planOperation(change)
+		Seen := registerResource(tflog, change.Address)
+
+		metric := tflog.Resources[change.Address]
+		metric.Operation = Op
+		metric.DesiredStatus = Created
+		if Op == Destroy || Op == Forget {
+			metric.DesiredStatus = NotCreated
+		}
+		if Op == Create || change.Change.Importing != nil {
+			metric.BeforeStatus = NotCreated
+		}
+		if !Seen {
+			// Nothing happened to the resource during the run
+			metric.AfterStatus = metric.BeforeStatus
+		}
+		if metric.MovedFrom == "" {
+			metric.MovedFrom = change.PreviousAddress
+		}
+		metric.Provider = providerName(change.ProviderName)
+		tflog.Resources[change.Address] = metric
+
+		if Op == None {
+			continue
+		}
+		planned := tflog.PlannedChanges[change.Address] // Keep attributes parsed from the log
+		planned.Operation = Op
+		planned.ReplaceReason = replaceReasons[change.ActionReason]
+		planned.Imported = planned.Imported || change.Change.Importing != nil
+		planned.ActionReason = change.ActionReason
+		tflog.PlannedChanges[change.Address] = planned
+	}
+	return tflog
+}
+
+// Operation for the actions of a planned change
+func planOperation(change ResourceChange) Operation {
+	switch strings.Join(change.Change.Actions, ",") {
+	case "create":
+		return Create
+	case "update":
+		return Modify
+	case "delete":
+		return Destroy
+	case "delete,create", "create,delete":
+		return Replace
+	case "read":
+		return Read
+	case "forget":
+		return Forget
+	}
+	// No-op: the resource may still be imported or moved
+	if change.Change.Importing != nil {
+		return Import
+	}
+	if change.PreviousAddress != "" {
+		return Move
+	}
+	return None
+}
+
+// Register a resource found in a plan or state file. Returns true if the log
+// already contained the resource.
+func registerResource(tflog ParsedLog, Resource string) bool {
+	if _, found := tflog.Resources[Resource]; found {
+		return true
+	}
+	tflog.RegisterNewResource(Resource)
+	metric := tflog.Resources[Resource]
+	metric.TotalTime = 0
+	metric.ModificationStartedIndex = -1
+	metric.ModificationStartedEvent = -1
+	tflog.Resources[Resource] = metric
+	return false
+}
+
+// Short name of a provider, e.g. "hashicorp/aws" for
+// "registry.terraform.io/hashicorp/aws" or `provider["registry.terraform.io/hashicorp/aws"].west`
+func providerName(in string) string {
+	if start := strings.Index(in, `["`); start >= 0 {
+		in = in[start+2:]
+		in = strings.Split(in, `"]`)[0]
+	}
+	in = strings.TrimPrefix(in, "registry.terraform.io/")
+	return strings.TrimPrefix(in, "registry.opentofu.org/")
+}
@@ -0,0 +1,90 @@
+package tfprofile
+
+import (
+	"bufio"
+	"os"
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseFile(t *testing.T, File string) ParsedLog {
+	file, _ := os.Open(File)
+	log, err := Parse(bufio.NewScanner(file), false)
+	assert.Nil(t, err)
+	return log
+}
+
+func TestReadPlan(t *testing.T) {
+	plan, err := ReadPlan("../../../test/all_operations_plan.json")
+	assert.Nil(t, err)
+	assert.Equal(t, 8, len(plan.ResourceChanges))
+	assert.Equal(t, 9, len(plan.PriorState.State().Resources))
+
+	_, err = ReadPlan("../../../test/all_operations.tfstate")
+	assert.NotNil(t, err)
+	_, err = ReadPlan("../../../test/all_operations.log")
+	assert.NotNil(t, err)
+	_, err = ReadPlan("does-not-exist")
+	assert.NotNil(t, err)
+}
+
+func TestAddPlan(t *testing.T) {
+	plan, _ := ReadPlan("../../../test/all_operations_plan.json")
+	log := AddPlan(parseFile(t, "../../../test/all_operations.log"), plan)
+
+	assert.Equal(t, 8, len(log.Resources))
+	assert.Equal(t, Replace, log.Resources["aws_ssm_parameter.p1"].Operation)
+	assert.Equal(t, NotCreated, log.Resources["aws_ssm_parameter.p4"].DesiredStatus)
+	assert.Equal(t, "hashicorp/aws", log.Resources["aws_ssm_parameter.p5"].Provider)
+	assert.Equal(t, "tainted", log.PlannedChanges["aws_ssm_parameter.p1"].ReplaceReason)
+	assert.Equal(t, "forced", log.PlannedChanges["aws_ssm_parameter.p6"].ReplaceReason)
+	assert.Equal(t, "delete_because_no_resource_config", log.PlannedChanges["aws_ssm_parameter.p4"].ActionReason)
+	// Attributes parsed from the log are kept
+	assert.NotEmpty(t, log.PlannedChanges["aws_ssm_parameter.p6"].Attributes)
+	assert.Equal(t, []string{}, log.SummaryMismatches())
+
+	// Resources the log never mentions
+	extra := log.Resources["aws_ssm_parameter.extra[0]"]
+	assert.Equal(t, None, extra.Operation)
+	assert.Equal(t, Created, extra.AfterStatus)
+	assert.Equal(t, -1, extra.ModificationStartedIndex)
+	_, found := log.PlannedChanges["aws_ssm_parameter.extra[0]"]
+	assert.False(t, found)
+}
+
+func TestPlanOperation(t *testing.T) {
+	tests := []struct {
+		actions   []string
+		importing bool
+		previous  string
+		op        Operation
+	}{
+		{[]string{"create"}, false, "", Create},
+		{[]string{"create", "delete"}, false, "", Replace},
+		{[]string{"read"}, false, "", Read},
+		{[]string{"forget"}, false, "", Forget},
+		{[]string{"no-op"}, true, "", Import},
+		{[]string{"update"}, true, "", Modify},
+		{[]string{"no-op"}, false, "aws_s3_bucket.old", Move},
+		{[]string{"no-op"}, false, "", None},
+	}
+	for _, test := range tests {
+		change := ResourceChange{PreviousAddress: test.previous}
+		change.Change.Actions = test.actions
+		if test.importing {
+			change.Change.Importing = &struct{}{}
+		}
+		assert.Equal(t, test.op, planOperation(change), test.actions)
+	}
+}
+
+func TestProviderName(t *testing.T) {
+	assert.Equal(t, "hashicorp/aws", providerName("registry.terraform.io/hashicorp/aws"))
+	assert.Equal(t, "hashicorp/aws", providerName(`provider["registry.terraform.io/hashicorp/aws"].west`))
+	assert.Equal(t, "opentofu/random", providerName(`provider["registry.opentofu.org/opentofu/random"]`))
+	assert.Equal(t, "example.com/acme/widget", providerName("example.com/acme/widget"))
+}
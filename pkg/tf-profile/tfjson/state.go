@@ -0,0 +1,150 @@
+package tfprofile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+// Resources in the state before a run. Read from a state file
+// (terraform.tfstate, `terraform state pull`) or from the output of
+// `terraform show -json`.
+type State struct {
+	Resources []StateResource
+}
+
+// A single resource instance in the state
+type StateResource struct {
+	Address  string
+	Provider string
+}
+
+// State as printed by `terraform show -json`, or the prior_state of a JSON plan
+type ShowState struct {
+	Values *struct {
+		RootModule ShowModule `json:"root_module"`
+	} `json:"values"`
+}
+
+type ShowModule struct {
+	Resources []struct {
+		Address      string `json:"address"`
+		ProviderName string `json:"provider_name"`
+	} `json:"resources"`
+	ChildModules []ShowModule `json:"child_modules"`
+}
+
+// State file, as written by Terraform (format version 4)
+type stateFile struct {
+	Version   int `json:"version"`
+	Resources []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Provider  string `json:"provider"`
+		Instances []struct {
+			IndexKey interface{} `json:"index_key"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+// Read a state file, or the output of `terraform show -json`
+func ReadState(File string) (State, error) {
+	content, err := os.ReadFile(File)
+	if err != nil {
+		return State{}, err
+	}
+
+	show := ShowState{}
+	err = json.Unmarshal(content, &show)
+	if err != nil {
+		return State{}, fmt.Errorf("Unable to read state %v: %v", File, err)
+	}
+	if show.Values != nil {
+		return show.State(), nil
+	}
+
+	raw := stateFile{}
+	err = json.Unmarshal(content, &raw)
+	if err != nil || raw.Version == 0 {
+		return State{}, fmt.Errorf("Unable to read state %v: not a state file or the output of `terraform show -json`.", File)
+	}
+	state := State{}
+	for _, resource := range raw.Resources {
+		Address := resource.Type + "." + resource.Name
+		if resource.Mode == "data" {
+			Address = "data." + Address
+		}
+		if resource.Module != "" {
+			Address = resource.Module + "." + Address
+		}
+		for _, instance := range resource.Instances {
+			state.Resources = append(state.Resources, StateResource{
+				Address:  Address + instanceKey(instance.IndexKey),
+				Provider: providerName(resource.Provider),
+			})
+		}
+	}
+	return state, nil
+}
+
+// Resources in the output of `terraform show -json`
+func (s ShowState) State() State {
+	state := State{}
+	if s.Values == nil {
+		return state
+	}
+	modules := []ShowModule{s.Values.RootModule}
+	for len(modules) > 0 {
+		module := modules[0]
+		modules = append(modules[1:], module.ChildModules...)
+		for _, resource := range module.Resources {
+			state.Resources = append(state.Resources, StateResource{resource.Address, providerName(resource.ProviderName)})
+		}
+	}
+	return state
+}
+
+// Instance key of a resource in a state file: a number for count, a string for for_each
+func instanceKey(key interface{}) string {
+	switch key := key.(type) {
+	case string:
+		return fmt.Sprintf("[%q]", key)
+	case float64:
+		return fmt.Sprintf("[%v]", int(key))
+	default:
+		return ""
+	}
+}
+
+// Add the resources of the state before a run to a parsed log. Resources in
+// the state existed before the run, other resources did not (data sources are
+// left alone). Resources that the log never mentions are added as well, e.g.
+// the instances of a count that were not changed.
+func AddState(tflog ParsedLog, state State) ParsedLog {
+	tflog.ContainsState = true
+	InState := map[string]bool{}
+	for _, resource := range state.Resources {
+		InState[resource.Address] = true
+		registerResource(tflog, resource.Address)
+
+		metric := tflog.Resources[resource.Address]
+		metric.BeforeStatus = Created
+		if metric.Provider == "" {
+			metric.Provider = resource.Provider
+		}
+		tflog.Resources[resource.Address] = metric
+	}
+
+	for resource, metric := range tflog.Resources {
+		if InState[resource] || InState[metric.MovedFrom] || IsDataSource(resource) {
+			continue
+		}
+		metric.BeforeStatus = NotCreated
+		tflog.Resources[resource] = metric
+	}
+	return tflog
+}
@@ -0,0 +1,74 @@
+package tfprofile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadState(t *testing.T) {
+	state, err := ReadState("../../../test/all_operations.tfstate")
+	assert.Nil(t, err)
+	assert.Equal(t, 9, len(state.Resources))
+	assert.Equal(t, StateResource{"aws_ssm_parameter.extra[1]", "hashicorp/aws"}, state.Resources[7])
+	assert.Equal(t, "data.aws_region.current", state.Resources[8].Address)
+
+	// Output of `terraform show -json`, with child modules
+	File := filepath.Join(t.TempDir(), "state.json")
+	os.WriteFile(File, []byte(`{"format_version":"1.0","values":{"root_module":{
+		"resources":[{"address":"aws_vpc.this","provider_name":"registry.terraform.io/hashicorp/aws"}],
+		"child_modules":[{"address":"module.a","resources":[{"address":"module.a.aws_subnet.b[\"x\"]","provider_name":"registry.terraform.io/hashicorp/aws"}]}]}}}`), 0644)
+	state, err = ReadState(File)
+	assert.Nil(t, err)
+	assert.Equal(t, []StateResource{{"aws_vpc.this", "hashicorp/aws"}, {`module.a.aws_subnet.b["x"]`, "hashicorp/aws"}}, state.Resources)
+
+	_, err = ReadState("../../../test/all_operations.log")
+	assert.NotNil(t, err)
+	_, err = ReadState("../../../test/test_file.txt")
+	assert.NotNil(t, err)
+}
+
+func TestInstanceKey(t *testing.T) {
+	assert.Equal(t, "", instanceKey(nil))
+	assert.Equal(t, "[2]", instanceKey(float64(2)))
+	assert.Equal(t, `["eu-west-1"]`, instanceKey("eu-west-1"))
+}
+
+func TestAddState(t *testing.T) {
+	log := parseFile(t, "../../../test/all_operations.log")
+	log.RegisterNewResource("aws_ssm_parameter.new")
+	log = AddState(log, State{Resources: []StateResource{
+		{"aws_ssm_parameter.p1", "hashicorp/aws"},
+		{"aws_ssm_parameter.untouched", "hashicorp/aws"},
+		{"data.aws_region.current", "hashicorp/aws"},
+	}})
+
+	assert.True(t, log.ContainsState)
+	assert.Equal(t, Created, log.Resources["aws_ssm_parameter.p1"].BeforeStatus)
+	assert.Equal(t, NotCreated, log.Resources["aws_ssm_parameter.new"].BeforeStatus)
+	assert.Equal(t, Created, log.Resources["aws_ssm_parameter.untouched"].BeforeStatus)
+	assert.Equal(t, "hashicorp/aws", log.Resources["aws_ssm_parameter.untouched"].Provider)
+	assert.Equal(t, Created, log.Resources["data.aws_region.current"].BeforeStatus)
+}
+
+func TestAddPlanAndState(t *testing.T) {
+	log := parseFile(t, "../../../test/all_operations.log")
+	same, err := AddPlanAndState(log, "", "")
+	assert.Nil(t, err)
+	assert.False(t, same.ContainsState)
+
+	// The prior state of the plan is used without a state file
+	log, err = AddPlanAndState(log, "../../../test/all_operations_plan.json", "")
+	assert.Nil(t, err)
+	assert.True(t, log.ContainsState)
+	assert.Equal(t, 9, len(log.Resources))
+
+	_, err = AddPlanAndState(parseFile(t, "../../../test/terragrunt.log"), "", "../../../test/all_operations.tfstate")
+	assert.NotNil(t, err)
+	_, err = AddPlanAndState(parseFile(t, "../../../test/all_operations.log"), "does-not-exist", "")
+	assert.NotNil(t, err)
+}
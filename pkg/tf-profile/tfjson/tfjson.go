@@ -0,0 +1,44 @@
+package tfprofile
+
+import (
+	"fmt"
+
+	. "github.com/QuintenBruynseraede/tf-profile/pkg/tf-profile/core"
+)
+
+// Add a JSON plan (`terraform show -json <planfile>`) and the state before
+// the run to a parsed log, if given. Without a state file, the prior state of
+// the plan is used. Both files describe a single Terraform configuration, so
+// a log with multiple Terragrunt units must be narrowed down to one first.
+func AddPlanAndState(tflog ParsedLog, PlanFile string, StateFile string) (ParsedLog, error) {
+	if PlanFile == "" && StateFile == "" {
+		return tflog, nil
+	}
+	if len(tflog.Units) > 0 {
+		return ParsedLog{}, fmt.Errorf("Unable to add a plan or state to a log with multiple Terragrunt units, select one with --unit.")
+	}
+
+	var state *State
+	if PlanFile != "" {
+		plan, err := ReadPlan(PlanFile)
+		if err != nil {
+			return ParsedLog{}, err
+		}
+		tflog = AddPlan(tflog, plan)
+		if plan.PriorState != nil {
+			prior := plan.PriorState.State()
+			state = &prior
+		}
+	}
+	if StateFile != "" {
+		read, err := ReadState(StateFile)
+		if err != nil {
+			return ParsedLog{}, err
+		}
+		state = &read
+	}
+	if state != nil {
+		tflog = AddState(tflog, *state)
+	}
+	return tflog, nil
+}